@@ -2,7 +2,10 @@ package builder
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -19,41 +22,99 @@ func CreateStep(stepType string, stepConfig map[string]any) (models.Step, error)
 	}
 
 	// Pre-process all configuration values to convert special prefixes
-	// ($var:, $secret:, $env:, $js:) into appropriate ValueSpec types
-	processedConfig := preprocessStepConfig(stepConfig)
+	// ($var:, $secret:, $env:, $js:, $tmpl:, $path:) and ${{ }} expressions into appropriate ValueSpec types
+	// Raw keys (source code, e.g. the code of js steps) are passed as written
+	meta, _ := DescribeStepType(stepType)
+	raw := make(map[string]any)
+	toProcess := make(map[string]any, len(stepConfig))
+	for key, value := range stepConfig {
+		if input, ok := meta.Input(key); ok && input.Raw {
+			raw[key] = value
+		} else {
+			toProcess[key] = value
+		}
+	}
+	processedConfig, err := preprocessStepConfig(toProcess)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration for step type '%s': %w", stepType, err)
+	}
+	for key, value := range raw {
+		processedConfig[key] = value
+	}
 
 	return factory(processedConfig)
 }
 
+// BaseDirKey is the step configuration key holding the directory of the pipeline file
+// Set by WithBaseDir; steps resolving relative paths (e.g. require() in js steps) read it
+const BaseDirKey = "$base_dir"
+
+// WithBaseDir returns a copy of stepConfig with BaseDirKey set to dir
+func WithBaseDir(stepConfig map[string]any, dir string) map[string]any {
+	return withReservedKey(stepConfig, BaseDirKey, dir)
+}
+
+// withReservedKey returns a copy of stepConfig with a reserved key set
+func withReservedKey(stepConfig map[string]any, key string, value any) map[string]any {
+	result := make(map[string]any, len(stepConfig)+1)
+	for k, v := range stepConfig {
+		result[k] = v
+	}
+	result[key] = value
+	return result
+}
+
+// isReservedKey reports whether a step configuration key is set by the pipeline builder
+func isReservedKey(key string) bool {
+	return key == BaseDirKey || key == HTTPClientKey
+}
+
 // preprocessStepConfig recursively processes configuration values,
 // converting strings with special prefixes into ValueSpec types
-func preprocessStepConfig(config map[string]any) map[string]any {
+func preprocessStepConfig(config map[string]any) (map[string]any, error) {
 	result := make(map[string]any)
 
 	for key, value := range config {
-		result[key] = preprocessValue(value)
+		processed, err := preprocessValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		result[key] = processed
 	}
 
-	return result
+	return result, nil
 }
 
 // preprocessValue converts a single value, handling nested structures
-func preprocessValue(value any) any {
+func preprocessValue(value any) (any, error) {
 	// If already a ValueSpec, return as-is
 	if _, ok := value.(config.ValueSpec); ok {
-		return value
+		return value, nil
 	}
 
 	switch v := value.(type) {
 	case string:
 		// Only convert strings with special prefixes to ValueSpec
 		// Keep normal strings as-is for backward compatibility
-		if strings.HasPrefix(v, "$js:") || strings.HasPrefix(v, "$var:") ||
-			strings.HasPrefix(v, "$secret:") || strings.HasPrefix(v, "$env:") {
-			return ParseConfigValue(v)
+		if strings.HasPrefix(v, "$var:") || strings.HasPrefix(v, "$secret:") ||
+			strings.HasPrefix(v, "$env:") || isExpression(v) {
+			spec := ParseConfigValue(v)
+			return spec, precompileExpressions(spec)
+		}
+		// Paths are parsed here to report syntax errors when the step is created
+		if expr, ok := strings.CutPrefix(v, "$path:"); ok {
+			return config.ParsePath(expr)
+		}
+		// Inline expressions anywhere in the string
+		if hasInterpolation(v) {
+			spec, err := ParseInterpolation(v)
+			if err != nil {
+				return nil, err
+			}
+			return spec, precompileExpressions(spec)
 		}
 		// Return normal strings as-is
-		return v
+		return v, nil
 
 	case map[string]any:
 		// Recursively process maps
@@ -63,17 +124,48 @@ func preprocessValue(value any) any {
 		// Process array elements
 		result := make([]any, len(v))
 		for i, item := range v {
-			result[i] = preprocessValue(item)
+			processed, err := preprocessValue(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = processed
 		}
-		return result
+		return result, nil
 
 	default:
 		// Return other types (numbers, booleans, nil) as-is
 		// They will be wrapped in StaticValue by the step factory if needed
-		return v
+		return v, nil
 	}
 }
 
+// isExpression reports whether a string is a "$<lang>:" expression of a registered evaluator
+func isExpression(s string) bool {
+	_, _, ok := config.CutLanguagePrefix(s)
+	return ok
+}
+
+// precompileExpressions compiles the expressions of a value (see config.ExpressionCompiler), so
+// that syntax errors are reported when the step is created and events do not pay for the compilation
+func precompileExpressions(spec config.ValueSpec) error {
+	switch v := spec.(type) {
+	case config.DynamicValue:
+		if err := config.CompileExpression(v.Language, v.Expression); err != nil {
+			if v.Language == "js" || v.Language == "javascript" || v.Language == "" {
+				return fmt.Errorf("invalid JS expression '%s': %w", v.Expression, err)
+			}
+			return fmt.Errorf("invalid %s expression '%s': %w", v.Language, v.Expression, err)
+		}
+	case config.InterpolatedValue:
+		for _, part := range v.Parts {
+			if err := precompileExpressions(part); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // GenerateEventID generates a unique ID for an event
 func GenerateEventID() string {
 	return fmt.Sprintf("evt_%d", time.Now().UnixNano())
@@ -84,8 +176,9 @@ func RegisterDynamicAPIServices(serviceRegistry *ServiceRegistry) error {
 	for _, serviceName := range serviceRegistry.List() {
 		// Capture serviceName for the closure
 		svcName := serviceName
+		serviceDef, _ := serviceRegistry.Get(svcName)
 
-		RegisterStepType(svcName, func(cfg map[string]any) (models.Step, error) {
+		RegisterStepTypeWithMetadata(serviceStepMetadata(serviceDef), func(cfg map[string]any) (models.Step, error) {
 			// Get the service definition
 			serviceDef, exists := serviceRegistry.Get(svcName)
 			if !exists {
@@ -108,7 +201,7 @@ func RegisterDynamicAPIServices(serviceRegistry *ServiceRegistry) error {
 			// Use all parameters from config except 'operation'
 			valueContext := make(map[string]config.ValueSpec)
 			for k, v := range cfg {
-				if k != "operation" {
+				if k != "operation" && !isReservedKey(k) {
 					valueContext[k] = ParseConfigValue(v)
 				}
 			}
@@ -166,6 +259,9 @@ func RegisterDynamicAPIServices(serviceRegistry *ServiceRegistry) error {
 			if bodySpec != nil {
 				httpConfig["body"] = bodySpec
 			}
+			if client, ok := cfg[HTTPClientKey]; ok {
+				httpConfig[HTTPClientKey] = client
+			}
 
 			// Create HTTPClientStep using the registered factory
 			return CreateStep("http_client", httpConfig)
@@ -175,6 +271,141 @@ func RegisterDynamicAPIServices(serviceRegistry *ServiceRegistry) error {
 	return nil
 }
 
+// serviceStepMetadata synthesises the metadata of a service step type from its definition:
+// the step accepts an operation, and each operation the global parameters plus its own
+func serviceStepMetadata(def *config.ServiceDefinition) config.StepMetadata {
+	meta := config.StepMetadata{
+		Name:        def.Service.Name,
+		Category:    "service",
+		Description: def.Service.Description,
+		Inputs: []config.InputMeta{
+			{Name: "operation", Type: "string", Required: true, Description: "Operation of the service"},
+		},
+		Ports: []string{"default"},
+	}
+
+	opNames := make([]string, 0, len(def.Operations))
+	for name := range def.Operations {
+		opNames = append(opNames, name)
+	}
+	sort.Strings(opNames)
+
+	for _, name := range opNames {
+		op := def.Operations[name]
+		// Operation parameters override the global ones with the same name
+		params := make(map[string]config.ParameterDef, len(def.GlobalParams)+len(op.Params))
+		for paramName, param := range def.GlobalParams {
+			params[paramName] = param
+		}
+		for paramName, param := range op.Params {
+			params[paramName] = param
+		}
+
+		paramNames := make([]string, 0, len(params))
+		for paramName := range params {
+			paramNames = append(paramNames, paramName)
+		}
+		sort.Strings(paramNames)
+
+		inputs := make([]config.InputMeta, 0, len(paramNames))
+		for _, paramName := range paramNames {
+			inputs = append(inputs, parameterInputMeta(paramName, params[paramName]))
+		}
+		// The variables of the templates (path, headers, auth) are configuration keys too
+		for _, variable := range templateVariables(def, &op) {
+			if _, declared := params[variable]; !declared {
+				inputs = append(inputs, config.InputMeta{Name: variable, Type: "any", Description: "Template variable"})
+			}
+		}
+
+		meta.Operations = append(meta.Operations, config.OperationMeta{
+			Name:        name,
+			Description: op.Description,
+			Method:      op.Method,
+			Path:        op.Path,
+			Inputs:      inputs,
+		})
+	}
+	return meta
+}
+
+// templateVariable matches a variable of a service template ("{{.item_id}}")
+var templateVariable = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// templateVariables returns the parameters read by the templates of an operation, sorted:
+// base_url, authentication, headers, path, query parameters and body (as stepgen does for the schema)
+func templateVariables(def *config.ServiceDefinition, op *config.OperationDef) []string {
+	used := make(map[string]bool)
+	collectTemplateVariables(def.Defaults.BaseURL, used)
+	collectTemplateVariables(def.Defaults.Headers, used)
+	if auth := def.Defaults.Auth; auth != nil {
+		collectTemplateVariables([]any{auth.Value, auth.Username, auth.Password}, used)
+	}
+	collectTemplateVariables(op.Path, used)
+	collectTemplateVariables(op.Headers, used)
+	collectTemplateVariables(op.QueryParams, used)
+	collectTemplateVariables(op.Body, used)
+
+	variables := make([]string, 0, len(used))
+	for name := range used {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// collectTemplateVariables adds to used the parameters referenced by a template value
+// ("{{.name}}" in strings, "$param" and "$for_each" in body structures)
+func collectTemplateVariables(value any, used map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		for _, match := range templateVariable.FindAllStringSubmatch(v, -1) {
+			used[match[1]] = true
+		}
+	case map[string]string:
+		for _, item := range v {
+			collectTemplateVariables(item, used)
+		}
+	case map[string]any:
+		for key, item := range v {
+			if name, ok := item.(string); ok && (key == "$param" || key == "$for_each") {
+				used[name] = true
+				continue
+			}
+			collectTemplateVariables(item, used)
+		}
+	case []any:
+		for _, item := range v {
+			collectTemplateVariables(item, used)
+		}
+	}
+}
+
+// parameterInputMeta describes a service parameter as a configuration key
+// A parameter with a default never has to be set, even when declared $required
+func parameterInputMeta(name string, param config.ParameterDef) config.InputMeta {
+	input := config.InputMeta{
+		Name:        name,
+		Type:        param.Type,
+		Required:    param.IsRequired() && param.Default == nil,
+		Description: param.Description,
+	}
+	if input.Type == "" {
+		input.Type = "any"
+	}
+	switch def := param.Default.(type) {
+	case nil:
+	case string:
+		input.Default = def
+	default:
+		// Objects and arrays are shown as JSON
+		if data, err := json.Marshal(def); err == nil {
+			input.Default = string(data)
+		}
+	}
+	return input
+}
+
 // buildURLSpec builds a config.ValueSpec for the complete URL (base_url + path + query params)
 func buildURLSpec(serviceDef *config.ServiceDefinition, opDef *config.OperationDef, context map[string]config.ValueSpec) (config.ValueSpec, error) {
 	// Check if there are dynamic values in the context
@@ -492,10 +723,12 @@ func jsStringLiteral(s string) string {
 
 // parseConfigValue converts a configuration value to config.ValueSpec
 // Recognizes special prefixes:
-// - "$js:" for dynamic JavaScript expressions
+// - "$js:" for dynamic JavaScript expressions ("$tmpl:" and "$<lang>:" for the other evaluators)
 // - "$var:" for global variable references
 // - "$secret:" for global secret references
 // - "$env:" for environment variable references
+// - "$path:" for path expressions on the stage inputs (see config.PathValue)
+// - "${{ expr }}" for expressions inside a string (see ParseInterpolation)
 func ParseConfigValue(v any) config.ValueSpec {
 	// If it's already a ValueSpec, return it as-is (idempotent)
 	if vs, ok := v.(config.ValueSpec); ok {
@@ -504,16 +737,6 @@ func ParseConfigValue(v any) config.ValueSpec {
 
 	// If it's a string, check for special prefixes
 	if str, ok := v.(string); ok {
-		// Check for $js: prefix (dynamic JavaScript expression)
-		if strings.HasPrefix(str, "$js:") {
-			expr := strings.TrimPrefix(str, "$js:")
-			expr = strings.TrimSpace(expr)
-			return config.DynamicValue{
-				Language:   "js",
-				Expression: expr,
-			}
-		}
-
 		// Check for $var: prefix (global variable reference)
 		if strings.HasPrefix(str, "$var:") {
 			varName := strings.TrimPrefix(str, "$var:")
@@ -534,6 +757,31 @@ func ParseConfigValue(v any) config.ValueSpec {
 			envName = strings.TrimSpace(envName)
 			return config.EnvReference{Name: envName}
 		}
+
+		// Check for $path: prefix (path expression evaluated in Go)
+		if strings.HasPrefix(str, "$path:") {
+			expr := strings.TrimSpace(strings.TrimPrefix(str, "$path:"))
+			if path, err := config.ParsePath(expr); err == nil {
+				return path
+			}
+			// Invalid path: the error is reported by Resolve
+			return config.PathValue{Expression: expr}
+		}
+
+		// Check for $<lang>: prefix (expression of a registered evaluator, e.g. $js:, $tmpl:)
+		if lang, expr, ok := config.CutLanguagePrefix(str); ok {
+			return config.DynamicValue{
+				Language:   lang,
+				Expression: strings.TrimSpace(expr),
+			}
+		}
+
+		// Check for ${{ }} inline expressions (invalid ones are kept as literal text)
+		if hasInterpolation(str) {
+			if spec, err := ParseInterpolation(str); err == nil {
+				return spec
+			}
+		}
 	}
 
 	// Otherwise it's a static value
@@ -1,6 +1,7 @@
 package builder
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/simon020286/go-pipeline/config"
@@ -76,6 +77,23 @@ func TestParseConfigValue_DynamicJSWithSpaces(t *testing.T) {
 	}
 }
 
+func TestParseConfigValue_Path(t *testing.T) {
+	result := ParseConfigValue("$path: fetch.Body.items[*].id")
+
+	path, ok := result.(config.PathValue)
+	if !ok {
+		t.Fatalf("Expected PathValue, got %T", result)
+	}
+	if path.Expression != "fetch.Body.items[*].id" {
+		t.Errorf("Expected trimmed expression, got '%s'", path.Expression)
+	}
+
+	// Syntax errors are reported when the step config is preprocessed
+	if _, err := preprocessStepConfig(map[string]any{"value": "$path: fetch..Body"}); err == nil || !strings.Contains(err.Error(), "value: invalid path") {
+		t.Errorf("Expected invalid path error, got %v", err)
+	}
+}
+
 func TestParseConfigValue_Bool(t *testing.T) {
 	result := ParseConfigValue(true)
 
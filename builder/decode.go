@@ -0,0 +1,329 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+// FieldError is a problem with a configuration key found by DecodeConfig
+type FieldError struct {
+	Path    string // Key of the value, e.g. "url", "headers.Accept" or "fields[2].name"
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ConfigErrors are all the problems found by DecodeConfig
+type ConfigErrors []FieldError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+var (
+	valueSpecType = reflect.TypeOf((*config.ValueSpec)(nil)).Elem()
+	durationType  = reflect.TypeOf(time.Duration(0))
+)
+
+// DecodeConfig fills the struct pointed to by target from a step configuration, using the
+// step tags of its fields (the ones read by stepgen):
+//   - name=key: configuration key (default: the field name in snake_case)
+//   - required: the key must be set to a non-null value
+//   - default=value: value used when the key is not set
+//   - type and desc only document the field
+//
+// Fields of type config.ValueSpec stay dynamic: expressions are kept as they are and other
+// values become static values. The other fields need static values, converted to the type of
+// the field (strings, numbers, bools, time.Duration from strings like "5s", slices, maps and
+// structs, whose fields are decoded with their own step tags).
+// Keys without a field, like the reserved "$" keys, are ignored; the problems of all the
+// fields are returned together as ConfigErrors
+func DecodeConfig(cfg map[string]any, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("DecodeConfig: target must be a pointer to a struct, got %T", target)
+	}
+
+	var errs ConfigErrors
+	decodeStruct(cfg, v.Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// stepTag is the parsed step tag of a field
+type stepTag struct {
+	name       string
+	required   bool
+	def        string
+	hasDefault bool
+}
+
+// parseStepTag parses a step tag; desc is the last option, so it can contain commas
+func parseStepTag(field reflect.StructField) stepTag {
+	tag := stepTag{name: snakeCase(field.Name)}
+	raw := field.Tag.Get("step")
+	for raw != "" {
+		var part string
+		if strings.HasPrefix(raw, "desc=") {
+			break
+		}
+		part, raw, _ = strings.Cut(raw, ",")
+		switch {
+		case part == "required":
+			tag.required = true
+		case strings.HasPrefix(part, "name="):
+			tag.name = strings.TrimPrefix(part, "name=")
+		case strings.HasPrefix(part, "default="):
+			tag.def, tag.hasDefault = strings.TrimPrefix(part, "default="), true
+		}
+	}
+	return tag
+}
+
+// decodeStruct decodes the keys of m into the exported fields of the struct v
+func decodeStruct(m map[string]any, v reflect.Value, prefix string, errs *ConfigErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("step") == "-" {
+			continue
+		}
+
+		tag := parseStepTag(field)
+		path := prefix + tag.name
+		raw := m[tag.name]
+		if raw == nil {
+			switch {
+			case tag.required:
+				*errs = append(*errs, FieldError{Path: path, Message: "required"})
+				continue
+			case tag.hasDefault:
+				value, err := parseDefault(tag.def, field.Type)
+				if err != nil {
+					*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf("invalid default %q: %v", tag.def, err)})
+					continue
+				}
+				raw = value
+			default:
+				continue
+			}
+		}
+
+		if value, ok := decodeValue(raw, field.Type, path, errs); ok {
+			v.Field(i).Set(value)
+		}
+	}
+}
+
+// decodeValue converts raw to a value of type t
+func decodeValue(raw any, t reflect.Type, path string, errs *ConfigErrors) (reflect.Value, bool) {
+	fail := func(format string, args ...any) (reflect.Value, bool) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+		return reflect.Value{}, false
+	}
+
+	if t == valueSpecType {
+		return reflect.ValueOf(ParseConfigValue(raw)), true
+	}
+	if t.Kind() == reflect.Interface {
+		if raw == nil {
+			return reflect.Zero(t), true
+		}
+		return reflect.ValueOf(raw), true
+	}
+
+	// Gli altri campi richiedono valori statici
+	if spec, ok := raw.(config.ValueSpec); ok {
+		value, static := spec.GetStaticValue()
+		if !static {
+			return fail("must be a static value, not an expression or reference")
+		}
+		raw = value
+	}
+	if raw == nil {
+		return reflect.Zero(t), true
+	}
+	rv := reflect.ValueOf(raw)
+	if rv.Type().AssignableTo(t) {
+		return rv, true
+	}
+
+	switch {
+	case t == durationType:
+		s, ok := raw.(string)
+		if !ok {
+			return fail("expected a duration like 500ms or 5s, got %T", raw)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fail("invalid duration %q", s)
+		}
+		return reflect.ValueOf(d), true
+
+	case t.Kind() == reflect.String:
+		if rv.Kind() != reflect.String {
+			return fail("expected a string, got %T", raw)
+		}
+		return rv.Convert(t), true
+
+	case t.Kind() == reflect.Bool:
+		if rv.Kind() != reflect.Bool {
+			return fail("expected a boolean, got %T", raw)
+		}
+		return rv.Convert(t), true
+
+	case isInt(t.Kind()) || isUint(t.Kind()):
+		var n int64
+		switch {
+		case isInt(rv.Kind()):
+			n = rv.Int()
+		case isUint(rv.Kind()):
+			n = int64(rv.Uint())
+		case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+			f := rv.Float()
+			if f != float64(int64(f)) {
+				return fail("expected an integer, got %v", f)
+			}
+			n = int64(f)
+		default:
+			return fail("expected an integer, got %T", raw)
+		}
+		result := reflect.New(t).Elem()
+		if isUint(t.Kind()) {
+			if n < 0 || result.OverflowUint(uint64(n)) {
+				return fail("%d out of range", n)
+			}
+			result.SetUint(uint64(n))
+		} else {
+			if result.OverflowInt(n) {
+				return fail("%d out of range", n)
+			}
+			result.SetInt(n)
+		}
+		return result, true
+
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		result := reflect.New(t).Elem()
+		switch {
+		case isInt(rv.Kind()):
+			result.SetFloat(float64(rv.Int()))
+		case isUint(rv.Kind()):
+			result.SetFloat(float64(rv.Uint()))
+		case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+			result.SetFloat(rv.Float())
+		default:
+			return fail("expected a number, got %T", raw)
+		}
+		return result, true
+
+	case t.Kind() == reflect.Slice:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fail("expected a list, got %T", raw)
+		}
+		result := reflect.MakeSlice(t, rv.Len(), rv.Len())
+		ok := true
+		for i := 0; i < rv.Len(); i++ {
+			item, itemOK := decodeValue(rv.Index(i).Interface(), t.Elem(), fmt.Sprintf("%s[%d]", path, i), errs)
+			if itemOK {
+				result.Index(i).Set(item)
+			}
+			ok = ok && itemOK
+		}
+		return result, ok
+
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return fail("expected a map, got %T", raw)
+		}
+		result := reflect.MakeMapWithSize(t, rv.Len())
+		ok := true
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			item, itemOK := decodeValue(iter.Value().Interface(), t.Elem(), path+"."+key, errs)
+			if itemOK {
+				result.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), item)
+			}
+			ok = ok && itemOK
+		}
+		return result, ok
+
+	case t.Kind() == reflect.Struct:
+		m, isMap := raw.(map[string]any)
+		if !isMap {
+			return fail("expected a map, got %T", raw)
+		}
+		before := len(*errs)
+		result := reflect.New(t).Elem()
+		decodeStruct(m, result, path+".", errs)
+		return result, len(*errs) == before
+
+	case t.Kind() == reflect.Pointer:
+		elem, ok := decodeValue(raw, t.Elem(), path, errs)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		result := reflect.New(t.Elem())
+		result.Elem().Set(elem)
+		return result, true
+	}
+
+	return fail("unsupported value %T for a field of type %s", raw, t)
+}
+
+// parseDefault converts the default of a step tag to the type of the field
+func parseDefault(s string, t reflect.Type) (any, error) {
+	switch {
+	case t == valueSpecType || t.Kind() == reflect.Interface || t.Kind() == reflect.String || t == durationType:
+		return s, nil
+	case t.Kind() == reflect.Bool:
+		return strconv.ParseBool(s)
+	case isInt(t.Kind()) || isUint(t.Kind()):
+		return strconv.ParseInt(s, 10, 64)
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("defaults are not supported for %s", t)
+}
+
+func isInt(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUint(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uint64
+}
+
+// snakeCase converts a field name to its configuration key: ContentType -> content_type,
+// URL -> url, HTTPClient -> http_client
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			lowerBefore := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			acronymEnd := i > 0 && unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if lowerBefore || acronymEnd {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+type decodeHeader struct {
+	Name  string           `step:"required"`
+	Value config.ValueSpec `step:"required"`
+}
+
+type decodeTarget struct {
+	URL         config.ValueSpec            `step:"required,type=string,desc=The URL, with commas, to call"`
+	Method      config.ValueSpec            `step:"default=GET"`
+	Headers     map[string]config.ValueSpec `step:"desc=Headers"`
+	ContentType string                      `step:"name=content_type,default=application/json"`
+	Retries     int                         `step:"default=3"`
+	Ratio       float64
+	Enabled     bool          `step:"default=true"`
+	Timeout     time.Duration `step:"default=5s"`
+	Tags        []string
+	Extra       []decodeHeader
+	Payload     any
+	Limits      *struct {
+		Max int `step:"required"`
+	}
+	internal string
+}
+
+func TestDecodeConfig(t *testing.T) {
+	cfg, err := preprocessStepConfig(map[string]any{
+		"url":       "$var:base_url",
+		"headers":   map[string]any{"Authorization": "Bearer ${{ $secret:token }}", "Accept": "application/json"},
+		"ratio":     1,
+		"tags":      []any{"a", "b"},
+		"extra":     []any{map[string]any{"name": "x", "value": "$js: 1 + 1"}},
+		"payload":   map[string]any{"n": 1},
+		"limits":    map[string]any{"max": 10.0},
+		"retries":   nil, // null: si usa il default
+		"$base_dir": "/tmp",
+		"unknown":   true,
+	})
+	if err != nil {
+		t.Fatalf("preprocessStepConfig failed: %v", err)
+	}
+
+	var target decodeTarget
+	if err := DecodeConfig(cfg, &target); err != nil {
+		t.Fatalf("DecodeConfig failed: %v", err)
+	}
+
+	if target.URL != (config.VariableReference{Name: "base_url"}) {
+		t.Errorf("Expected url to stay a variable reference, got %#v", target.URL)
+	}
+	if method, ok := target.Method.GetStaticValue(); !ok || method != "GET" {
+		t.Errorf("Expected the default method GET, got %#v", target.Method)
+	}
+	if _, ok := target.Headers["Authorization"].(config.InterpolatedValue); !ok {
+		t.Errorf("Expected an interpolated header, got %#v", target.Headers["Authorization"])
+	}
+	if accept, _ := target.Headers["Accept"].GetStaticValue(); accept != "application/json" {
+		t.Errorf("Expected a static Accept header, got %#v", target.Headers["Accept"])
+	}
+	if target.ContentType != "application/json" || target.Retries != 3 || target.Ratio != 1 || !target.Enabled || target.Timeout != 5*time.Second {
+		t.Errorf("Unexpected scalar fields: %+v", target)
+	}
+	if !reflect.DeepEqual(target.Tags, []string{"a", "b"}) {
+		t.Errorf("Unexpected tags: %v", target.Tags)
+	}
+	if len(target.Extra) != 1 || target.Extra[0].Name != "x" {
+		t.Fatalf("Unexpected extra: %+v", target.Extra)
+	}
+	if _, ok := target.Extra[0].Value.(config.DynamicValue); !ok {
+		t.Errorf("Expected a dynamic value, got %#v", target.Extra[0].Value)
+	}
+	if !reflect.DeepEqual(target.Payload, map[string]any{"n": 1}) {
+		t.Errorf("Unexpected payload: %v", target.Payload)
+	}
+	if target.Limits == nil || target.Limits.Max != 10 {
+		t.Errorf("Unexpected limits: %+v", target.Limits)
+	}
+}
+
+func TestDecodeConfig_Errors(t *testing.T) {
+	cfg, err := preprocessStepConfig(map[string]any{
+		"content_type": 5,
+		"retries":      1.5,
+		"enabled":      "$var:flag",
+		"timeout":      "soon",
+		"tags":         []any{"a", 2},
+		"extra":        []any{map[string]any{"value": 1}, "x"},
+		"headers":      "none",
+		"limits":       map[string]any{},
+	})
+	if err != nil {
+		t.Fatalf("preprocessStepConfig failed: %v", err)
+	}
+
+	err = DecodeConfig(cfg, &decodeTarget{})
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ConfigErrors, got %v", err)
+	}
+
+	expected := []FieldError{
+		{"url", "required"},
+		{"headers", "expected a map, got string"},
+		{"content_type", "expected a string, got int"},
+		{"retries", "expected an integer, got 1.5"},
+		{"enabled", "must be a static value, not an expression or reference"},
+		{"timeout", `invalid duration "soon"`},
+		{"tags[1]", "expected a string, got int"},
+		{"extra[0].name", "required"},
+		{"extra[1]", "expected a map, got string"},
+		{"limits.max", "required"},
+	}
+	if !reflect.DeepEqual([]FieldError(errs), expected) {
+		t.Errorf("Unexpected errors:\n got %v\nwant %v", errs, ConfigErrors(expected))
+	}
+
+	if err := DecodeConfig(map[string]any{}, decodeTarget{}); err == nil {
+		t.Error("Expected an error for a non-pointer target")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"URL":              "url",
+		"ContentType":      "content_type",
+		"MaxExecutionTime": "max_execution_time",
+		"HTTPClient":       "http_client",
+		"UserID":           "user_id",
+		"Ms":               "ms",
+	}
+	for input, want := range tests {
+		if got := snakeCase(input); got != want {
+			t.Errorf("snakeCase(%q) = %q, expected %q", input, got, want)
+		}
+	}
+}
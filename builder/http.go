@@ -0,0 +1,103 @@
+package builder
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+// HTTPClientKey is the step configuration key holding the *http.Client of the pipeline
+// Set by WithHTTPClient; steps making HTTP requests read it with HTTPClient
+const HTTPClientKey = "$http_client"
+
+// DefaultHTTPTimeout is the request timeout when the pipeline does not set one
+const DefaultHTTPTimeout = 30 * time.Second
+
+// defaultHTTPClient is used by steps of pipelines without HTTP settings
+var defaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// NewHTTPClient creates the HTTP client of a pipeline from its settings (nil = defaults)
+func NewHTTPClient(cfg *config.HTTPConfig) (*http.Client, error) {
+	if cfg == nil {
+		return defaultHTTPClient, nil
+	}
+
+	timeout := DefaultHTTPTimeout
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http timeout: %w", err)
+		}
+		timeout = parsed
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.TLS != nil {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+func newTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("tls cert_file and key_file must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// WithHTTPClient returns a copy of stepConfig with HTTPClientKey set to client
+func WithHTTPClient(stepConfig map[string]any, client *http.Client) map[string]any {
+	return withReservedKey(stepConfig, HTTPClientKey, client)
+}
+
+// HTTPClient returns the HTTP client of a step configuration, or the default client
+func HTTPClient(stepConfig map[string]any) *http.Client {
+	if client, ok := stepConfig[HTTPClientKey].(*http.Client); ok && client != nil {
+		return client
+	}
+	return defaultHTTPClient
+}
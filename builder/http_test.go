@@ -0,0 +1,105 @@
+package builder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+func TestNewHTTPClient_Defaults(t *testing.T) {
+	client, err := NewHTTPClient(nil)
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if client != HTTPClient(nil) {
+		t.Error("Expected the default client without HTTP settings")
+	}
+
+	client, err = NewHTTPClient(&config.HTTPConfig{Timeout: "5s"})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if client.Timeout != 5*time.Second {
+		t.Errorf("Expected timeout 5s, got %s", client.Timeout)
+	}
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Un proxy HTTP riceve l'URL assoluto della richiesta
+		proxied = r.URL.String()
+		w.Write([]byte("from proxy"))
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(&config.HTTPConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	resp, err := client.Get("http://upstream.invalid/items")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://upstream.invalid/items" {
+		t.Errorf("Expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestNewHTTPClient_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// Senza la CA del server la verifica fallisce
+	client, err := NewHTTPClient(&config.HTTPConfig{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("Expected a certificate error without the server CA")
+	}
+
+	client, err = NewHTTPClient(&config.HTTPConfig{TLS: &config.TLSConfig{InsecureSkipVerify: true}})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request with insecure_skip_verify failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewHTTPClient_Errors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  *config.HTTPConfig
+	}{
+		{"invalid timeout", &config.HTTPConfig{Timeout: "soon"}},
+		{"invalid proxy", &config.HTTPConfig{Proxy: "://proxy"}},
+		{"missing CA file", &config.HTTPConfig{TLS: &config.TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}}},
+		{"CA file without certificates", &config.HTTPConfig{TLS: &config.TLSConfig{CAFile: notPEM}}},
+		{"cert without key", &config.HTTPConfig{TLS: &config.TLSConfig{CertFile: notPEM}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPClient(tt.cfg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package builder
 import (
 	"embed"
 	"fmt"
-	"log"
+	"sync"
 )
 
 //go:embed services/*.yaml
@@ -12,31 +12,70 @@ var embeddedServices embed.FS
 // globalServiceRegistry is the global service registry
 var globalServiceRegistry *ServiceRegistry
 
+// ServiceLoadMessage is a message about loading the global services
+// The messages are kept instead of being printed, so that programs decide whether to show them
+type ServiceLoadMessage struct {
+	Warning bool // A service, a directory or the registration failed
+	Message string
+}
+
+var (
+	serviceLoadMessages []ServiceLoadMessage
+	serviceLoadMu       sync.Mutex
+)
+
+// ServiceLoadMessages returns the messages of the last load of the global services
+// (at startup or by ReloadServices)
+func ServiceLoadMessages() []ServiceLoadMessage {
+	serviceLoadMu.Lock()
+	defer serviceLoadMu.Unlock()
+	return append([]ServiceLoadMessage(nil), serviceLoadMessages...)
+}
+
+func setServiceLoadMessages(messages []ServiceLoadMessage) {
+	serviceLoadMu.Lock()
+	defer serviceLoadMu.Unlock()
+	serviceLoadMessages = messages
+}
+
 func init() {
 	// Initialize the global registry
 	globalServiceRegistry = NewServiceRegistry()
 
+	var messages []ServiceLoadMessage
+	info := func(format string, args ...any) {
+		messages = append(messages, ServiceLoadMessage{Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(format string, args ...any) {
+		messages = append(messages, ServiceLoadMessage{Warning: true, Message: fmt.Sprintf(format, args...)})
+	}
+
 	// Load embedded services
 	if err := globalServiceRegistry.LoadServicesFromEmbed(embeddedServices, "services"); err != nil {
-		log.Printf("Warning: failed to load embedded services: %v", err)
+		warn("failed to load embedded services: %v", err)
 	} else {
-		log.Printf("Loaded %d embedded service(s): %v", globalServiceRegistry.Count(), globalServiceRegistry.List())
+		info("Loaded %d embedded service(s): %v", globalServiceRegistry.Count(), globalServiceRegistry.List())
 	}
 
 	// Load custom services from user directory
 	customServicesPath := GetServicesPath()
 	if err := globalServiceRegistry.LoadServicesFromDirectory(customServicesPath); err != nil {
-		log.Printf("Warning: failed to load custom services from %s: %v", customServicesPath, err)
+		warn("failed to load custom services from %s: %v", customServicesPath, err)
 	} else if globalServiceRegistry.Count() > 0 {
-		log.Printf("Custom services path: %s", customServicesPath)
+		info("Custom services path: %s", customServicesPath)
+	}
+	for _, warning := range globalServiceRegistry.Warnings() {
+		warn("%s", warning)
 	}
 
 	// Register all services as step types
 	if err := RegisterDynamicAPIServices(globalServiceRegistry); err != nil {
-		log.Printf("Warning: failed to register dynamic API services: %v", err)
+		warn("failed to register dynamic API services: %v", err)
 	} else {
-		log.Printf("Registered %d dynamic API step type(s)", globalServiceRegistry.Count())
+		info("Registered %d dynamic API step type(s)", globalServiceRegistry.Count())
 	}
+
+	setServiceLoadMessages(messages)
 }
 
 // GetGlobalServiceRegistry returns the global service registry
@@ -68,6 +107,10 @@ func ReloadServices() error {
 	// Update the global registry
 	globalServiceRegistry = newRegistry
 
-	log.Printf("Reloaded %d service(s): %v", globalServiceRegistry.Count(), globalServiceRegistry.List())
+	messages := []ServiceLoadMessage{{Message: fmt.Sprintf("Reloaded %d service(s): %v", newRegistry.Count(), newRegistry.List())}}
+	for _, warning := range newRegistry.Warnings() {
+		messages = append(messages, ServiceLoadMessage{Warning: true, Message: warning})
+	}
+	setServiceLoadMessages(messages)
 	return nil
 }
@@ -0,0 +1,118 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+const (
+	interpolationOpen   = "${{"
+	interpolationEscape = "$${{" // Literal "${{"
+	interpolationClose  = "}}"
+)
+
+// hasInterpolation reports whether a string contains inline expressions (or escaped ones)
+func hasInterpolation(s string) bool {
+	return strings.Contains(s, interpolationOpen)
+}
+
+// ParseInterpolation compiles a string containing "${{ expr }}" expressions into a ValueSpec
+// An expression is JavaScript (same context as $js:), a $<lang>: expression of another evaluator
+// or a $var:, $secret:, $env: or $path: reference; "$${{" is a literal "${{". A string made of
+// a single expression keeps the expression type
+func ParseInterpolation(s string) (config.ValueSpec, error) {
+	var parts []config.ValueSpec
+	var text strings.Builder
+	expressions := 0
+
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], interpolationEscape) {
+			text.WriteString(interpolationOpen)
+			i += len(interpolationEscape)
+			continue
+		}
+		if !strings.HasPrefix(s[i:], interpolationOpen) {
+			text.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		start := i + len(interpolationOpen)
+		end, err := findInterpolationEnd(s, start)
+		if err != nil {
+			return nil, err
+		}
+		expr := strings.TrimSpace(s[start:end])
+		if expr == "" {
+			return nil, fmt.Errorf("empty expression at offset %d in %q", i, s)
+		}
+
+		if text.Len() > 0 {
+			parts = append(parts, config.NewStaticValue(text.String()))
+			text.Reset()
+		}
+		parts = append(parts, parseInterpolationExpression(expr))
+		expressions++
+		i = end + len(interpolationClose)
+	}
+
+	if text.Len() > 0 {
+		parts = append(parts, config.NewStaticValue(text.String()))
+	}
+
+	switch {
+	case expressions == 0:
+		// Solo escape: testo statico
+		return config.NewStaticValue(text.String()), nil
+	case len(parts) == 1:
+		return parts[0], nil
+	default:
+		return config.InterpolatedValue{Parts: parts}, nil
+	}
+}
+
+// parseInterpolationExpression converts the content of ${{ }} into a ValueSpec
+func parseInterpolationExpression(expr string) config.ValueSpec {
+	if strings.HasPrefix(expr, "$var:") || strings.HasPrefix(expr, "$secret:") ||
+		strings.HasPrefix(expr, "$env:") || strings.HasPrefix(expr, "$path:") || isExpression(expr) {
+		return ParseConfigValue(expr)
+	}
+	return config.DynamicValue{Language: "js", Expression: expr}
+}
+
+// findInterpolationEnd returns the offset of the "}}" closing the expression starting at start
+// Braces and quotes of the JavaScript expression are balanced, so "${{ {a: 1}.a }}" works
+func findInterpolationEnd(s string, start int) (int, error) {
+	depth := 0
+	var quote byte
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				if strings.HasPrefix(s[i:], interpolationClose) {
+					return i, nil
+				}
+				return 0, fmt.Errorf("unbalanced '}' at offset %d in %q", i, s)
+			}
+			depth--
+		}
+	}
+	return 0, fmt.Errorf("unterminated %s expression in %q", interpolationOpen, s)
+}
@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/simon020286/go-pipeline/config"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func TestParseInterpolation_Resolve(t *testing.T) {
+	t.Setenv("DATA_DIR", "/var/data")
+	state := &models.StepInput{
+		Data: map[string]map[string]*models.Data{
+			"fetch": models.CreateDefaultResultData(map[string]any{"page": 3}),
+		},
+		GlobalVariables: map[string]any{"id": 42, "filter": map[string]any{"active": true}},
+		GlobalSecrets:   map[string]any{"token": "abc"},
+	}
+
+	tests := []struct {
+		input string
+		want  any
+	}{
+		{"https://x/${{ $vars.id }}/items", "https://x/42/items"},
+		{"${{$vars.id}}-${{ ctx.fetch.page + 1 }}", "42-4"},
+		{"Bearer ${{ $secret:token }}", "Bearer abc"},
+		{"${{ $env:DATA_DIR }}/in.csv", "/var/data/in.csv"},
+		{"q=${{ $vars.filter }}", `q={"active":true}`},
+		{"${{ {a: '}}'}.a }}!", "}}!"},
+		{"${{ $vars.id }}", int64(42)}, // A single expression keeps its type
+		{"literal $${{ not an expression }}", "literal ${{ not an expression }}"},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseInterpolation(tt.input)
+		if err != nil {
+			t.Errorf("ParseInterpolation(%q) failed: %v", tt.input, err)
+			continue
+		}
+		got, err := spec.Resolve(state)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %#v, expected %#v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseInterpolation_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"https://x/${{ $vars.id", "unterminated"},
+		{"${{ }}", "empty expression"},
+		{"${{ a } }}", "unbalanced"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseInterpolation(tt.input); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseInterpolation(%q): expected error containing %q, got %v", tt.input, tt.want, err)
+		}
+	}
+}
+
+func TestPreprocessStepConfig_Interpolation(t *testing.T) {
+	processed, err := preprocessStepConfig(map[string]any{
+		"url":     "https://api/${{ $vars.id }}",
+		"headers": map[string]any{"X-Id": "${{ $var:id }}"},
+		"plain":   "no expressions",
+	})
+	if err != nil {
+		t.Fatalf("preprocessStepConfig failed: %v", err)
+	}
+	if _, ok := processed["url"].(config.InterpolatedValue); !ok {
+		t.Errorf("Expected InterpolatedValue, got %T", processed["url"])
+	}
+	if _, ok := processed["headers"].(map[string]any)["X-Id"].(config.VariableReference); !ok {
+		t.Errorf("Expected VariableReference, got %T", processed["headers"].(map[string]any)["X-Id"])
+	}
+	if processed["plain"] != "no expressions" {
+		t.Errorf("Plain string changed: %v", processed["plain"])
+	}
+
+	if _, err := preprocessStepConfig(map[string]any{"list": []any{"${{ oops"}}); err == nil || !strings.Contains(err.Error(), "list: [0]:") {
+		t.Errorf("Expected error with the value path, got %v", err)
+	}
+}
+
+func TestPreprocessStepConfig_Evaluators(t *testing.T) {
+	processed, err := preprocessStepConfig(map[string]any{
+		"greeting": `$tmpl: Hello {{ .user.name | title }}`,
+		"inline":   `id-${{ $tmpl:{{ .user.id }} }}`,
+		"unknown":  "$python: 1 + 1",
+	})
+	if err != nil {
+		t.Fatalf("preprocessStepConfig failed: %v", err)
+	}
+
+	greeting, ok := processed["greeting"].(config.DynamicValue)
+	if !ok || greeting.Language != "tmpl" || greeting.Expression != "Hello {{ .user.name | title }}" {
+		t.Fatalf("Expected a tmpl DynamicValue, got %#v", processed["greeting"])
+	}
+	// Un prefisso senza evaluator registrato resta testo
+	if processed["unknown"] != "$python: 1 + 1" {
+		t.Errorf("Expected an unregistered language to stay a string, got %#v", processed["unknown"])
+	}
+
+	state := &models.StepInput{Data: map[string]map[string]*models.Data{
+		"user": models.CreateDefaultResultData(map[string]any{"name": "ada", "id": 7}),
+	}}
+	for key, want := range map[string]string{"greeting": "Hello Ada", "inline": "id-7"} {
+		got, err := processed[key].(config.ValueSpec).Resolve(state)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %q, got %v (%v)", key, want, got, err)
+		}
+	}
+
+	if _, err := preprocessStepConfig(map[string]any{"body": "$tmpl: {{ .user.name "}); err == nil || !strings.Contains(err.Error(), "invalid tmpl expression") {
+		t.Errorf("Expected a template syntax error when the step is created, got %v", err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 // ServiceRegistry maintains all loaded service definitions
 type ServiceRegistry struct {
 	services map[string]*config.ServiceDefinition
+	warnings []string // Files skipped by LoadServicesFromDirectory
 }
 
 // NewServiceRegistry creates a new registry
@@ -85,6 +86,7 @@ func (sr *ServiceRegistry) LoadServicesFromEmbed(embedFS embed.FS, basePath stri
 }
 
 // LoadServicesFromDirectory loads services from a filesystem directory
+// Invalid files are skipped and reported by Warnings
 func (sr *ServiceRegistry) LoadServicesFromDirectory(dirPath string) error {
 	// Check if directory exists
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
@@ -114,8 +116,8 @@ func (sr *ServiceRegistry) LoadServicesFromDirectory(dirPath string) error {
 		}
 
 		if err := sr.loadServiceFromBytes(data, entry.Name()); err != nil {
-			// Log warning but continue with other services
-			fmt.Printf("Warning: failed to load service from %s: %v\n", entry.Name(), err)
+			// Keep a warning and continue with other services
+			sr.warnings = append(sr.warnings, fmt.Sprintf("failed to load service from %s: %v", filePath, err))
 			continue
 		}
 	}
@@ -123,23 +125,42 @@ func (sr *ServiceRegistry) LoadServicesFromDirectory(dirPath string) error {
 	return nil
 }
 
+// Warnings returns the problems of the files skipped while loading services
+func (sr *ServiceRegistry) Warnings() []string {
+	return append([]string(nil), sr.warnings...)
+}
+
 // loadServiceFromBytes loads a service definition from bytes
 func (sr *ServiceRegistry) loadServiceFromBytes(data []byte, filename string) error {
+	def, err := ParseServiceDefinition(data, filename)
+	if err != nil {
+		return err
+	}
+
+	if err := sr.Register(def); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ParseServiceDefinition decodes a service definition from YAML and validates it
+// filename names the service when the definition does not (without its extension)
+func ParseServiceDefinition(data []byte, filename string) (*config.ServiceDefinition, error) {
 	var def config.ServiceDefinition
 	if err := yaml.Unmarshal(data, &def); err != nil {
-		return fmt.Errorf("failed to parse YAML: %w", err)
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
 	// If name is not specified, use the filename
 	if def.Service.Name == "" {
-		def.Service.Name = strings.TrimSuffix(filename, filepath.Ext(filename))
+		def.Service.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
 	}
 
-	if err := sr.Register(&def); err != nil {
-		return err
+	if err := def.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid service definition: %w", err)
 	}
-
-	return nil
+	return &def, nil
 }
 
 // GetServicesPath returns the path to the custom services directory
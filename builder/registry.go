@@ -2,26 +2,53 @@ package builder
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
+	"github.com/simon020286/go-pipeline/config"
 	"github.com/simon020286/go-pipeline/models"
 )
 
 // StepFactory is a function that creates a Step from a configuration
 type StepFactory func(config map[string]any) (models.Step, error)
 
+// stepRegistration is a registered step type: its factory and its metadata
+type stepRegistration struct {
+	factory StepFactory
+	meta    config.StepMetadata
+}
+
 var (
-	// registry contains all registered factories by step type
-	registry = make(map[string]StepFactory)
+	// registry contains all registered step types by name
+	registry = make(map[string]stepRegistration)
 	mu       sync.RWMutex
 )
 
 // RegisterStepType registers a factory for a step type
 // This function is called by init() in step packages
+// The metadata is taken from the stepgen registry (config.GetStepMetadata) when available
 func RegisterStepType(stepType string, factory StepFactory) {
+	meta, ok := config.GetStepMetadata(stepType)
+	if !ok {
+		// Senza metadati config.ValidatePipeline non controlla le chiavi dello step
+		meta = config.StepMetadata{Name: stepType}
+	}
+	registerStepType(meta, factory)
+}
+
+// RegisterStepTypeWithMetadata registers a factory for the step type meta.Name, described by meta
+// The metadata is also registered with config.RegisterStepMetadata, so that config.ValidatePipeline
+// checks the step_config of the stages using the step type
+func RegisterStepTypeWithMetadata(meta config.StepMetadata, factory StepFactory) {
+	config.RegisterStepMetadata(meta)
+	registerStepType(meta, factory)
+}
+
+func registerStepType(meta config.StepMetadata, factory StepFactory) {
 	mu.Lock()
 	defer mu.Unlock()
-	registry[stepType] = factory
+	registry[meta.Name] = stepRegistration{factory: factory, meta: meta}
+	config.DeclareStepType(meta.Name)
 }
 
 // GetStepFactory returns the factory for a step type
@@ -29,11 +56,24 @@ func GetStepFactory(stepType string) (StepFactory, error) {
 	mu.RLock()
 	defer mu.RUnlock()
 
-	factory, exists := registry[stepType]
+	reg, exists := registry[stepType]
 	if !exists {
 		return nil, fmt.Errorf("unknown step type: %s", stepType)
 	}
-	return factory, nil
+	return reg.factory, nil
+}
+
+// DescribeStepType returns the metadata of a registered step type
+// Step types registered without metadata are described by their name only
+func DescribeStepType(stepType string) (config.StepMetadata, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	reg, exists := registry[stepType]
+	if !exists {
+		return config.StepMetadata{}, fmt.Errorf("unknown step type: %s", stepType)
+	}
+	return reg.meta, nil
 }
 
 // ListStepTypes returns all registered step types
@@ -47,3 +87,16 @@ func ListStepTypes() []string {
 	}
 	return types
 }
+
+// DescribeStepTypes returns the metadata of all registered step types, sorted by name
+func DescribeStepTypes() []config.StepMetadata {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	metas := make([]config.StepMetadata, 0, len(registry))
+	for _, reg := range registry {
+		metas = append(metas, reg.meta)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	return metas
+}
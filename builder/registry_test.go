@@ -0,0 +1,168 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/simon020286/go-pipeline/config"
+	"github.com/simon020286/go-pipeline/models"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDescribeStepType_WithMetadata(t *testing.T) {
+	RegisterStepTypeWithMetadata(config.StepMetadata{
+		Name:       "test_described",
+		Category:   "trigger",
+		Ports:      []string{"default"},
+		Continuous: true,
+	}, func(cfg map[string]any) (models.Step, error) { return nil, nil })
+
+	meta, err := DescribeStepType("test_described")
+	if err != nil {
+		t.Fatalf("DescribeStepType failed: %v", err)
+	}
+	if meta.Category != "trigger" || !meta.Continuous || len(meta.Ports) != 1 {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if !config.IsStepTypeDeclared("test_described") {
+		t.Error("Step type should be declared for validation")
+	}
+}
+
+func TestDescribeStepType_WithoutMetadata(t *testing.T) {
+	RegisterStepType("test_undescribed", func(cfg map[string]any) (models.Step, error) { return nil, nil })
+
+	meta, err := DescribeStepType("test_undescribed")
+	if err != nil {
+		t.Fatalf("DescribeStepType failed: %v", err)
+	}
+	if meta.Name != "test_undescribed" || len(meta.Inputs) != 0 {
+		t.Errorf("Expected a name-only description, got %+v", meta)
+	}
+}
+
+func TestDescribeStepType_Unknown(t *testing.T) {
+	if _, err := DescribeStepType("does_not_exist"); err == nil {
+		t.Error("Expected error for unknown step type")
+	}
+}
+
+func TestDescribeStepType_Service(t *testing.T) {
+	sr := NewServiceRegistry()
+	err := sr.Register(&config.ServiceDefinition{
+		Service: config.ServiceInfo{Name: "test_service", Description: "Test service"},
+		Defaults: config.ServiceDefaults{
+			BaseURL: "https://example.com",
+			Auth:    &config.AuthConfig{Type: "bearer", Header: "Authorization", Value: "Bearer {{.api_key}}"},
+		},
+		GlobalParams: map[string]config.ParameterDef{
+			"token": {Required: true, Type: "string"},
+		},
+		Operations: map[string]config.OperationDef{
+			"search": {
+				Description: "Search items",
+				Method:      "GET",
+				Path:        "/search",
+				Params: map[string]config.ParameterDef{
+					"query": {Required: true, Type: "string", Description: "Search terms"},
+					"limit": {Required: true, Type: "int", Default: 10},
+				},
+			},
+			"create": {Method: "POST", Path: "/lists/{{ .list_id }}/items"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := RegisterDynamicAPIServices(sr); err != nil {
+		t.Fatalf("RegisterDynamicAPIServices failed: %v", err)
+	}
+
+	meta, err := DescribeStepType("test_service")
+	if err != nil {
+		t.Fatalf("DescribeStepType failed: %v", err)
+	}
+	if meta.Category != "service" || meta.Description != "Test service" {
+		t.Errorf("Unexpected metadata: %+v", meta)
+	}
+	if input, ok := meta.Input("operation"); !ok || !input.Required {
+		t.Error("Expected required operation input")
+	}
+	if len(meta.Operations) != 2 || meta.Operations[0].Name != "create" {
+		t.Fatalf("Expected sorted operations, got %+v", meta.Operations)
+	}
+
+	search, ok := meta.Operation("search")
+	if !ok {
+		t.Fatal("Expected search operation")
+	}
+	if search.Method != "GET" || search.Path != "/search" {
+		t.Errorf("Unexpected operation: %+v", search)
+	}
+	if len(search.Inputs) != 4 {
+		t.Fatalf("Expected global and operation params, got %+v", search.Inputs)
+	}
+	expected := []config.InputMeta{
+		{Name: "limit", Type: "int", Default: "10"},
+		{Name: "query", Type: "string", Required: true, Description: "Search terms"},
+		{Name: "token", Type: "string", Required: true},
+		{Name: "api_key", Type: "any", Description: "Template variable"},
+	}
+	for i, input := range expected {
+		if search.Inputs[i] != input {
+			t.Errorf("Input %d: expected %+v, got %+v", i, input, search.Inputs[i])
+		}
+	}
+	create, _ := meta.Operation("create")
+	if len(create.Inputs) != 3 || create.Inputs[1].Name != "api_key" || create.Inputs[2].Name != "list_id" {
+		t.Errorf("Expected the template variables as inputs, got %+v", create.Inputs)
+	}
+
+	// I metadati sintetizzati sono registrati anche per ValidatePipeline
+	var cfg config.PipelineConfig
+	source := "stages:\n  - id: find\n    step_type: test_service\n    step_config:\n      operaton: search\n      query: go\n      token: abc\n"
+	if err := yaml.Unmarshal([]byte(source), &cfg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	diagnostics := config.ValidatePipeline(&cfg)
+	if len(diagnostics) != 2 || diagnostics[1].String() != "line 5, column 7: stage 'find': unknown key 'operaton' for step type 'test_service' (did you mean 'operation'?)" {
+		t.Errorf("Unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestServiceRegistry_LoadServicesFromDirectoryWarnings(t *testing.T) {
+	dir := t.TempDir()
+	valid := "service:\n  name: echo\ndefaults:\n  base_url: https://example.com\noperations:\n  get:\n    method: GET\n    path: /get\n"
+	if err := os.WriteFile(filepath.Join(dir, "echo.yaml"), []byte(valid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("service:\n  name: broken\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewServiceRegistry()
+	if err := registry.LoadServicesFromDirectory(dir); err != nil {
+		t.Fatalf("LoadServicesFromDirectory failed: %v", err)
+	}
+	if _, ok := registry.Get("echo"); !ok {
+		t.Error("Expected the valid service to be loaded")
+	}
+	warnings := registry.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "broken.yaml") {
+		t.Errorf("Expected one warning about broken.yaml, got %v", warnings)
+	}
+}
+
+func TestServiceLoadMessages(t *testing.T) {
+	var loaded bool
+	for _, message := range ServiceLoadMessages() {
+		if !message.Warning && strings.HasPrefix(message.Message, "Loaded ") {
+			loaded = true
+		}
+	}
+	if !loaded {
+		t.Errorf("Expected the startup messages to report the embedded services, got %v", ServiceLoadMessages())
+	}
+}
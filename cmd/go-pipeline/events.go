@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func (c *cli) eventsTailCommand(args []string) error {
+	fs := c.newFlagSet("events tail", "<events.jsonl>",
+		"Print the events written by 'run --events-file'. With -f, keep reading the events\n"+
+			"appended by a running pipeline until interrupted.")
+	follow := fs.Bool("f", false, "Follow the file, printing the events as they are appended")
+	types := fs.String("type", "", "Only print these event types (comma-separated, e.g. stage.error,stage.output)")
+	stages := fs.String("stage", "", "Only print the stage events of these stages (comma-separated)")
+	interval := fs.Duration("poll-interval", 250*time.Millisecond, "How often a followed file is checked for new events")
+	jsonOutput := fs.Bool("json", false, "Print the events as JSON lines on stdout")
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to open events file: %w", err)
+	}
+	defer file.Close()
+
+	filter := eventFilter{types: splitList(*types), stages: splitList(*stages)}
+	printLine := func(line []byte) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			return
+		}
+		var event models.Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			fmt.Fprintf(c.stderr, "Warning: skipping invalid event: %v\n", err)
+			return
+		}
+		if !filter.accepts(event) {
+			return
+		}
+		if *jsonOutput {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(c.stdout, "%s\n", data)
+			return
+		}
+		fmt.Fprintln(c.stdout, formatEvent(event))
+	}
+
+	// A line without its newline is kept until the writer completes it
+	reader := bufio.NewReader(file)
+	var pending []byte
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		pending = append(pending, chunk...)
+		if err == nil {
+			printLine(pending)
+			pending = pending[:0]
+			continue
+		}
+		if err != io.EOF {
+			return fmt.Errorf("failed to read events file: %w", err)
+		}
+		if !*follow {
+			printLine(pending)
+			return nil
+		}
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// eventFilter selects events by type and stage (empty lists accept everything)
+// Pipeline events are never filtered by stage, as with pipeline.ListenerOptions
+type eventFilter struct {
+	types  []string
+	stages []string
+}
+
+func (f eventFilter) accepts(event models.Event) bool {
+	if len(f.types) > 0 && !slices.Contains(f.types, string(event.Type)) {
+		return false
+	}
+	if stageID := event.StageID(); len(f.stages) > 0 && stageID != "" && !slices.Contains(f.stages, stageID) {
+		return false
+	}
+	return true
+}
+
+// formatEvent renders an event on one line: time, type and the fields of its payload
+func formatEvent(event models.Event) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %-18s", event.Timestamp.Format("2006-01-02T15:04:05.000Z07:00"), event.Type)
+	switch payload := event.Payload.(type) {
+	case models.PipelineStartedEvent:
+		fmt.Fprintf(&sb, " mode=%s", payload.Mode)
+	case models.PipelineCompletedEvent:
+		fmt.Fprintf(&sb, " duration=%v", payload.Duration)
+	case models.PipelineErrorEvent:
+		fmt.Fprintf(&sb, " error=%q", payload.Error)
+	case models.StageStartedEvent:
+		fmt.Fprintf(&sb, " stage=%s event=%s attempt=%d", payload.StageID, payload.EventID, payload.Attempt)
+	case models.StageCompletedEvent:
+		fmt.Fprintf(&sb, " stage=%s event=%s attempt=%d duration=%v", payload.StageID, payload.EventID, payload.Attempt, payload.Duration)
+	case models.StageErrorEvent:
+		fmt.Fprintf(&sb, " stage=%s event=%s attempt=%d error=%q", payload.StageID, payload.EventID, payload.Attempt, payload.Error)
+	case models.StageOutputEvent:
+		fmt.Fprintf(&sb, " stage=%s event=%s ports=%s", payload.StageID, payload.EventID, strings.Join(sortedKeys(payload.Output), ","))
+	case models.StageLogEvent:
+		fmt.Fprintf(&sb, " stage=%s event=%s level=%s message=%q", payload.StageID, payload.EventID, payload.Level, payload.Message)
+	}
+	return sb.String()
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+func (c *cli) graphCommand(args []string) error {
+	fs := c.newFlagSet("graph", "<pipeline.yaml>",
+		"Print the stage graph of a pipeline: one node per stage, one edge per dependency,\n"+
+			"labelled with the branch it filters. Render it with Graphviz (dot -Tsvg) or Mermaid.")
+	var pf pipelineFlags
+	pf.register(fs)
+	format := fs.String("format", "dot", "Graph format: dot or mermaid")
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if *format != "dot" && *format != "mermaid" {
+		return usageErrorf("unknown graph format '%s' (expected dot or mermaid)", *format)
+	}
+
+	cfg, p, err := pf.build(positional[0])
+	if err != nil {
+		return err
+	}
+	plan, err := buildPlan(cfg, p)
+	if err != nil {
+		return invalidError(err)
+	}
+
+	if *format == "mermaid" {
+		fmt.Fprint(c.stdout, mermaidGraph(plan))
+	} else {
+		fmt.Fprint(c.stdout, dotGraph(plan))
+	}
+	return nil
+}
+
+// dotGraph renders a plan in the Graphviz DOT language
+// Continuous stages are drawn as ellipses, the others as boxes
+func dotGraph(plan planResult) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n", plan.Pipeline)
+	sb.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+	for _, stage := range plan.Stages {
+		attrs := fmt.Sprintf("label=%q", stage.ID+"\n"+stage.StepType)
+		if stage.Continuous {
+			attrs += ", shape=ellipse"
+		}
+		if stage.DeadLetter {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "  %q [%s];\n", stage.ID, attrs)
+	}
+	for _, stage := range plan.Stages {
+		for _, dep := range stage.Dependencies {
+			ref := config.ParseDependency(dep)
+			if ref.Branch != "" {
+				fmt.Fprintf(&sb, "  %q -> %q [label=%q];\n", ref.StageID, stage.ID, ref.Branch)
+			} else {
+				fmt.Fprintf(&sb, "  %q -> %q;\n", ref.StageID, stage.ID)
+			}
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// mermaidGraph renders a plan as a Mermaid flowchart
+// Node IDs are numbered, since stage IDs may contain characters Mermaid does not accept
+func mermaidGraph(plan planResult) string {
+	nodes := make(map[string]string, len(plan.Stages))
+	for i, stage := range plan.Stages {
+		nodes[stage.ID] = fmt.Sprintf("s%d", i)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for _, stage := range plan.Stages {
+		label := mermaidLabel(stage.ID + "<br/>" + stage.StepType)
+		if stage.Continuous {
+			fmt.Fprintf(&sb, "  %s([\"%s\"])\n", nodes[stage.ID], label)
+		} else {
+			fmt.Fprintf(&sb, "  %s[\"%s\"]\n", nodes[stage.ID], label)
+		}
+	}
+	for _, stage := range plan.Stages {
+		for _, dep := range stage.Dependencies {
+			ref := config.ParseDependency(dep)
+			if ref.Branch != "" {
+				fmt.Fprintf(&sb, "  %s -->|%s| %s\n", nodes[ref.StageID], mermaidLabel(ref.Branch), nodes[stage.ID])
+			} else {
+				fmt.Fprintf(&sb, "  %s --> %s\n", nodes[ref.StageID], nodes[stage.ID])
+			}
+		}
+	}
+	return sb.String()
+}
+
+// mermaidLabel escapes the quotes and pipes of a Mermaid label
+func mermaidLabel(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "#124;").Replace(s)
+}
@@ -0,0 +1,235 @@
+// go-pipeline runs, checks and describes YAML pipelines.
+//
+// Usage: go-pipeline <command> [options] [arguments]
+//
+// Commands:
+//
+//	run               run a pipeline
+//	validate          check pipeline files without running them
+//	plan              show the stages of a pipeline in execution order
+//	graph             print the stage graph (Graphviz DOT or Mermaid)
+//	steps list        list the step types
+//	steps describe    describe a step type
+//	services list     list the service definitions
+//	services describe describe a service or one of its operations
+//	services validate check service definition files
+//	events tail       print the events written by run --events-file
+//
+// Exit codes: 0 success, 1 failure (a stage failed, an unknown name), 2 usage error,
+// 3 invalid pipeline or service definition, 124 timeout, 130 interrupted.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/simon020286/go-pipeline/builder"
+	_ "github.com/simon020286/go-pipeline/steps"
+)
+
+// Exit codes
+const (
+	exitOK          = 0
+	exitFailure     = 1
+	exitUsage       = 2
+	exitInvalid     = 3
+	exitTimeout     = 124
+	exitInterrupted = 130
+)
+
+// exitError is an error that ends the program with a specific exit code
+type exitError struct {
+	code     int
+	err      error
+	reported bool // Already printed (e.g. by the flag package)
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf reports a wrong invocation (exit code 2)
+func usageErrorf(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// invalidError reports an invalid pipeline or service definition (exit code 3)
+func invalidError(err error) error {
+	return &exitError{code: exitInvalid, err: err}
+}
+
+// command is a subcommand; groups (steps, services, events) have subcommands instead of run
+type command struct {
+	name        string
+	summary     string
+	run         func(c *cli, args []string) error
+	subcommands []*command
+	logsItself  bool // Reports the warnings of the service loading itself (run, in its log format)
+}
+
+var commands = []*command{
+	{name: "run", summary: "Run a pipeline", run: (*cli).runCommand, logsItself: true},
+	{name: "validate", summary: "Check pipeline files without running them", run: (*cli).validateCommand},
+	{name: "plan", summary: "Show the stages of a pipeline in execution order", run: (*cli).planCommand},
+	{name: "graph", summary: "Print the stage graph (Graphviz DOT or Mermaid)", run: (*cli).graphCommand},
+	{name: "steps", summary: "List and describe step types", subcommands: []*command{
+		{name: "list", summary: "List the step types", run: (*cli).stepsListCommand},
+		{name: "describe", summary: "Describe a step type", run: (*cli).stepsDescribeCommand},
+	}},
+	{name: "services", summary: "List, describe and check service definitions", subcommands: []*command{
+		{name: "list", summary: "List the service definitions", run: (*cli).servicesListCommand},
+		{name: "describe", summary: "Describe a service or one of its operations", run: (*cli).servicesDescribeCommand},
+		{name: "validate", summary: "Check service definition files", run: (*cli).servicesValidateCommand},
+	}},
+	{name: "events", summary: "Read pipeline events", subcommands: []*command{
+		{name: "tail", summary: "Print the events written by run --events-file", run: (*cli).eventsTailCommand},
+	}},
+}
+
+// cli holds the streams of the program, so that commands can be tested
+type cli struct {
+	ctx     context.Context
+	stdout  io.Writer
+	stderr  io.Writer
+	jsonLog *slog.Logger // Set by run --log-format json: errors are logged as JSON lines too
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	c := &cli{ctx: ctx, stdout: os.Stdout, stderr: os.Stderr}
+	code := c.main(os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// main runs the command of args and returns the exit code
+func (c *cli) main(args []string) int {
+	err := c.dispatch(commands, "go-pipeline", args)
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+
+	code := exitFailure
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.code
+		if exitErr.reported {
+			return code
+		}
+	}
+	if c.jsonLog != nil {
+		c.jsonLog.Error(err.Error(), "exit_code", code)
+	} else {
+		fmt.Fprintf(c.stderr, "Error: %v\n", err)
+	}
+	return code
+}
+
+// dispatch finds the command named by args[0] among cmds and runs it
+func (c *cli) dispatch(cmds []*command, prefix string, args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		c.printCommands(cmds, prefix)
+		if len(args) == 0 {
+			return usageErrorf("missing command")
+		}
+		return nil
+	}
+
+	for _, cmd := range cmds {
+		if cmd.name != args[0] {
+			continue
+		}
+		if cmd.subcommands != nil {
+			return c.dispatch(cmd.subcommands, prefix+" "+cmd.name, args[1:])
+		}
+		if !cmd.logsItself {
+			c.printServiceWarnings()
+		}
+		return cmd.run(c, args[1:])
+	}
+
+	c.printCommands(cmds, prefix)
+	return usageErrorf("unknown command '%s'", strings.TrimPrefix(prefix+" "+args[0], "go-pipeline "))
+}
+
+// printServiceWarnings prints the warnings of the service loading (see builder.ServiceLoadMessages)
+func (c *cli) printServiceWarnings() {
+	for _, message := range builder.ServiceLoadMessages() {
+		if message.Warning {
+			fmt.Fprintf(c.stderr, "Warning: %s\n", message.Message)
+		}
+	}
+}
+
+func (c *cli) printCommands(cmds []*command, prefix string) {
+	fmt.Fprintf(c.stderr, "Usage: %s <command> [options] [arguments]\n\nCommands:\n", prefix)
+	for _, cmd := range cmds {
+		fmt.Fprintf(c.stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(c.stderr, "\nRun '%s <command> -h' for the options of a command.\n", prefix)
+}
+
+// newFlagSet returns the flag set of a command, printing its usage on stderr
+func (c *cli) newFlagSet(name, arguments, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: go-pipeline %s [options] %s\n\n%s\n", name, arguments, description)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(c.stderr, "\nOptions:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parseFlags parses args, accepting flags after the positional arguments too,
+// and checks the number of positional arguments (max < 0: no limit)
+func parseFlags(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &exitError{code: exitUsage, err: err, reported: true}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) < min || (max >= 0 && len(positional) > max) {
+		fs.Usage()
+		return nil, usageErrorf("%s: wrong number of arguments", fs.Name())
+	}
+	return positional, nil
+}
+
+// printJSON writes value to stdout as indented JSON
+func (c *cli) printJSON(value any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const invokePipeline = `name: invoke
+variables:
+  greeting: hello
+inputs:
+  n:
+    $type: int
+    $required: true
+outputs:
+  doubled: calc
+  message: say
+stages:
+  - id: calc
+    step_type: js
+    step_config:
+      code: "return ctx.$inputs.n * 2"
+  - id: say
+    step_type: map
+    step_config:
+      fields:
+        - name: text
+          value: "$var:greeting"
+    dependencies: [calc]
+`
+
+const branchPipeline = `name: branches
+stages:
+  - id: check
+    step_type: if
+    step_config:
+      condition: "true"
+  - id: on_true
+    step_type: delay
+    step_config:
+      ms: 1
+    dependencies: ["check:true"]
+  - id: on_false
+    step_type: delay
+    step_config:
+      ms: 1
+    dependencies: ["check:false"]
+  - id: done
+    step_type: delay
+    step_config:
+      ms: 1
+    dependencies: [on_true, on_false]
+`
+
+const brokenPipeline = `name: broken
+stages:
+  - id: calc
+    step_type: js
+    step_config:
+      code: "return 1"
+    dependencies: [missing]
+`
+
+// runCLI runs the program with args and returns its exit code, stdout and stderr
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	c := &cli{ctx: context.Background(), stdout: &stdout, stderr: &stderr}
+	code := c.main(args)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeFile writes content to a file of a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestMain_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"unknown subcommand", []string{"steps", "frobnicate"}, exitUsage},
+		{"missing argument", []string{"plan"}, exitUsage},
+		{"unknown flag", []string{"validate", "--frobnicate", "p.yaml"}, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"command help", []string{"run", "-h"}, exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLI(t, tt.args...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d (stderr: %s)", code, tt.code, stderr)
+			}
+			if !strings.Contains(stderr, "Usage: go-pipeline") {
+				t.Errorf("stderr does not contain the usage: %s", stderr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := writeFile(t, "valid.yaml", invokePipeline)
+	broken := writeFile(t, "broken.yaml", brokenPipeline)
+
+	code, stdout, _ := runCLI(t, "validate", valid)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	if !strings.Contains(stdout, valid+": ok") {
+		t.Errorf("unexpected output: %s", stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "validate", "--json", valid, broken)
+	if code != exitInvalid {
+		t.Fatalf("exit code = %d, want %d", code, exitInvalid)
+	}
+	var reports []fileReport
+	if err := json.Unmarshal([]byte(stdout), &reports); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout)
+	}
+	if len(reports) != 2 || !reports[0].Valid || reports[1].Valid {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+	if len(reports[1].Diagnostics) == 0 || reports[1].Diagnostics[0].Line != 7 ||
+		!strings.Contains(reports[1].Diagnostics[0].Message, "missing") {
+		t.Errorf("unexpected diagnostics: %+v", reports[1].Diagnostics)
+	}
+}
+
+func TestValidate_Var(t *testing.T) {
+	path := writeFile(t, "vars.yaml", `name: vars
+stages:
+  - id: say
+    step_type: map
+    step_config:
+      fields:
+        - name: text
+          value: "$var:greeting"
+`)
+
+	if code, _, _ := runCLI(t, "validate", path); code != exitInvalid {
+		t.Errorf("exit code without --var = %d, want %d", code, exitInvalid)
+	}
+	if code, _, stderr := runCLI(t, "validate", "--var", "greeting=hi", path); code != exitOK {
+		t.Errorf("exit code with --var = %d, want %d (stderr: %s)", code, exitOK, stderr)
+	}
+}
+
+func TestRun(t *testing.T) {
+	path := writeFile(t, "invoke.yaml", invokePipeline)
+
+	code, stdout, stderr := runCLI(t, "run", "--json", "--log-format", "none", "--input", "n=21", "--var", "greeting=hi", path)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d (stderr: %s)", code, exitOK, stderr)
+	}
+	var result runResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout)
+	}
+	if result.Status != statusCompleted || result.Mode != "batch" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !reflect.DeepEqual(result.Outputs["doubled"], []any{float64(42)}) {
+		t.Errorf("doubled = %v, want 42", result.Outputs["doubled"])
+	}
+	if message := result.Outputs["message"]; len(message) != 1 || message[0].(map[string]any)["text"] != "hi" {
+		t.Errorf("message = %v, want the --var value", result.Outputs["message"])
+	}
+}
+
+func TestRun_InvalidInput(t *testing.T) {
+	path := writeFile(t, "invoke.yaml", invokePipeline)
+
+	if code, _, _ := runCLI(t, "run", "--log-format", "none", "--input", "n=abc", path); code != exitUsage {
+		t.Errorf("exit code = %d, want %d", code, exitUsage)
+	}
+	if code, _, _ := runCLI(t, "run", "--log-format", "none", path); code != exitUsage {
+		t.Errorf("exit code without the required input = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestRun_JSONLog(t *testing.T) {
+	path := writeFile(t, "invoke.yaml", invokePipeline)
+	failing := writeFile(t, "failing.yaml", `name: failing
+stages:
+  - id: fail
+    step_type: js
+    step_config:
+      code: "throw new Error('boom')"
+`)
+
+	// Successful and failed runs: stderr must only contain JSON lines
+	for _, args := range [][]string{
+		{"run", "-v", "--log-format", "json", "--input", "n=1", path},
+		{"run", "--log-format", "json", failing},
+	} {
+		_, _, stderr := runCLI(t, args...)
+		lines := strings.Split(strings.TrimSpace(stderr), "\n")
+		for _, line := range lines {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Errorf("%v: stderr line is not JSON: %q", args, line)
+			}
+		}
+		if len(lines) < 2 {
+			t.Errorf("%v: expected several log records, got %q", args, stderr)
+		}
+	}
+}
+
+func TestRun_StageError(t *testing.T) {
+	path := writeFile(t, "failing.yaml", `name: failing
+stages:
+  - id: fail
+    step_type: js
+    step_config:
+      code: "throw new Error('boom')"
+`)
+
+	code, stdout, stderr := runCLI(t, "run", "--json", "--log-format", "none", path)
+	if code != exitFailure {
+		t.Fatalf("exit code = %d, want %d", code, exitFailure)
+	}
+	var result runResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout)
+	}
+	if result.Status != statusFailed || len(result.Errors) != 1 || result.Errors[0].Stage != "fail" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if !strings.Contains(stderr, "first in stage 'fail'") {
+		t.Errorf("unexpected stderr: %s", stderr)
+	}
+}
+
+func TestRun_EventsFile(t *testing.T) {
+	path := writeFile(t, "invoke.yaml", invokePipeline)
+	events := filepath.Join(t.TempDir(), "events.jsonl")
+
+	if code, _, stderr := runCLI(t, "run", "--log-format", "none", "--events-file", events, "--input", "n=1", path); code != exitOK {
+		t.Fatalf("run exit code = %d (stderr: %s)", code, stderr)
+	}
+
+	code, stdout, _ := runCLI(t, "events", "tail", "--type", "stage.completed", "--stage", "say", events)
+	if code != exitOK {
+		t.Fatalf("events tail exit code = %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "stage.completed") || !strings.Contains(lines[0], "stage=say") {
+		t.Errorf("unexpected events: %s", stdout)
+	}
+
+	_, stdout, _ = runCLI(t, "events", "tail", "--json", "--type", "pipeline.started,pipeline.completed", events)
+	lines = strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d pipeline events, want 2: %s", len(lines), stdout)
+	}
+	var event map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil || event["type"] != "pipeline.started" {
+		t.Errorf("unexpected event %s (%v)", lines[0], err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	path := writeFile(t, "branches.yaml", branchPipeline)
+
+	code, stdout, _ := runCLI(t, "plan", "--json", path)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	var plan planResult
+	if err := json.Unmarshal([]byte(stdout), &plan); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout)
+	}
+	want := [][]string{{"check"}, {"on_true", "on_false"}, {"done"}}
+	if len(plan.Levels) != len(want) {
+		t.Fatalf("levels = %v, want %v", plan.Levels, want)
+	}
+	for i := range want {
+		if strings.Join(plan.Levels[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("level %d = %v, want %v", i, plan.Levels[i], want[i])
+		}
+	}
+	if plan.Mode != "batch" {
+		t.Errorf("mode = %s, want batch", plan.Mode)
+	}
+
+	if code, _, _ := runCLI(t, "plan", writeFile(t, "broken.yaml", brokenPipeline)); code != exitInvalid {
+		t.Errorf("exit code of an invalid pipeline = %d, want %d", code, exitInvalid)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	path := writeFile(t, "branches.yaml", branchPipeline)
+
+	_, stdout, _ := runCLI(t, "graph", path)
+	for _, want := range []string{`digraph "branches" {`, `"check" -> "on_true" [label="true"];`, `"on_true" -> "done";`} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("DOT output does not contain %q:\n%s", want, stdout)
+		}
+	}
+
+	_, stdout, _ = runCLI(t, "graph", "--format", "mermaid", path)
+	for _, want := range []string{"flowchart LR", "s0 -->|false| s2", "s1 --> s3"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Mermaid output does not contain %q:\n%s", want, stdout)
+		}
+	}
+
+	if code, _, _ := runCLI(t, "graph", "--format", "svg", path); code != exitUsage {
+		t.Errorf("exit code of an unknown format = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestSteps(t *testing.T) {
+	code, stdout, _ := runCLI(t, "steps", "list", "--category", "trigger")
+	if code != exitOK || !strings.Contains(stdout, "cron") || strings.Contains(stdout, "foreach") {
+		t.Errorf("unexpected steps list (exit code %d):\n%s", code, stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "steps", "describe", "--json", "cron")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, want %d", code, exitOK)
+	}
+	var meta struct {
+		Name       string `json:"name"`
+		Continuous bool   `json:"continuous"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &meta); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, stdout)
+	}
+	if meta.Name != "cron" || !meta.Continuous {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+
+	if code, _, stderr := runCLI(t, "steps", "describe", "frobnicate"); code != exitFailure || !strings.Contains(stderr, "unknown step type") {
+		t.Errorf("unexpected result for an unknown step type: %d %s", code, stderr)
+	}
+}
+
+func TestServices(t *testing.T) {
+	code, stdout, _ := runCLI(t, "services", "describe", "hackernews", "get_item")
+	if code != exitOK || !strings.Contains(stdout, "Operation:   hackernews.get_item") {
+		t.Errorf("unexpected services describe (exit code %d):\n%s", code, stdout)
+	}
+
+	valid := writeFile(t, "echo.yaml", `service:
+  name: echo
+defaults:
+  base_url: https://example.com
+operations:
+  get:
+    method: GET
+    path: /get
+`)
+	invalid := writeFile(t, "nothing.yaml", `service:
+  name: nothing
+`)
+	code, stdout, _ = runCLI(t, "services", "validate", valid, invalid)
+	if code != exitInvalid {
+		t.Errorf("exit code = %d, want %d", code, exitInvalid)
+	}
+	if !strings.Contains(stdout, valid+": ok") || !strings.Contains(stdout, "invalid service definition") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	pipeline "github.com/simon020286/go-pipeline"
+	"github.com/simon020286/go-pipeline/config"
+	"gopkg.in/yaml.v3"
+)
+
+// keyValueFlag is a repeatable "key=value" flag
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + f[key]
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	f[key] = val
+	return nil
+}
+
+// pipelineFlags are the options of the commands loading a pipeline
+type pipelineFlags struct {
+	vars        keyValueFlag
+	secretsFile string
+}
+
+func (f *pipelineFlags) register(fs *flag.FlagSet) {
+	f.vars = make(keyValueFlag)
+	fs.Var(f.vars, "var", "Override a pipeline variable (`key=value`, repeatable)")
+	fs.StringVar(&f.secretsFile, "secrets-file", "", "YAML or JSON `file` of secrets, merged over the pipeline secrets")
+}
+
+// load loads a pipeline file and applies the variable and secret overrides
+// A pipeline that cannot be loaded is reported as invalid (exit code 3)
+func (f *pipelineFlags) load(path string) (*config.PipelineConfig, error) {
+	cfg, err := config.LoadPipeline(path)
+	if err != nil {
+		return nil, invalidError(err)
+	}
+
+	if len(f.vars) > 0 && cfg.Variables == nil {
+		cfg.Variables = make(map[string]any, len(f.vars))
+	}
+	for key, value := range f.vars {
+		cfg.Variables[key] = value
+	}
+
+	if f.secretsFile != "" {
+		secrets, err := loadSecretsFile(f.secretsFile)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Secrets == nil {
+			cfg.Secrets = make(map[string]any, len(secrets))
+		}
+		for key, value := range secrets {
+			cfg.Secrets[key] = value
+		}
+	}
+	return cfg, nil
+}
+
+// build loads and builds a pipeline; build errors are reported as invalid pipelines
+func (f *pipelineFlags) build(path string) (*config.PipelineConfig, *pipeline.Pipeline, error) {
+	cfg, err := f.load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := pipeline.BuildFromConfig(cfg)
+	if err != nil {
+		return nil, nil, invalidError(err)
+	}
+	return cfg, p, nil
+}
+
+// loadSecretsFile reads a map of secrets from a YAML (or JSON) file
+func loadSecretsFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	var secrets map[string]any
+	if err := yaml.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid secrets file %s: %w", path, err)
+	}
+	return secrets, nil
+}
+
+// stageDependencies returns the dependencies of a stage (the legacy inputs when there are none)
+func stageDependencies(stage config.StageConfig) []string {
+	if len(stage.Dependencies) > 0 {
+		return stage.Dependencies
+	}
+	return stage.Inputs
+}
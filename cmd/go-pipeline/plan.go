@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	pipeline "github.com/simon020286/go-pipeline"
+	"github.com/simon020286/go-pipeline/config"
+)
+
+// planResult describes how a pipeline runs, printed by plan --json
+type planResult struct {
+	Pipeline string            `json:"pipeline"`
+	Mode     string            `json:"mode"`
+	Levels   [][]string        `json:"levels"`
+	Stages   []plannedStage    `json:"stages"`
+	Inputs   []string          `json:"inputs,omitempty"`
+	Outputs  map[string]string `json:"outputs,omitempty"`
+}
+
+// plannedStage is a stage of the plan
+type plannedStage struct {
+	ID           string   `json:"id"`
+	StepType     string   `json:"step_type"`
+	Level        int      `json:"level"`
+	Dependencies []string `json:"dependencies,omitempty"`
+	Continuous   bool     `json:"continuous,omitempty"`
+	DeadLetter   bool     `json:"dead_letter,omitempty"`
+}
+
+func (c *cli) planCommand(args []string) error {
+	fs := c.newFlagSet("plan", "<pipeline.yaml>",
+		"Show the stages of a pipeline in execution order: stages of the same level run concurrently,\n"+
+			"once the stages they depend on have produced their outputs.")
+	var pf pipelineFlags
+	pf.register(fs)
+	jsonOutput := fs.Bool("json", false, "Print the plan as JSON on stdout")
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	cfg, p, err := pf.build(positional[0])
+	if err != nil {
+		return err
+	}
+	plan, err := buildPlan(cfg, p)
+	if err != nil {
+		return invalidError(err)
+	}
+
+	if *jsonOutput {
+		return c.printJSON(plan)
+	}
+
+	fmt.Fprintf(c.stdout, "Pipeline: %s\n", plan.Pipeline)
+	fmt.Fprintf(c.stdout, "Mode: %s\n", plan.Mode)
+	if len(plan.Inputs) > 0 {
+		fmt.Fprintf(c.stdout, "Inputs: %s\n", strings.Join(plan.Inputs, ", "))
+	}
+	stages := make(map[string]plannedStage, len(plan.Stages))
+	for _, stage := range plan.Stages {
+		stages[stage.ID] = stage
+	}
+	for level, ids := range plan.Levels {
+		fmt.Fprintf(c.stdout, "\nLevel %d:\n", level)
+		for _, id := range ids {
+			stage := stages[id]
+			fmt.Fprintf(c.stdout, "  %s (%s)", stage.ID, stage.StepType)
+			if len(stage.Dependencies) > 0 {
+				fmt.Fprintf(c.stdout, " <- %s", strings.Join(stage.Dependencies, ", "))
+			}
+			if stage.Continuous {
+				fmt.Fprint(c.stdout, " [continuous]")
+			}
+			if stage.DeadLetter {
+				fmt.Fprint(c.stdout, " [dead letter]")
+			}
+			fmt.Fprintln(c.stdout)
+		}
+	}
+	if len(plan.Outputs) > 0 {
+		fmt.Fprintf(c.stdout, "\nOutputs:\n")
+		for _, name := range sortedKeys(plan.Outputs) {
+			fmt.Fprintf(c.stdout, "  %s <- %s\n", name, plan.Outputs[name])
+		}
+	}
+	return nil
+}
+
+// buildPlan groups the stages of a built pipeline by level: a stage runs at the level
+// following the highest level of its dependencies, entry stages at level 0
+func buildPlan(cfg *config.PipelineConfig, p *pipeline.Pipeline) (planResult, error) {
+	plan := planResult{
+		Pipeline: cfg.Name,
+		Mode:     p.Mode().String(),
+		Inputs:   sortedKeys(cfg.Inputs),
+		Outputs:  cfg.Outputs,
+	}
+
+	levels := make(map[string]int, len(cfg.Stages))
+	for len(levels) < len(cfg.Stages) {
+		progress := false
+		for _, stage := range cfg.Stages {
+			if _, done := levels[stage.ID]; done {
+				continue
+			}
+			level, ready := 0, true
+			for _, dep := range stageDependencies(stage) {
+				depLevel, done := levels[config.ParseDependency(dep).StageID]
+				if !done {
+					ready = false
+					break
+				}
+				level = max(level, depLevel+1)
+			}
+			if ready {
+				levels[stage.ID] = level
+				progress = true
+			}
+		}
+		if !progress {
+			return planResult{}, fmt.Errorf("the stages contain a dependency cycle")
+		}
+	}
+
+	for _, stage := range cfg.Stages {
+		planned := plannedStage{
+			ID:           stage.ID,
+			StepType:     stage.StepType,
+			Level:        levels[stage.ID],
+			Dependencies: stageDependencies(stage),
+			DeadLetter:   cfg.DeadLetter != nil && cfg.DeadLetter.Type == "stage" && cfg.DeadLetter.Stage == stage.ID,
+		}
+		if built, ok := p.GetStage(stage.ID); ok {
+			planned.Continuous = built.Step.IsContinuous()
+		}
+		plan.Stages = append(plan.Stages, planned)
+
+		for len(plan.Levels) <= planned.Level {
+			plan.Levels = append(plan.Levels, nil)
+		}
+		plan.Levels[planned.Level] = append(plan.Levels[planned.Level], stage.ID)
+	}
+	return plan, nil
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
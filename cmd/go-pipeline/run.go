@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	pipeline "github.com/simon020286/go-pipeline"
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/config"
+	"github.com/simon020286/go-pipeline/logging"
+	"github.com/simon020286/go-pipeline/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Run statuses
+const (
+	statusCompleted   = "completed"
+	statusFailed      = "failed"
+	statusTimeout     = "timeout"
+	statusInterrupted = "interrupted"
+)
+
+// runResult is the outcome of a run, printed by run --json
+type runResult struct {
+	Pipeline   string           `json:"pipeline"`
+	Mode       string           `json:"mode"`
+	Status     string           `json:"status"`
+	DurationMs int64            `json:"duration_ms"`
+	Errors     []runError       `json:"errors,omitempty"`
+	Outputs    map[string][]any `json:"outputs,omitempty"`
+}
+
+// runError is a stage or pipeline error of a run
+type runError struct {
+	Stage   string `json:"stage,omitempty"`
+	EventID string `json:"event_id,omitempty"`
+	Error   string `json:"error"`
+}
+
+// errorCollector is a listener keeping the errors of a run
+type errorCollector struct {
+	mutex  sync.Mutex
+	errors []runError
+}
+
+func (e *errorCollector) OnEvent(event models.Event) {
+	var runErr runError
+	if payload, ok := event.AsStageError(); ok {
+		runErr = runError{Stage: payload.StageID, EventID: payload.EventID, Error: payload.Error}
+	} else if payload, ok := event.AsPipelineError(); ok {
+		runErr = runError{Error: payload.Error}
+	} else {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.errors = append(e.errors, runErr)
+}
+
+func (e *errorCollector) collected() []runError {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]runError(nil), e.errors...)
+}
+
+// eventsFileListener appends the events as JSON lines (the format read by events tail)
+// The stage outputs are written as they are: secrets are not redacted
+type eventsFileListener struct {
+	encoder *json.Encoder
+	errs    io.Writer
+}
+
+func (l *eventsFileListener) OnEvent(event models.Event) {
+	if err := l.encoder.Encode(event); err != nil {
+		fmt.Fprintf(l.errs, "Warning: failed to write event: %v\n", err)
+	}
+}
+
+func (c *cli) runCommand(args []string) error {
+	fs := c.newFlagSet("run", "<pipeline.yaml>",
+		"Run a pipeline. Batch pipelines run until every stage is done and print their declared outputs;\n"+
+			"streaming pipelines (cron, continuous webhook) run until interrupted or until --timeout.")
+	var pf pipelineFlags
+	pf.register(fs)
+	inputs := make(keyValueFlag)
+	fs.Var(inputs, "input", "Set a declared pipeline input (`key=value`, repeatable; the value is parsed as YAML)")
+	timeout := fs.Duration("timeout", 0, "Stop the pipeline after this `duration` (batch pipelines then fail with exit code 124)")
+	verbose := fs.Bool("v", false, "Log debug events and the stage outputs")
+	logFormat := fs.String("log-format", "text", "Format of the event log on stderr: text, json or none")
+	eventsFile := fs.String("events-file", "", "Append the events as JSON lines to `file` (see events tail)")
+	jsonOutput := fs.Bool("json", false, "Print the result of the run as JSON on stdout")
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	switch *logFormat {
+	case "text":
+		logServiceLoad(slog.NewTextHandler(c.stderr, &slog.HandlerOptions{Level: level}))
+	case "json":
+		// stderr only carries JSON lines: the summary and the errors too
+		c.jsonLog = slog.New(slog.NewJSONHandler(c.stderr, &slog.HandlerOptions{Level: level}))
+		logServiceLoad(c.jsonLog.Handler())
+	case "none":
+		c.printServiceWarnings()
+	default:
+		return usageErrorf("unknown log format '%s' (expected text, json or none)", *logFormat)
+	}
+
+	cfg, p, err := pf.build(positional[0])
+	if err != nil {
+		return err
+	}
+	mode := p.Mode()
+
+	runInputs, err := parseInputs(cfg.Inputs, inputs)
+	if err != nil {
+		return err
+	}
+	if len(runInputs) > 0 && mode == pipeline.ExecutionModeStreaming {
+		return usageErrorf("--input is only supported by batch pipelines")
+	}
+
+	logOptions := logging.Options{
+		Level:         level,
+		IncludeOutput: *verbose,
+		Secrets:       p.GlobalSecrets(),
+	}
+	switch *logFormat {
+	case "text":
+		p.AddListener(logging.NewText(c.stderr, logOptions))
+	case "json":
+		p.AddListener(logging.NewJSON(c.stderr, logOptions))
+	}
+
+	if *eventsFile != "" {
+		file, err := os.OpenFile(*eventsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open events file: %w", err)
+		}
+		defer file.Close()
+		p.AddListener(&eventsFileListener{encoder: json.NewEncoder(file), errs: c.stderr})
+	}
+
+	collector := &errorCollector{}
+	p.AddListener(collector)
+
+	ctx := c.ctx
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	var outputs map[string][]any
+	var invokeErr error
+	if mode == pipeline.ExecutionModeBatch {
+		// The stage errors are also gathered by the collector
+		outputs, invokeErr = p.Invoke(ctx, runInputs)
+	} else {
+		if err := p.Start(ctx); err != nil {
+			return err
+		}
+		p.Wait()
+	}
+
+	result := runResult{
+		Pipeline:   cfg.Name,
+		Mode:       mode.String(),
+		Status:     statusCompleted,
+		DurationMs: time.Since(start).Milliseconds(),
+		Errors:     collector.collected(),
+		Outputs:    outputs,
+	}
+	code := exitOK
+	switch {
+	case c.ctx.Err() != nil:
+		result.Status, code = statusInterrupted, exitInterrupted
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) && mode == pipeline.ExecutionModeBatch:
+		result.Status, code = statusTimeout, exitTimeout
+	case len(result.Errors) > 0:
+		result.Status, code = statusFailed, exitFailure
+	case invokeErr != nil:
+		result.Status, code = statusFailed, exitFailure
+		result.Errors = []runError{{Error: invokeErr.Error()}}
+	}
+
+	if *jsonOutput {
+		if err := c.printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		if len(outputs) > 0 {
+			if err := c.printJSON(outputs); err != nil {
+				return err
+			}
+		}
+		if c.jsonLog != nil {
+			c.jsonLog.Info("run.finished", "pipeline", cfg.Name, "mode", result.Mode, "status", result.Status, "duration_ms", result.DurationMs)
+		} else {
+			fmt.Fprintf(c.stderr, "Pipeline %s (%s) %s in %v\n", cfg.Name, result.Mode, result.Status, time.Duration(result.DurationMs)*time.Millisecond)
+		}
+	}
+
+	switch code {
+	case exitOK:
+		return nil
+	case exitFailure:
+		first := result.Errors[0]
+		if first.Stage != "" {
+			return &exitError{code: code, err: fmt.Errorf("%d error(s), first in stage '%s': %s", len(result.Errors), first.Stage, first.Error)}
+		}
+		return &exitError{code: code, err: fmt.Errorf("%d error(s), first: %s", len(result.Errors), first.Error)}
+	default:
+		return &exitError{code: code, err: fmt.Errorf("pipeline %s", result.Status)}
+	}
+}
+
+// logServiceLoad writes the messages of the service loading to the event log:
+// the warnings, and the other messages at debug level (-v)
+func logServiceLoad(handler slog.Handler) {
+	logger := slog.New(handler)
+	for _, message := range builder.ServiceLoadMessages() {
+		if message.Warning {
+			logger.Warn(message.Message)
+		} else {
+			logger.Debug(message.Message)
+		}
+	}
+}
+
+// parseInputs converts the --input values to the declared inputs of the pipeline
+// Values are parsed as YAML ("3" is a number, "[a, b]" a list) except for string inputs,
+// and are checked with config.ResolveInputs
+func parseInputs(defs map[string]config.ParameterDef, values keyValueFlag) (map[string]any, error) {
+	if len(defs) == 0 && len(values) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inputs := make(map[string]any, len(values))
+	for _, name := range names {
+		raw := values[name]
+		if def, ok := defs[name]; ok && def.Type == "string" {
+			inputs[name] = raw
+			continue
+		}
+		var value any
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, usageErrorf("invalid value of input '%s': %v", name, err)
+		}
+		inputs[name] = value
+	}
+
+	if _, err := config.ResolveInputs(defs, inputs); err != nil {
+		return nil, usageErrorf("%v", err)
+	}
+	return inputs, nil
+}
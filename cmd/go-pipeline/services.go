@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/simon020286/go-pipeline/builder"
+)
+
+// serviceSummary is a service of services list --json
+type serviceSummary struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	BaseURL     string   `json:"base_url,omitempty"`
+	Operations  []string `json:"operations"`
+}
+
+func (c *cli) servicesListCommand(args []string) error {
+	fs := c.newFlagSet("services list", "",
+		"List the loaded service definitions: the embedded ones and those of $GO_PIPELINE_SERVICES_PATH\n"+
+			"(default ~/.go-pipeline/services).")
+	jsonOutput := fs.Bool("json", false, "Print the services as JSON on stdout")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	registry := builder.GetGlobalServiceRegistry()
+	names := registry.List()
+	sort.Strings(names)
+	summaries := make([]serviceSummary, 0, len(names))
+	for _, name := range names {
+		def, _ := registry.Get(name)
+		summaries = append(summaries, serviceSummary{
+			Name:        name,
+			Description: def.Service.Description,
+			Version:     def.Service.Version,
+			BaseURL:     def.Defaults.BaseURL,
+			Operations:  sortedKeys(def.Operations),
+		})
+	}
+
+	if *jsonOutput {
+		return c.printJSON(summaries)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tOPERATIONS\tDESCRIPTION")
+	for _, summary := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", summary.Name, len(summary.Operations), summary.Description)
+	}
+	return w.Flush()
+}
+
+func (c *cli) servicesDescribeCommand(args []string) error {
+	fs := c.newFlagSet("services describe", "<service> [operation]",
+		"Describe a service and its operations, or the parameters of one operation.")
+	jsonOutput := fs.Bool("json", false, "Print the metadata as JSON on stdout")
+	positional, err := parseFlags(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	name := positional[0]
+	def, ok := builder.GetGlobalServiceRegistry().Get(name)
+	if !ok {
+		return fmt.Errorf("unknown service: %s", name)
+	}
+	meta, err := builder.DescribeStepType(name)
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 1 {
+		if *jsonOutput {
+			return c.printJSON(meta)
+		}
+		if def.Defaults.BaseURL != "" {
+			fmt.Fprintf(c.stdout, "Base URL:    %s\n", def.Defaults.BaseURL)
+		}
+		return writeStepMetadata(c.stdout, meta)
+	}
+
+	op, ok := meta.Operation(positional[1])
+	if !ok {
+		return fmt.Errorf("unknown operation '%s' of service '%s'", positional[1], name)
+	}
+	if *jsonOutput {
+		return c.printJSON(op)
+	}
+	fmt.Fprintf(c.stdout, "Operation:   %s.%s\n", name, op.Name)
+	if op.Description != "" {
+		fmt.Fprintf(c.stdout, "Description: %s\n", op.Description)
+	}
+	fmt.Fprintf(c.stdout, "Request:     %s %s%s\n", op.Method, def.Defaults.BaseURL, op.Path)
+	fmt.Fprintln(c.stdout, "\nParameters:")
+	return writeInputs(c.stdout, op.Inputs)
+}
+
+func (c *cli) servicesValidateCommand(args []string) error {
+	fs := c.newFlagSet("services validate", "<service.yaml>...",
+		"Check service definition files. Exits with code 3 when a file is invalid.")
+	jsonOutput := fs.Bool("json", false, "Print the reports as JSON on stdout")
+	files, err := parseFlags(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]fileReport, 0, len(files))
+	for _, file := range files {
+		report := fileReport{File: file, Valid: true}
+		data, err := os.ReadFile(file)
+		if err == nil {
+			_, err = builder.ParseServiceDefinition(data, file)
+		}
+		if err != nil {
+			report.Valid = false
+			report.Diagnostics = []diagnostic{{File: file, Message: err.Error()}}
+		}
+		reports = append(reports, report)
+	}
+
+	return c.printReports(reports, *jsonOutput)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/config"
+)
+
+func (c *cli) stepsListCommand(args []string) error {
+	fs := c.newFlagSet("steps list", "",
+		"List the registered step types: the built-in steps and the service steps.")
+	category := fs.String("category", "", "Only list the step types of this `category` (e.g. flow, trigger, service)")
+	jsonOutput := fs.Bool("json", false, "Print the metadata of the step types as JSON on stdout")
+	if _, err := parseFlags(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	var metas []config.StepMetadata
+	for _, meta := range builder.DescribeStepTypes() {
+		if *category == "" || meta.Category == *category {
+			metas = append(metas, meta)
+		}
+	}
+
+	if *jsonOutput {
+		if metas == nil {
+			metas = []config.StepMetadata{}
+		}
+		return c.printJSON(metas)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCATEGORY\tDESCRIPTION")
+	for _, meta := range metas {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", meta.Name, meta.Category, meta.Description)
+	}
+	return w.Flush()
+}
+
+func (c *cli) stepsDescribeCommand(args []string) error {
+	fs := c.newFlagSet("steps describe", "<step_type>",
+		"Describe a step type: its parameters, output ports and, for service steps, its operations.")
+	jsonOutput := fs.Bool("json", false, "Print the metadata as JSON on stdout")
+	positional, err := parseFlags(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	meta, err := builder.DescribeStepType(positional[0])
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return c.printJSON(meta)
+	}
+	return writeStepMetadata(c.stdout, meta)
+}
+
+// writeStepMetadata prints the description of a step type
+func writeStepMetadata(w io.Writer, meta config.StepMetadata) error {
+	fmt.Fprintf(w, "Name:        %s\n", meta.Name)
+	if meta.Category != "" {
+		fmt.Fprintf(w, "Category:    %s\n", meta.Category)
+	}
+	if meta.Description != "" {
+		fmt.Fprintf(w, "Description: %s\n", meta.Description)
+	}
+	if meta.ContinuousConfigurable {
+		fmt.Fprintf(w, "Continuous:  configurable (continuous key)\n")
+	} else {
+		fmt.Fprintf(w, "Continuous:  %t\n", meta.Continuous)
+	}
+	if len(meta.Ports) > 0 {
+		fmt.Fprintf(w, "Ports:       %s\n", strings.Join(meta.Ports, ", "))
+	} else {
+		fmt.Fprintf(w, "Ports:       not known in advance\n")
+	}
+
+	fmt.Fprintln(w, "\nParameters:")
+	if err := writeInputs(w, meta.Inputs); err != nil {
+		return err
+	}
+
+	if len(meta.Operations) > 0 {
+		fmt.Fprintln(w, "\nOperations:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, op := range meta.Operations {
+			fmt.Fprintf(tw, "  %s\t%s %s\t%s\n", op.Name, op.Method, op.Path, op.Description)
+		}
+		return tw.Flush()
+	}
+	return nil
+}
+
+// writeInputs prints a table of parameters
+func writeInputs(w io.Writer, inputs []config.InputMeta) error {
+	if len(inputs) == 0 {
+		fmt.Fprintln(w, "  none")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  NAME\tTYPE\tREQUIRED\tDEFAULT\tDESCRIPTION")
+	for _, input := range inputs {
+		fmt.Fprintf(tw, "  %s\t%s\t%t\t%s\t%s\n", input.Name, input.Type, input.Required, input.Default, input.Description)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	pipeline "github.com/simon020286/go-pipeline"
+	"github.com/simon020286/go-pipeline/config"
+)
+
+// fileReport is the result of the validation of a file, printed by validate --json
+type fileReport struct {
+	File        string       `json:"file"`
+	Valid       bool         `json:"valid"`
+	Diagnostics []diagnostic `json:"diagnostics,omitempty"`
+}
+
+// diagnostic is a problem of a file
+type diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Stage   string `json:"stage,omitempty"`
+	Message string `json:"message"`
+}
+
+func (c *cli) validateCommand(args []string) error {
+	fs := c.newFlagSet("validate", "<pipeline.yaml>...",
+		"Check pipeline files without running them: the configuration (see config.ValidatePipeline),\n"+
+			"then the construction of every step. Exits with code 3 when a file is invalid.")
+	var pf pipelineFlags
+	pf.register(fs)
+	jsonOutput := fs.Bool("json", false, "Print the reports as JSON on stdout")
+	files, err := parseFlags(fs, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]fileReport, 0, len(files))
+	for _, file := range files {
+		report := fileReport{File: file, Diagnostics: validatePipelineFile(&pf, file)}
+		report.Valid = len(report.Diagnostics) == 0
+		reports = append(reports, report)
+	}
+
+	return c.printReports(reports, *jsonOutput)
+}
+
+// printReports prints the validation reports of files; invalid files are an error (exit code 3)
+func (c *cli) printReports(reports []fileReport, jsonOutput bool) error {
+	invalid := 0
+	for _, report := range reports {
+		if !report.Valid {
+			invalid++
+		}
+	}
+
+	if jsonOutput {
+		if err := c.printJSON(reports); err != nil {
+			return err
+		}
+	} else {
+		for _, report := range reports {
+			if report.Valid {
+				fmt.Fprintf(c.stdout, "%s: ok\n", report.File)
+				continue
+			}
+			for _, d := range report.Diagnostics {
+				fmt.Fprintln(c.stdout, d.String())
+			}
+		}
+	}
+
+	if invalid > 0 {
+		return invalidError(fmt.Errorf("%d of %d file(s) invalid", invalid, len(reports)))
+	}
+	return nil
+}
+
+// validatePipelineFile returns the problems of a pipeline file
+func validatePipelineFile(pf *pipelineFlags, file string) []diagnostic {
+	cfg, err := pf.load(file)
+	if err != nil {
+		return []diagnostic{{File: file, Message: err.Error()}}
+	}
+
+	if diagnostics := config.ValidatePipeline(cfg); len(diagnostics) > 0 {
+		result := make([]diagnostic, len(diagnostics))
+		for i, d := range diagnostics {
+			result[i] = diagnostic(d)
+			if result[i].File == "" {
+				result[i].File = file
+			}
+		}
+		return result
+	}
+
+	// Step configurations are decoded by the step factories
+	if _, err := pipeline.BuildFromConfig(cfg); err != nil {
+		return []diagnostic{{File: file, Message: err.Error()}}
+	}
+	return nil
+}
+
+func (d diagnostic) String() string {
+	return config.Diagnostic(d).String()
+}
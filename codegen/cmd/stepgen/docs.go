@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/simon020286/go-pipeline/config"
+	"gopkg.in/yaml.v3"
+)
+
+// docsHeader marks the generated Markdown pages
+const docsHeader = "<!-- Code generated by stepgen. DO NOT EDIT. -->\n\n"
+
+// writeDocs renders the Markdown reference of the steps and of the service operations into dir:
+// an index (README.md), steps/<name>.md and services/<service>/<operation>.md
+// The steps and services subdirectories are regenerated from scratch, so removed steps lose their page
+func writeDocs(dir string, steps []StepMetadata, structs map[string][]InputMeta, services []*config.ServiceDefinition) error {
+	pages := map[string]string{
+		"README.md": renderDocsIndex(steps, services),
+	}
+	for _, step := range steps {
+		page, err := renderStepDoc(step, structs)
+		if err != nil {
+			return fmt.Errorf("step %s: %w", step.Name, err)
+		}
+		pages[filepath.Join("steps", step.Name+".md")] = page
+	}
+	for _, def := range services {
+		for _, name := range sortedKeys(def.Operations) {
+			page, err := renderOperationDoc(def, name)
+			if err != nil {
+				return fmt.Errorf("service %s, operation %s: %w", def.Service.Name, name, err)
+			}
+			pages[filepath.Join("services", def.Service.Name, name+".md")] = page
+		}
+	}
+
+	for _, sub := range []string{"steps", "services"} {
+		if err := os.RemoveAll(filepath.Join(dir, sub)); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(pages) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(pages[name]), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderDocsIndex renders the index of the reference: the steps by category, then the services
+func renderDocsIndex(steps []StepMetadata, services []*config.ServiceDefinition) string {
+	var sb strings.Builder
+	sb.WriteString(docsHeader)
+	sb.WriteString("# Step Reference\n\n")
+	sb.WriteString("Generated by `stepgen` (`go generate ./steps`) from the `@step` config structs and the service definitions in `builder/services`.\n")
+
+	byCategory := make(map[string][]StepMetadata)
+	for _, step := range steps {
+		byCategory[step.Category] = append(byCategory[step.Category], step)
+	}
+	sb.WriteString("\n## Steps\n")
+	for _, category := range sortedKeys(byCategory) {
+		fmt.Fprintf(&sb, "\n### %s\n\n", category)
+		sb.WriteString("| Step | Description |\n|------|-------------|\n")
+		for _, step := range byCategory[category] {
+			fmt.Fprintf(&sb, "| [`%s`](steps/%s.md) | %s |\n", step.Name, step.Name, escapeCell(step.Description))
+		}
+	}
+
+	if len(services) > 0 {
+		sb.WriteString("\n## Services\n")
+		for _, def := range services {
+			fmt.Fprintf(&sb, "\n### %s\n\n", def.Service.Name)
+			if def.Service.Description != "" {
+				fmt.Fprintf(&sb, "%s\n\n", def.Service.Description)
+			}
+			sb.WriteString("| Operation | Method | Description |\n|-----------|--------|-------------|\n")
+			for _, name := range sortedKeys(def.Operations) {
+				op := def.Operations[name]
+				fmt.Fprintf(&sb, "| [`%s`](services/%s/%s.md) | %s | %s |\n",
+					name, def.Service.Name, name, op.Method, escapeCell(op.Description))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// renderStepDoc renders the page of a step: its parameters, output ports and an example stage
+// The items of list parameters whose element is a config struct of the package are documented too
+func renderStepDoc(step StepMetadata, structs map[string][]InputMeta) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(docsHeader)
+	fmt.Fprintf(&sb, "# `%s`\n\n", step.Name)
+	fmt.Fprintf(&sb, "%s.\n\n", strings.TrimSuffix(step.Description, "."))
+	fmt.Fprintf(&sb, "- **Category:** %s\n", step.Category)
+	if step.Continuous {
+		sb.WriteString("- **Continuous:** yes, the step emits events on its own and can start a pipeline\n")
+	} else if step.ContinuousConfigurable {
+		sb.WriteString("- **Continuous:** configurable, with `continuous: true` the step emits events on its own and can start a pipeline\n")
+	}
+
+	sb.WriteString("\n## Parameters\n\n")
+	writeInputsTable(&sb, step.Inputs)
+	for _, input := range step.Inputs {
+		if fields, ok := structs[elementType(input.Type)]; ok {
+			fmt.Fprintf(&sb, "\nEach item of `%s`:\n\n", input.Name)
+			writeInputsTable(&sb, fields)
+		}
+	}
+
+	sb.WriteString("\n## Output Ports\n\n")
+	writePorts(&sb, step.Ports)
+
+	example, err := renderExample(step.Name, stepExampleConfig(step, structs), step.Category != "trigger")
+	if err != nil {
+		return "", err
+	}
+	sb.WriteString("\n## Example\n\n```yaml\n")
+	sb.WriteString(example)
+	sb.WriteString("```\n")
+	return sb.String(), nil
+}
+
+// renderOperationDoc renders the page of a service operation: its request, parameters and an example stage
+func renderOperationDoc(def *config.ServiceDefinition, name string) (string, error) {
+	op := def.Operations[name]
+	inputs := operationInputs(def, op)
+
+	var sb strings.Builder
+	sb.WriteString(docsHeader)
+	fmt.Fprintf(&sb, "# `%s` / `%s`\n\n", def.Service.Name, name)
+	if op.Description != "" {
+		fmt.Fprintf(&sb, "%s.\n\n", strings.TrimSuffix(op.Description, "."))
+	}
+	fmt.Fprintf(&sb, "- **Service:** %s", def.Service.Name)
+	if def.Service.Description != "" {
+		fmt.Fprintf(&sb, " (%s)", def.Service.Description)
+	}
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "- **Request:** `%s %s%s`\n", op.Method, def.Defaults.BaseURL, op.Path)
+	responseType := op.ResponseType
+	if responseType == "" {
+		responseType = "json"
+	}
+	fmt.Fprintf(&sb, "- **Response:** %s\n", responseType)
+
+	sb.WriteString("\n## Parameters\n\n")
+	writeInputsTable(&sb, append([]InputMeta{
+		{Name: "operation", Type: "string", Required: true, Description: fmt.Sprintf("Must be `%s`", name)},
+	}, inputs...))
+
+	sb.WriteString("\n## Output Ports\n\n")
+	writePorts(&sb, []string{"default"})
+	sb.WriteString("\nThe output is the HTTP response, as for `http_client`.\n")
+
+	cfg := []configEntry{{"operation", name}}
+	for _, input := range inputs {
+		if input.Required {
+			cfg = append(cfg, configEntry{input.Name, exampleValue(input, nil)})
+		}
+	}
+	example, err := renderExample(def.Service.Name, cfg, true)
+	if err != nil {
+		return "", err
+	}
+	sb.WriteString("\n## Example\n\n```yaml\n")
+	sb.WriteString(example)
+	sb.WriteString("```\n")
+	return sb.String(), nil
+}
+
+// operationInputs returns the parameters of an operation, sorted: the global parameters of the
+// service, overridden by the operation params, and the undeclared placeholders of its templates
+func operationInputs(def *config.ServiceDefinition, op config.OperationDef) []InputMeta {
+	params := make(map[string]config.ParameterDef)
+	for name, param := range def.GlobalParams {
+		params[name] = param
+	}
+	for name, param := range op.Params {
+		params[name] = param
+	}
+
+	// Placeholders of the path are needed to build the URL, the others are left empty when unset
+	inputs := make(map[string]InputMeta)
+	used := make(map[string]bool)
+	collectPlaceholders(op.QueryParams, used)
+	collectPlaceholders(op.Headers, used)
+	collectPlaceholders(op.Body, used)
+	collectPlaceholders(def.Defaults.Headers, used)
+	if def.Defaults.Auth != nil {
+		auth := def.Defaults.Auth
+		collectPlaceholders([]any{auth.Value, auth.Username, auth.Password}, used)
+	}
+	for name := range used {
+		inputs[name] = InputMeta{Name: name, Type: "any", Description: "Used by the request template"}
+	}
+	inPath := make(map[string]bool)
+	collectPlaceholders(op.Path, inPath)
+	for name := range inPath {
+		inputs[name] = InputMeta{Name: name, Type: "any", Required: true, Description: "Used by the request path"}
+	}
+	for name, param := range params {
+		input := InputMeta{
+			Name:        name,
+			Type:        param.Type,
+			Required:    param.IsRequired() && param.Default == nil,
+			Description: param.Description,
+		}
+		if input.Type == "" {
+			input.Type = "any"
+		}
+		switch value := param.Default.(type) {
+		case nil:
+		case string:
+			input.Default = value
+		default:
+			if data, err := json.Marshal(value); err == nil {
+				input.Default = string(data)
+			}
+		}
+		inputs[name] = input
+	}
+
+	result := make([]InputMeta, 0, len(inputs))
+	for _, name := range sortedKeys(inputs) {
+		result = append(result, inputs[name])
+	}
+	return result
+}
+
+func writeInputsTable(sb *strings.Builder, inputs []InputMeta) {
+	if len(inputs) == 0 {
+		sb.WriteString("None.\n")
+		return
+	}
+	sb.WriteString("| Name | Type | Required | Default | Description |\n")
+	sb.WriteString("|------|------|----------|---------|-------------|\n")
+	for _, input := range inputs {
+		required := "no"
+		if input.Required {
+			required = "yes"
+		}
+		def := ""
+		if input.Default != "" {
+			def = "`" + input.Default + "`"
+		}
+		fmt.Fprintf(sb, "| `%s` | `%s` | %s | %s | %s |\n",
+			input.Name, input.Type, required, def, escapeCell(input.Description))
+	}
+}
+
+func writePorts(sb *strings.Builder, ports []string) {
+	if len(ports) == 0 {
+		sb.WriteString("Not known in advance: the step decides at runtime which ports it emits.\n")
+		return
+	}
+	for _, port := range ports {
+		fmt.Fprintf(sb, "- `%s`\n", port)
+	}
+	sb.WriteString("\nDownstream stages select a port with `stage_id:port` in `dependencies`.\n")
+}
+
+// configEntry is a key of an example step_config
+type configEntry struct {
+	key   string
+	value any
+}
+
+// stepExampleConfig returns the step_config of the example of a step:
+// the required parameters and the ones with a default
+func stepExampleConfig(step StepMetadata, structs map[string][]InputMeta) []configEntry {
+	var cfg []configEntry
+	for _, input := range step.Inputs {
+		if input.Required || input.Default != "" {
+			cfg = append(cfg, configEntry{input.Name, exampleValue(input, structs)})
+		}
+	}
+	return cfg
+}
+
+// exampleValue returns an example value for a parameter: its default, or a placeholder of its type
+// Dynamic parameters read the output of the "previous" stage of the example
+func exampleValue(input InputMeta, structs map[string][]InputMeta) any {
+	if input.Default != "" {
+		return defaultValue(input.Type, input.Default)
+	}
+	if fields, ok := structs[elementType(input.Type)]; ok {
+		item := make(map[string]any, len(fields))
+		for _, field := range fields {
+			item[field.Name] = exampleValue(field, nil)
+		}
+		return []any{item}
+	}
+	switch {
+	case input.Type == "string":
+		return "<" + input.Name + ">"
+	case input.Type == "duration":
+		return "5s"
+	case input.Type == "bool":
+		return true
+	case strings.HasPrefix(input.Type, "int") || strings.HasPrefix(input.Type, "uint"):
+		return 1
+	case strings.HasPrefix(input.Type, "float"):
+		return 1.5
+	case input.Type == "array":
+		return "$js: ctx.previous.items"
+	case strings.HasPrefix(input.Type, "map["):
+		return map[string]any{"key": "value"}
+	default:
+		return "$js: ctx.previous"
+	}
+}
+
+// renderExample renders a stage running stepType with cfg, as an item of the stages list
+func renderExample(stepType string, cfg []configEntry, withDependency bool) (string, error) {
+	stage := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value any) error {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return err
+		}
+		stage.Content = append(stage.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &node)
+		return nil
+	}
+
+	if err := add("id", "my_"+stepType); err != nil {
+		return "", err
+	}
+	if err := add("step_type", stepType); err != nil {
+		return "", err
+	}
+	if len(cfg) > 0 {
+		configNode := &yaml.Node{Kind: yaml.MappingNode}
+		for _, entry := range cfg {
+			var node yaml.Node
+			if err := node.Encode(entry.value); err != nil {
+				return "", err
+			}
+			configNode.Content = append(configNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: entry.key}, &node)
+		}
+		stage.Content = append(stage.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "step_config"}, configNode)
+	}
+	if withDependency {
+		if err := add("dependencies", []string{"previous"}); err != nil {
+			return "", err
+		}
+	}
+
+	var sb strings.Builder
+	encoder := yaml.NewEncoder(&sb)
+	encoder.SetIndent(2)
+	if err := encoder.Encode([]*yaml.Node{stage}); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// parseStructTypes returns the fields of the struct types declared in the Go files of dir,
+// so that the pages can document the items of list parameters (e.g. the fields of map)
+func parseStructTypes(dir string) (map[string][]InputMeta, error) {
+	fset := token.NewFileSet()
+	structs := make(map[string][]InputMeta)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_gen.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			typeSpec, ok := n.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+			if structType, ok := typeSpec.Type.(*ast.StructType); ok {
+				structs[typeSpec.Name.Name] = parseStructFields(structType)
+			}
+			return false
+		})
+	}
+	return structs, nil
+}
+
+// elementType returns the element type of a slice type ("[]MapField" -> "MapField"), "" for other types
+func elementType(goType string) string {
+	if !strings.HasPrefix(goType, "[]") {
+		return ""
+	}
+	return strings.TrimPrefix(goType, "[]")
+}
+
+// escapeCell escapes the pipes of a Markdown table cell
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
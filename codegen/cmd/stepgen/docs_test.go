@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// generateDocs writes the reference of the steps and services of the repository into a temporary directory
+func generateDocs(t *testing.T) string {
+	t.Helper()
+	steps, err := parseDirectory("../../../steps")
+	if err != nil {
+		t.Fatalf("parseDirectory failed: %v", err)
+	}
+	structs, err := parseStructTypes("../../../steps")
+	if err != nil {
+		t.Fatalf("parseStructTypes failed: %v", err)
+	}
+	services, err := loadServices("../../../builder/services")
+	if err != nil {
+		t.Fatalf("loadServices failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := writeDocs(dir, steps, structs, services); err != nil {
+		t.Fatalf("writeDocs failed: %v", err)
+	}
+	return dir
+}
+
+// readPages returns the Markdown pages of a reference directory by relative path
+func readPages(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	pages := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		pages[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkDir failed: %v", err)
+	}
+	return pages
+}
+
+func TestWriteDocs_Pages(t *testing.T) {
+	pages := readPages(t, generateDocs(t))
+
+	for _, name := range []string{"README.md", "steps/map.md", "steps/foreach.md", "services/hackernews/get_item.md"} {
+		if _, ok := pages[name]; !ok {
+			t.Errorf("missing page %s", name)
+		}
+	}
+
+	mapPage := pages["steps/map.md"]
+	if !strings.Contains(mapPage, "Each item of `fields`") || !strings.Contains(mapPage, "- name: <name>") {
+		t.Errorf("map page should document the items of fields:\n%s", mapPage)
+	}
+	if !strings.Contains(pages["steps/cron.md"], "**Continuous:** yes") {
+		t.Error("cron page should say the step is continuous")
+	}
+	if !strings.Contains(pages["steps/webhook.md"], "**Continuous:** configurable") {
+		t.Error("webhook page should say the step can be continuous")
+	}
+	if !strings.Contains(pages["steps/if.md"], "- `true`\n- `false`") {
+		t.Error("if page should list its output ports")
+	}
+	if !strings.Contains(pages["services/hackernews/get_item.md"], "| `item_id` | `any` | yes |") {
+		t.Error("get_item page should require the placeholder of its path")
+	}
+}
+
+// yamlBlock matches the example snippet of a page
+var yamlBlock = regexp.MustCompile("(?s)```yaml\n(.*?)```")
+
+func TestWriteDocs_ExamplesMatchSchema(t *testing.T) {
+	schema := loadSchema(t)
+	v := &schemaValidator{root: schema}
+
+	for name, page := range readPages(t, generateDocs(t)) {
+		match := yamlBlock.FindStringSubmatch(page)
+		if match == nil {
+			if name != "README.md" {
+				t.Errorf("%s: no example", name)
+			}
+			continue
+		}
+		doc := decodeYAML(t, []byte("stages:\n"+match[1]))
+		if errs := v.validate(schema, doc, ""); len(errs) > 0 {
+			t.Errorf("%s: %s", name, strings.Join(errs, "; "))
+		}
+	}
+}
+
+func TestWriteDocs_UpToDate(t *testing.T) {
+	generated := readPages(t, generateDocs(t))
+	committed := readPages(t, "../../../docs/reference")
+
+	for name, page := range generated {
+		if committed[name] != page {
+			t.Errorf("docs/reference/%s is out of date: run go generate ./steps", name)
+		}
+	}
+	for name := range committed {
+		if _, ok := generated[name]; !ok {
+			t.Errorf("docs/reference/%s is no longer generated: run go generate ./steps", name)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 // stepgen parses Go source files looking for step config structs
 // and generates JSON metadata and registry code.
+// With -schema it also writes the JSON Schema of the pipeline files, including the
+// service step types defined in the -services directory.
+// With -docs it also writes the Markdown reference of the steps and service operations.
 //
-// Usage: go run ./codegen/cmd/stepgen ./steps
+// Usage: go run ./codegen/cmd/stepgen [-schema file] [-docs dir] [-services dir] ./steps
 package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -16,6 +20,8 @@ import (
 	"regexp"
 	"strings"
 	"unicode"
+
+	"github.com/simon020286/go-pipeline/config"
 )
 
 // StepMetadata represents the complete metadata for a step type
@@ -24,6 +30,10 @@ type StepMetadata struct {
 	Category    string      `json:"category"`
 	Description string      `json:"description"`
 	Inputs      []InputMeta `json:"inputs"`
+	Ports       []string    `json:"ports,omitempty"`
+	Continuous  bool        `json:"continuous,omitempty"`
+	// continuous=configurable: the "continuous" key of the step decides
+	ContinuousConfigurable bool `json:"continuous_configurable,omitempty"`
 }
 
 // InputMeta represents an input parameter metadata
@@ -33,6 +43,7 @@ type InputMeta struct {
 	Required    bool   `json:"required"`
 	Default     string `json:"default,omitempty"`
 	Description string `json:"description,omitempty"`
+	Raw         bool   `json:"raw,omitempty"`
 }
 
 // StepsRegistry holds all discovered step metadata
@@ -42,16 +53,24 @@ type StepsRegistry struct {
 }
 
 // stepCommentRegex matches @step comments
-// Format: @step name=xxx category=xxx description=xxx
+// Format: @step name=xxx category=xxx [ports=a,b] [continuous=true|configurable] description=xxx
 var stepCommentRegex = regexp.MustCompile(`@step\s+(.+)`)
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <directory>\n", os.Args[0])
+	schemaPath := flag.String("schema", "", "write the JSON Schema of pipeline files to this file")
+	docsDir := flag.String("docs", "", "write the Markdown reference of the steps and services to this directory")
+	servicesDir := flag.String("services", "", "directory of the service definitions to include in the schema and the docs")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-schema file] [-docs dir] [-services dir] <directory>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	dir := os.Args[1]
+	dir := flag.Arg(0)
 	steps, err := parseDirectory(dir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing directory: %v\n", err)
@@ -83,6 +102,36 @@ func main() {
 		os.Exit(1)
 	}
 	fmt.Printf("Generated %s\n", goPath)
+
+	var services []*config.ServiceDefinition
+	if *servicesDir != "" {
+		services, err = loadServices(*servicesDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading services: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *schemaPath != "" {
+		if err := writeJSON(*schemaPath, buildSchema(steps, services)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing schema: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Generated %s\n", *schemaPath)
+	}
+
+	if *docsDir != "" {
+		structs, err := parseStructTypes(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing directory: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeDocs(*docsDir, steps, structs, services); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing docs: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Generated %s\n", *docsDir)
+	}
 }
 
 func parseDirectory(dir string) ([]StepMetadata, error) {
@@ -181,6 +230,15 @@ func parseStepComment(doc *ast.CommentGroup) *StepMetadata {
 		meta.Name = extractValue(params, "name")
 		meta.Category = extractValue(params, "category")
 		meta.Description = extractValue(params, "description")
+		if ports := extractValue(params, "ports"); ports != "" {
+			meta.Ports = strings.Split(ports, ",")
+		}
+		switch extractValue(params, "continuous") {
+		case "true":
+			meta.Continuous = true
+		case "configurable":
+			meta.ContinuousConfigurable = true
+		}
 
 		if meta.Name != "" {
 			return meta
@@ -208,7 +266,7 @@ func extractValue(params, key string) string {
 	rest := params[start:]
 
 	// Look for next key= pattern
-	nextKeyPatterns := []string{" name=", " category=", " description="}
+	nextKeyPatterns := []string{" name=", " category=", " ports=", " continuous=", " description="}
 	end := len(rest)
 	for _, pattern := range nextKeyPatterns {
 		if idx := strings.Index(rest, pattern); idx != -1 && idx < end {
@@ -260,6 +318,12 @@ func parseStepTag(tag string, input *InputMeta) {
 			continue
 		}
 
+		// raw marks source code: the value is not preprocessed ($ references, ${{ }})
+		if part == "raw" {
+			input.Raw = true
+			continue
+		}
+
 		if strings.HasPrefix(part, "name=") {
 			input.Name = strings.TrimPrefix(part, "name=")
 			continue
@@ -270,6 +334,12 @@ func parseStepTag(tag string, input *InputMeta) {
 			continue
 		}
 
+		// type documents the value of dynamic fields (config.ValueSpec)
+		if strings.HasPrefix(part, "type=") {
+			input.Type = strings.TrimPrefix(part, "type=")
+			continue
+		}
+
 		if strings.HasPrefix(part, "desc=") {
 			input.Description = strings.TrimPrefix(part, "desc=")
 			continue
@@ -290,7 +360,14 @@ func typeToString(expr ast.Expr) string {
 	case *ast.StarExpr:
 		return "*" + typeToString(t.X)
 	case *ast.SelectorExpr:
-		return typeToString(t.X) + "." + t.Sel.Name
+		switch name := typeToString(t.X) + "." + t.Sel.Name; name {
+		case "config.ValueSpec":
+			return "any"
+		case "time.Duration":
+			return "duration"
+		default:
+			return name
+		}
 	default:
 		return "any"
 	}
@@ -340,12 +417,15 @@ func toSnakeCase(s string) string {
 	return output
 }
 
-func writeJSON(path string, registry StepsRegistry) error {
-	data, err := json.MarshalIndent(registry, "", "  ")
+func writeJSON(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
 }
 
 func writeGoRegistry(path string, registry StepsRegistry) error {
@@ -359,37 +439,34 @@ package steps
 
 import (
 	"encoding/json"
+
+	"github.com/simon020286/go-pipeline/config"
 )
 
 // StepMetadata represents the complete metadata for a step type
-type StepMetadata struct {
-	Name        string      %[1]sjson:"name"%[1]s
-	Category    string      %[1]sjson:"category"%[1]s
-	Description string      %[1]sjson:"description"%[1]s
-	Inputs      []InputMeta %[1]sjson:"inputs"%[1]s
-}
+type StepMetadata = config.StepMetadata
 
 // InputMeta represents an input parameter metadata
-type InputMeta struct {
-	Name        string %[1]sjson:"name"%[1]s
-	Type        string %[1]sjson:"type"%[1]s
-	Required    bool   %[1]sjson:"required"%[1]s
-	Default     string %[1]sjson:"default,omitempty"%[1]s
-	Description string %[1]sjson:"description,omitempty"%[1]s
-}
+type InputMeta = config.InputMeta
 
 // stepsMetadataJSON contains the embedded JSON metadata
 var stepsMetadataJSON = %[1]s%[2]s%[1]s
 
-var stepsMetadata []StepMetadata
+// stepsMetadata is loaded before the init functions of the package,
+// so that builder.RegisterStepType finds the metadata of each step type
+var stepsMetadata = loadStepsMetadata()
 
-func init() {
+func loadStepsMetadata() []StepMetadata {
 	var registry struct {
 		Steps []StepMetadata %[1]sjson:"steps"%[1]s
 	}
-	if err := json.Unmarshal([]byte(stepsMetadataJSON), &registry); err == nil {
-		stepsMetadata = registry.Steps
+	if err := json.Unmarshal([]byte(stepsMetadataJSON), &registry); err != nil {
+		return nil
+	}
+	for _, step := range registry.Steps {
+		config.RegisterStepMetadata(step)
 	}
+	return registry.Steps
 }
 
 // GetStepsMetadata returns the metadata for all registered steps
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/simon020286/go-pipeline/config"
+	"gopkg.in/yaml.v3"
+)
+
+// schemaID is the $id of the generated JSON Schema
+const schemaID = "https://github.com/simon020286/go-pipeline/schema/pipeline.schema.json"
+
+// object is a JSON Schema node
+type object = map[string]any
+
+// loadServices loads the service definitions (*.yaml, *.yml) of a directory, sorted by name
+// Like the builder, a definition without a name takes the name of its file
+func loadServices(dir string) ([]*config.ServiceDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*config.ServiceDefinition
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var def config.ServiceDefinition
+		if err := yaml.Unmarshal(data, &def); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", entry.Name(), err)
+		}
+		if def.Service.Name == "" {
+			def.Service.Name = strings.TrimSuffix(entry.Name(), ext)
+		}
+		if err := def.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		services = append(services, &def)
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Service.Name < services[j].Service.Name })
+	return services, nil
+}
+
+// buildSchema returns the JSON Schema of a pipeline file
+// step_config is a union discriminated by step_type: each step type has a definition
+// ("step.<name>") selected with if/then, and service steps are discriminated again by operation
+func buildSchema(steps []StepMetadata, services []*config.ServiceDefinition) object {
+	definitions := object{
+		"stage":          stageSchema(steps, services),
+		"stage_template": stageTemplateSchema(),
+		"parameter":      parameterSchema(),
+	}
+
+	var stepTypes []string
+	for _, step := range steps {
+		stepTypes = append(stepTypes, step.Name)
+		definitions["step."+step.Name] = stepConfigSchema(step)
+	}
+	for _, def := range services {
+		stepTypes = append(stepTypes, def.Service.Name)
+		definitions["step."+def.Service.Name] = serviceConfigSchema(def)
+	}
+	sort.Strings(stepTypes)
+
+	stage := definitions["stage"].(object)
+	stage["properties"].(object)["step_type"] = object{
+		"description": "Type of step to instantiate",
+		"enum":        stepTypes,
+	}
+
+	return object{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         schemaID,
+		"title":       "go-pipeline pipeline",
+		"description": "Pipeline configuration file (generated by stepgen)",
+		"type":        "object",
+		"properties": object{
+			"name":        object{"type": "string"},
+			"description": object{"type": "string"},
+			"variables": object{
+				"description": "Global reusable variables ($var:name)",
+				"type":        "object",
+			},
+			"secrets": object{
+				"description": "Sensitive values such as API keys and tokens ($secret:name)",
+				"type":        "object",
+			},
+			"stages": object{
+				"type":  "array",
+				"items": ref("stage"),
+			},
+			"dead_letter": object{
+				"description": "Where failed events are stored",
+				"type":        "object",
+				"properties": object{
+					"type":  object{"enum": []string{"file", "directory", "stage"}},
+					"path":  object{"type": "string", "description": "File or directory path (file and directory types)"},
+					"stage": object{"type": "string", "description": "Stage ID (stage type)"},
+				},
+				"required":             []string{"type"},
+				"additionalProperties": false,
+			},
+			"http": object{
+				"description": "Proxy, TLS and timeout of HTTP requests",
+				"type":        "object",
+				"properties": object{
+					"timeout": object{"type": "string", "description": "Request timeout, e.g. 10s (default 30s)"},
+					"proxy":   object{"type": "string", "description": "Proxy URL (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY)"},
+					"tls": object{
+						"type": "object",
+						"properties": object{
+							"ca_file":              object{"type": "string", "description": "PEM bundle of CAs trusted in addition to the system ones"},
+							"cert_file":            object{"type": "string", "description": "Client certificate (PEM), used with key_file"},
+							"key_file":             object{"type": "string", "description": "Client private key (PEM)"},
+							"insecure_skip_verify": object{"type": "boolean", "description": "Disables certificate verification (testing only)"},
+						},
+						"additionalProperties": false,
+					},
+				},
+				"additionalProperties": false,
+			},
+			"state": object{
+				"description": "Where the $state of the stages is kept",
+				"type":        "object",
+				"properties": object{
+					"type": object{"enum": []string{"memory", "file"}},
+					"path": object{"type": "string", "description": "JSON file (file type)"},
+				},
+				"required":             []string{"type"},
+				"additionalProperties": false,
+			},
+			"include": object{
+				"description": "Other pipeline files merged into this one (paths relative to this file)",
+				"type":        "array",
+				"items":       object{"type": "string"},
+			},
+			"stage_templates": object{
+				"description":          "Stage definitions instantiated with uses",
+				"type":                 "object",
+				"additionalProperties": ref("stage_template"),
+			},
+			"inputs": object{
+				"description":          "Parameters exposed to entry stages as $inputs",
+				"type":                 "object",
+				"additionalProperties": ref("parameter"),
+			},
+			"outputs": object{
+				"description":          "Output name -> stage_id or stage_id:port",
+				"type":                 "object",
+				"additionalProperties": object{"type": "string"},
+			},
+		},
+		"additionalProperties": false,
+		"definitions":          definitions,
+	}
+}
+
+// stageSchema returns the definition of a stage, with a branch per step type
+func stageSchema(steps []StepMetadata, services []*config.ServiceDefinition) object {
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	for _, def := range services {
+		names = append(names, def.Service.Name)
+	}
+	sort.Strings(names)
+
+	branches := make([]any, len(names))
+	for i, name := range names {
+		branches[i] = object{
+			"if": object{
+				"properties": object{"step_type": object{"const": name}},
+				"required":   []string{"step_type"},
+			},
+			"then": object{
+				"properties": object{"step_config": ref("step." + name)},
+			},
+		}
+	}
+
+	return object{
+		"type": "object",
+		"properties": object{
+			"id":          object{"type": "string", "description": "Unique stage ID"},
+			"step_config": object{"type": "object", "description": "Specific step configuration"},
+			"dependencies": object{
+				"description": "IDs of the stages this depends on (stage_id or stage_id:branch)",
+				"type":        "array",
+				"items":       object{"type": "string"},
+			},
+			"uses": object{"type": "string", "description": "Name of the stage template"},
+			"with": object{"type": "object", "description": "Template parameters"},
+			"inputs": object{
+				"description": "Deprecated: use dependencies",
+				"type":        "array",
+				"items":       object{"type": "string"},
+			},
+		},
+		"required":             []string{"id"},
+		"additionalProperties": false,
+		"allOf":                branches,
+	}
+}
+
+// stageTemplateSchema returns the definition of a stage template
+func stageTemplateSchema() object {
+	return object{
+		"type": "object",
+		"properties": object{
+			"description": object{"type": "string"},
+			"params": object{
+				"description":          "Parameters accepted in with",
+				"type":                 "object",
+				"additionalProperties": ref("parameter"),
+			},
+			"step_type":   object{"type": "string"},
+			"step_config": object{"type": "object"},
+		},
+		"required":             []string{"step_type"},
+		"additionalProperties": false,
+	}
+}
+
+// parameterSchema returns the definition of a ParameterDef
+func parameterSchema() object {
+	return object{
+		"type": "object",
+		"properties": object{
+			"$required":    object{"type": "boolean"},
+			"$optional":    object{"type": "boolean"},
+			"$default":     object{},
+			"$type":        object{"enum": []string{"string", "int", "float", "bool", "object", "array"}},
+			"$description": object{"type": "string"},
+		},
+		"additionalProperties": false,
+	}
+}
+
+// stepConfigSchema returns the definition of the step_config of a step described by @step
+func stepConfigSchema(step StepMetadata) object {
+	properties := object{}
+	var required []string
+	for _, input := range step.Inputs {
+		property := goTypeSchema(input.Type)
+		if input.Description != "" {
+			property["description"] = input.Description
+		}
+		if input.Default != "" {
+			property["default"] = defaultValue(input.Type, input.Default)
+		}
+		properties[input.Name] = property
+		if input.Required {
+			required = append(required, input.Name)
+		}
+	}
+
+	schema := object{
+		"description":          step.Description,
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// serviceConfigSchema returns the definition of the step_config of a service step:
+// the operation, then the parameters of the chosen operation
+func serviceConfigSchema(def *config.ServiceDefinition) object {
+	operations := sortedKeys(def.Operations)
+
+	choices := make([]any, len(operations))
+	branches := make([]any, len(operations))
+	for i, name := range operations {
+		op := def.Operations[name]
+		choices[i] = object{"const": name, "description": op.Description}
+
+		properties, required := operationParams(def, op)
+		then := object{"properties": properties}
+		if len(required) > 0 {
+			then["required"] = required
+		}
+		branches[i] = object{
+			"if": object{
+				"properties": object{"operation": object{"const": name}},
+				"required":   []string{"operation"},
+			},
+			"then": then,
+		}
+	}
+
+	return object{
+		"description": def.Service.Description,
+		"type":        "object",
+		"properties": object{
+			"operation": object{"description": "Operation of the service", "oneOf": choices},
+		},
+		"required": []string{"operation"},
+		"allOf":    branches,
+	}
+}
+
+// placeholderPattern matches the "{{.name}}" placeholders of the service templates
+var placeholderPattern = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// operationParams returns the properties of an operation: its declared parameters, the
+// global parameters of the service and the undeclared placeholders used by its templates
+func operationParams(def *config.ServiceDefinition, op config.OperationDef) (object, []string) {
+	properties := object{}
+	var required []string
+
+	used := make(map[string]bool)
+	collectPlaceholders(op.Path, used)
+	collectPlaceholders(op.QueryParams, used)
+	collectPlaceholders(op.Headers, used)
+	collectPlaceholders(op.Body, used)
+	collectPlaceholders(def.Defaults.Headers, used)
+	if def.Defaults.Auth != nil {
+		auth := def.Defaults.Auth
+		collectPlaceholders([]any{auth.Value, auth.Username, auth.Password}, used)
+	}
+	for _, name := range sortedKeys(used) {
+		properties[name] = object{}
+	}
+
+	for _, params := range []map[string]config.ParameterDef{def.GlobalParams, op.Params} {
+		for _, name := range sortedKeys(params) {
+			param := params[name]
+			properties[name] = paramSchema(param)
+			if param.IsRequired() && param.Default == nil && !contains(required, name) {
+				required = append(required, name)
+			}
+		}
+	}
+	return properties, required
+}
+
+// collectPlaceholders adds to used the parameters referenced by a service template value
+// ("{{.name}}" in strings, "$param", "$for_each" in body structures)
+func collectPlaceholders(value any, used map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		for _, match := range placeholderPattern.FindAllStringSubmatch(v, -1) {
+			used[match[1]] = true
+		}
+	case map[string]string:
+		for _, item := range v {
+			collectPlaceholders(item, used)
+		}
+	case map[string]any:
+		for key, item := range v {
+			if name, ok := item.(string); ok && (key == "$param" || key == "$for_each") {
+				used[name] = true
+				continue
+			}
+			collectPlaceholders(item, used)
+		}
+	case []any:
+		for _, item := range v {
+			collectPlaceholders(item, used)
+		}
+	}
+}
+
+// paramSchema returns the property of a ParameterDef
+// Values can always be strings, since "$js:", "$var:" and the other references are strings
+func paramSchema(param config.ParameterDef) object {
+	var property object
+	switch param.Type {
+	case "string":
+		property = object{"type": "string"}
+	case "int":
+		property = object{"type": []string{"integer", "string"}}
+	case "float":
+		property = object{"type": []string{"number", "string"}}
+	case "bool":
+		property = object{"type": []string{"boolean", "string"}}
+	case "object":
+		property = object{"type": []string{"object", "string"}}
+	case "array":
+		property = object{"type": []string{"array", "string"}}
+	default:
+		property = object{}
+	}
+	if param.Description != "" {
+		property["description"] = param.Description
+	}
+	if param.Default != nil {
+		property["default"] = param.Default
+	}
+	return property
+}
+
+// goTypeSchema returns the property of a config field from its Go type
+func goTypeSchema(goType string) object {
+	switch {
+	case goType == "string" || goType == "duration":
+		return object{"type": "string"}
+	case goType == "bool":
+		return object{"type": []string{"boolean", "string"}}
+	case strings.HasPrefix(goType, "int") || strings.HasPrefix(goType, "uint"):
+		return object{"type": []string{"integer", "string"}}
+	case strings.HasPrefix(goType, "float"):
+		return object{"type": []string{"number", "string"}}
+	case strings.HasPrefix(goType, "[]") || goType == "array":
+		return object{"type": []string{"array", "string"}}
+	case strings.HasPrefix(goType, "map[") || goType == "object":
+		return object{"type": []string{"object", "string"}}
+	default:
+		return object{}
+	}
+}
+
+// defaultValue converts the default of a step tag to the JSON type of the field
+func defaultValue(goType, value string) any {
+	switch {
+	case goType == "bool":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case strings.HasPrefix(goType, "int") || strings.HasPrefix(goType, "uint"):
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case strings.HasPrefix(goType, "float"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+// ref returns a reference to a definition
+func ref(name string) object {
+	return object{"$ref": "#/definitions/" + name}
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
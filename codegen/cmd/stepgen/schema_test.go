@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadSchema builds the schema of the steps and services of the repository, as JSON values
+func loadSchema(t *testing.T) map[string]any {
+	t.Helper()
+	steps, err := parseDirectory("../../../steps")
+	if err != nil {
+		t.Fatalf("parseDirectory failed: %v", err)
+	}
+	services, err := loadServices("../../../builder/services")
+	if err != nil {
+		t.Fatalf("loadServices failed: %v", err)
+	}
+
+	data, err := json.Marshal(buildSchema(steps, services))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return schema
+}
+
+// decodeYAML decodes a YAML document into JSON values
+func decodeYAML(t *testing.T, source []byte) any {
+	t.Helper()
+	var doc any
+	if err := yaml.Unmarshal(source, &doc); err != nil {
+		t.Fatalf("yaml.Unmarshal failed: %v", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var value any
+	_ = json.Unmarshal(data, &value)
+	return value
+}
+
+func TestBuildSchema_Examples(t *testing.T) {
+	schema := loadSchema(t)
+	v := &schemaValidator{root: schema}
+
+	// Esempi nel vecchio formato (step: {type, config})
+	legacy := map[string]bool{"cron_pipeline.yaml": true}
+
+	files, _ := filepath.Glob("../../../examples/*.yaml")
+	if len(files) == 0 {
+		t.Fatal("No examples found")
+	}
+	for _, file := range files {
+		if legacy[filepath.Base(file)] {
+			continue
+		}
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if errs := v.validate(schema, decodeYAML(t, source), ""); len(errs) > 0 {
+			t.Errorf("%s: %s", file, strings.Join(errs, "; "))
+		}
+	}
+}
+
+func TestBuildSchema_UpToDate(t *testing.T) {
+	committed, err := os.ReadFile("../../../schema/pipeline.schema.json")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	generated, err := json.MarshalIndent(loadSchema(t), "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	if strings.TrimSpace(string(committed)) != string(generated) {
+		t.Error("schema/pipeline.schema.json is out of date: run go generate ./steps")
+	}
+}
+
+func TestBuildSchema_Invalid(t *testing.T) {
+	schema := loadSchema(t)
+	v := &schemaValidator{root: schema}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"stages:\n  - id: a\n    step_type: nope\n", "/stages/0/step_type: not in enum"},
+		{"stages:\n  - id: a\n    step_type: js\n    step_config: {cod: x}\n", "/stages/0/step_config: missing required property 'code'"},
+		{"stages:\n  - id: a\n    step_type: delay\n    step_config: {ms: 10, extra: 1}\n", "/stages/0/step_config: unexpected property 'extra'"},
+		{"stages:\n  - id: a\n    step_type: if\n    step_config: {condition: [1]}\n", "/stages/0/step_config/condition: expected boolean|string"},
+		{"stages:\n  - id: a\n    step_type: jsonplaceholder\n    step_config: {operation: create_post, title: t}\n", "missing required property 'body'"},
+		{"stages:\n  - id: a\n    step_type: jsonplaceholder\n    step_config: {operation: publish}\n", "/stages/0/step_config/operation: no oneOf branch matches"},
+		{"stages:\n  - step_type: js\n    step_config: {code: x}\n", "/stages/0: missing required property 'id'"},
+		{"stage: []\n", "unexpected property 'stage'"},
+	}
+	for _, tt := range tests {
+		errs := v.validate(schema, decodeYAML(t, []byte(tt.source)), "")
+		if !strings.Contains(strings.Join(errs, "; "), tt.want) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.source, tt.want, errs)
+		}
+	}
+}
+
+// schemaValidator checks JSON values against the subset of JSON Schema used by buildSchema
+type schemaValidator struct {
+	root map[string]any
+}
+
+func (v *schemaValidator) validate(schema map[string]any, value any, path string) []string {
+	if target, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(target, "#/definitions/")
+		return v.validate(v.root["definitions"].(map[string]any)[name].(map[string]any), value, path)
+	}
+
+	var errs []string
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		fail("expected %s", typeNames(types))
+		return errs
+	}
+	if enum, ok := schema["enum"].([]any); ok && !containsValue(enum, value) {
+		fail("not in enum")
+	}
+	if constant, ok := schema["const"]; ok && constant != value {
+		fail("expected %v", constant)
+	}
+
+	if obj, ok := value.(map[string]any); ok {
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range asStrings(schema["required"]) {
+			if _, set := obj[name]; !set {
+				fail("missing required property '%s'", name)
+			}
+		}
+		for key, item := range obj {
+			if property, ok := properties[key].(map[string]any); ok {
+				errs = append(errs, v.validate(property, item, path+"/"+key)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					fail("unexpected property '%s'", key)
+				}
+			case map[string]any:
+				errs = append(errs, v.validate(additional, item, path+"/"+key)...)
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		if list, ok := value.([]any); ok {
+			for i, item := range list {
+				errs = append(errs, v.validate(items, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+	}
+
+	for _, sub := range asSchemas(schema["allOf"]) {
+		if cond, ok := sub["if"].(map[string]any); ok {
+			if len(v.validate(cond, value, path)) == 0 {
+				if then, ok := sub["then"].(map[string]any); ok {
+					errs = append(errs, v.validate(then, value, path)...)
+				}
+			}
+			continue
+		}
+		errs = append(errs, v.validate(sub, value, path)...)
+	}
+	if branches := asSchemas(schema["oneOf"]); len(branches) > 0 {
+		matches := 0
+		for _, sub := range branches {
+			if len(v.validate(sub, value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("no oneOf branch matches")
+		}
+	}
+	return errs
+}
+
+func matchesType(types, value any) bool {
+	for _, name := range asStrings(types) {
+		switch name {
+		case "object":
+			if _, ok := value.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]any); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := value.(float64); ok && f == float64(int64(f)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeNames(types any) string {
+	return strings.Join(asStrings(types), "|")
+}
+
+func asStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func asSchemas(value any) []map[string]any {
+	list, _ := value.([]any)
+	result := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if schema, ok := item.(map[string]any); ok {
+			result = append(result, schema)
+		}
+	}
+	return result
+}
+
+func containsValue(values []any, value any) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/simon020286/go-pipeline/jsruntime"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// Evaluator evaluates the expressions of a language, written as "$<lang>: expression"
+// ctx is the context of the stage run (done when the pipeline stops): long evaluations
+// should give up when it is done. Implementations must be safe for concurrent use
+type Evaluator interface {
+	Evaluate(ctx context.Context, expression string, state *models.StepInput) (any, error)
+}
+
+// ExpressionCompiler is implemented by evaluators that can check an expression in advance:
+// Compile is called when the step is created, so syntax errors fail the build
+type ExpressionCompiler interface {
+	Compile(expression string) error
+}
+
+// EvaluatorFunc adapts a function to the Evaluator interface
+type EvaluatorFunc func(ctx context.Context, expression string, state *models.StepInput) (any, error)
+
+func (f EvaluatorFunc) Evaluate(ctx context.Context, expression string, state *models.StepInput) (any, error) {
+	return f(ctx, expression, state)
+}
+
+var (
+	// evaluators contains the registered evaluators by language
+	evaluators   = make(map[string]Evaluator)
+	evaluatorsMu sync.RWMutex
+)
+
+// languagePrefix matches the "$<lang>:" prefix of an expression
+var languagePrefix = regexp.MustCompile(`^\$([a-z][a-z0-9_]*):`)
+
+// languageName matches a valid language name
+var languageName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// reservedPrefixes are the references parsed before evaluators, so they cannot be languages
+var reservedPrefixes = map[string]bool{"var": true, "secret": true, "env": true, "path": true, "param": true}
+
+// builtinLanguages are the languages registered by this package, which cannot be replaced
+var builtinLanguages = map[string]bool{"js": true, "javascript": true, "tmpl": true}
+
+// RegisterEvaluator registers the evaluator of a language, used by "$<lang>:" values
+// Languages are lowercase identifiers; var, secret, env, path and param are reserved and
+// js, javascript and tmpl are built in. It panics on an invalid or reserved name, or a nil evaluator
+func RegisterEvaluator(lang string, evaluator Evaluator) {
+	switch {
+	case !languageName.MatchString(lang):
+		panic(fmt.Sprintf("invalid evaluator language '%s' (expected a lowercase identifier)", lang))
+	case reservedPrefixes[lang]:
+		panic(fmt.Sprintf("evaluator language '%s' is reserved", lang))
+	case builtinLanguages[lang]:
+		panic(fmt.Sprintf("evaluator language '%s' is built in and cannot be replaced", lang))
+	case evaluator == nil:
+		panic(fmt.Sprintf("evaluator of language '%s' cannot be nil", lang))
+	}
+	registerEvaluator(lang, evaluator)
+}
+
+func registerEvaluator(lang string, evaluator Evaluator) {
+	evaluatorsMu.Lock()
+	defer evaluatorsMu.Unlock()
+	evaluators[lang] = evaluator
+}
+
+// GetEvaluator returns the evaluator of a language
+func GetEvaluator(lang string) (Evaluator, bool) {
+	evaluatorsMu.RLock()
+	defer evaluatorsMu.RUnlock()
+	evaluator, ok := evaluators[lang]
+	return evaluator, ok
+}
+
+// ListEvaluators returns the registered languages, sorted
+func ListEvaluators() []string {
+	evaluatorsMu.RLock()
+	defer evaluatorsMu.RUnlock()
+
+	languages := make([]string, 0, len(evaluators))
+	for lang := range evaluators {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// CutLanguagePrefix splits "$<lang>: expression" when lang has a registered evaluator
+func CutLanguagePrefix(s string) (lang, expression string, ok bool) {
+	match := languagePrefix.FindStringSubmatch(s)
+	if match == nil || reservedPrefixes[match[1]] {
+		return "", "", false
+	}
+	if _, registered := GetEvaluator(match[1]); !registered {
+		return "", "", false
+	}
+	return match[1], s[len(match[0]):], true
+}
+
+// CompileExpression checks an expression with the evaluator of its language, if it can
+func CompileExpression(lang, expression string) error {
+	if lang == "" {
+		lang = "js"
+	}
+	evaluator, ok := GetEvaluator(lang)
+	if !ok {
+		return fmt.Errorf("unsupported language: %s", lang)
+	}
+	if compiler, ok := evaluator.(ExpressionCompiler); ok {
+		return compiler.Compile(expression)
+	}
+	return nil
+}
+
+// jsEvaluator evaluates JavaScript expressions (compiled once, run on a pooled runtime)
+// Expressions run with jsruntime.ExpressionLimits and are interrupted when the stage context
+// is done, so a runaway expression cannot block a stage or a stopping pipeline
+type jsEvaluator struct{}
+
+func (jsEvaluator) Compile(expression string) error {
+	_, err := jsruntime.CompileExpression(expression)
+	return err
+}
+
+func (jsEvaluator) Evaluate(ctx context.Context, expression string, state *models.StepInput) (any, error) {
+	program, err := jsruntime.CompileExpression(expression)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JS expression '%s': %w", expression, err)
+	}
+
+	result, err := program.RunContext(ctx, state, jsruntime.ExpressionLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute JS expression '%s': %w", expression, err)
+	}
+	return result, nil
+}
+
+func init() {
+	registerEvaluator("js", jsEvaluator{})
+	registerEvaluator("javascript", jsEvaluator{})
+	registerEvaluator("tmpl", templateEvaluator{})
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func TestRegisterEvaluator(t *testing.T) {
+	RegisterEvaluator("reverse", EvaluatorFunc(func(_ context.Context, expression string, state *models.StepInput) (any, error) {
+		runes := []rune(expression)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	}))
+
+	tests := []struct {
+		input string
+		lang  string
+		ok    bool
+	}{
+		{"$reverse:abc", "reverse", true},
+		{"$js: 1 + 1", "js", true},
+		{"$tmpl:{{ .a }}", "tmpl", true},
+		{"$python: 1 + 1", "", false}, // Non registrato
+		{"$var:name", "", false},      // Riservato
+		{"$Reverse:abc", "", false},
+		{"reverse:abc", "", false},
+	}
+	for _, tt := range tests {
+		lang, _, ok := CutLanguagePrefix(tt.input)
+		if lang != tt.lang || ok != tt.ok {
+			t.Errorf("CutLanguagePrefix(%q) = %q, %v; expected %q, %v", tt.input, lang, ok, tt.lang, tt.ok)
+		}
+	}
+
+	result, err := DynamicValue{Language: "reverse", Expression: "abc"}.Resolve(&models.StepInput{})
+	if err != nil || result != "cba" {
+		t.Errorf("Expected 'cba', got %v (%v)", result, err)
+	}
+
+	languages := ListEvaluators()
+	for _, lang := range []string{"js", "reverse", "tmpl"} {
+		if !strings.Contains(strings.Join(languages, ","), lang) {
+			t.Errorf("Expected %s in %v", lang, languages)
+		}
+	}
+}
+
+func TestRegisterEvaluator_InvalidNames(t *testing.T) {
+	evaluator := EvaluatorFunc(func(context.Context, string, *models.StepInput) (any, error) { return nil, nil })
+
+	for _, lang := range []string{"", "Upper", "my-lang", "var", "secret", "env", "path", "param", "js", "javascript", "tmpl"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterEvaluator(%q) should panic", lang)
+				}
+			}()
+			RegisterEvaluator(lang, evaluator)
+		}()
+	}
+
+	// Gli evaluator predefiniti restano al loro posto
+	if result, err := (DynamicValue{Language: "js", Expression: "1 + 1"}).Resolve(&models.StepInput{}); err != nil || result != int64(2) {
+		t.Errorf("Expected the built-in js evaluator, got %v (%v)", result, err)
+	}
+}
+
+func TestJsEvaluator_StageContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	value := DynamicValue{Expression: "(() => { while (true) {} })()"}
+	_, err := value.Resolve(&models.StepInput{Ctx: ctx})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("The expression was not interrupted when the stage context was done (%s)", elapsed)
+	}
+}
+
+func TestCompileExpression(t *testing.T) {
+	if err := CompileExpression("", "1 +"); err == nil {
+		t.Error("Expected a JavaScript syntax error")
+	}
+	if err := CompileExpression("tmpl", "{{ .a "); err == nil {
+		t.Error("Expected a template syntax error")
+	}
+	if err := CompileExpression("python", "1"); err == nil {
+		t.Error("Expected an error for an unsupported language")
+	}
+}
+
+func TestTemplateEvaluator(t *testing.T) {
+	t.Setenv("REGION", "eu")
+	state := &models.StepInput{
+		Data: map[string]map[string]*models.Data{
+			"fetch": models.CreateDefaultResultData(map[string]any{
+				"user":  map[string]any{"name": " ada lovelace ", "tags": []any{"a", "b"}},
+				"count": float64(3),
+			}),
+			"route": {"valid": {Value: "ok"}, "invalid": {Value: nil}},
+		},
+		GlobalVariables: map[string]any{"base_url": "https://api.example.com"},
+		GlobalSecrets:   map[string]any{"token": "abc"},
+	}
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{`{{ var "base_url" }}/users/{{ .fetch.user.name | trim | title }}`, "https://api.example.com/users/Ada Lovelace"},
+		{`Bearer {{ secret "token" }}`, "Bearer abc"},
+		{`{{ .route.valid }} {{ .route.invalid | default "none" }}`, "ok none"},
+		{`{{ join "," .fetch.user.tags }} {{ add .fetch.count 1 }} {{ mul 2 3 }}`, "a,b 4 6"},
+		{`{{ index .fetch "missing" | default "-" }}`, "-"},
+		{`{{ dict "region" (env "REGION") | toJson }}`, `{"region":"eu"}`},
+		{`{{ .fetch.user.name | trim | upper | replace " " "_" | b64enc | b64dec }}`, "ADA_LOVELACE"},
+		{`{{ title "über élan ñandú" }} {{ trunc 3 "élan" }}`, "Über Élan Ñandú éla"},
+		{`{{ date "2006-01-02" "2024-03-01T10:00:00Z" }} {{ if empty .fetch.user.tags }}no{{ else }}tags{{ end }}`, "2024-03-01 tags"},
+	}
+
+	for _, tt := range tests {
+		got, err := DynamicValue{Language: "tmpl", Expression: tt.expression}.Resolve(state)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %q, expected %q", tt.expression, got, tt.want)
+		}
+	}
+
+	errorTests := []struct {
+		expression string
+		want       string
+	}{
+		{`{{ .fetch.missing }}`, "missing"},
+		{`{{ var "nope" }}`, "variable 'nope' not found"},
+		{`{{ div 1 0 }}`, "division by zero"},
+		{`{{ env "GO_PIPELINE_UNSET_VARIABLE" }}`, "environment variable 'GO_PIPELINE_UNSET_VARIABLE' is not set"},
+		{`{{ .a `, "invalid template"},
+	}
+	for _, tt := range errorTests {
+		_, err := DynamicValue{Language: "tmpl", Expression: tt.expression}.Resolve(state)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.expression, tt.want, err)
+		}
+	}
+
+	// I template in cache non condividono le funzioni legate all'input
+	other := &models.StepInput{GlobalVariables: map[string]any{"base_url": "http://other"}}
+	for _, input := range []*models.StepInput{state, other} {
+		got, _ := DynamicValue{Language: "tmpl", Expression: `{{ var "base_url" }}`}.Resolve(input)
+		if want := input.GlobalVariables["base_url"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+	}
+}
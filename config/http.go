@@ -0,0 +1,32 @@
+package config
+
+import "path/filepath"
+
+// HTTPConfig configures the HTTP client shared by the steps of a pipeline
+// (http_client, service steps and fetch() in js steps)
+type HTTPConfig struct {
+	Timeout string     `yaml:"timeout,omitempty"` // Request timeout, e.g. "10s" (default 30s)
+	Proxy   string     `yaml:"proxy,omitempty"`   // Proxy URL (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY)
+	TLS     *TLSConfig `yaml:"tls,omitempty"`
+}
+
+// TLSConfig configures the TLS connections of the HTTP client
+// Relative file paths are resolved from the directory of the pipeline file (by LoadPipeline)
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`              // PEM bundle of CAs trusted in addition to the system ones
+	CertFile           string `yaml:"cert_file,omitempty"`            // Client certificate (PEM), used with KeyFile
+	KeyFile            string `yaml:"key_file,omitempty"`             // Client private key (PEM)
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // Disables certificate verification (testing only)
+}
+
+// resolvePaths makes the TLS file paths absolute, relative to dir
+func (h *HTTPConfig) resolvePaths(dir string) {
+	if h == nil || h.TLS == nil {
+		return
+	}
+	for _, path := range []*string{&h.TLS.CAFile, &h.TLS.CertFile, &h.TLS.KeyFile} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dir, *path)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// ResolveInputs validates the inputs of a pipeline invocation against their definitions
+// Missing optional inputs take their default value; unknown inputs are rejected
+func ResolveInputs(defs map[string]ParameterDef, values map[string]any) (map[string]any, error) {
+	return resolveParams("input", defs, values)
+}
+
+// resolveParams validates values against parameter definitions (kind is used in error messages)
+func resolveParams(kind string, defs map[string]ParameterDef, values map[string]any) (map[string]any, error) {
+	for name := range values {
+		if _, exists := defs[name]; !exists {
+			return nil, fmt.Errorf("unknown %s '%s'", kind, name)
+		}
+	}
+
+	// Ordine stabile dei messaggi di errore
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := make(map[string]any, len(defs))
+	for _, name := range names {
+		def := defs[name]
+		value, exists := values[name]
+		if !exists || value == nil {
+			if def.IsRequired() {
+				return nil, fmt.Errorf("missing required %s '%s'", kind, name)
+			}
+			if def.Default == nil {
+				continue
+			}
+			value = def.Default
+		}
+
+		checked, err := def.CheckType(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %w", kind, name, err)
+		}
+		resolved[name] = checked
+	}
+
+	return resolved, nil
+}
+
+// CheckType verifies that a value matches the parameter $type and returns it normalized
+// (integral numbers become int for "int"); an empty $type accepts any value
+func (p ParameterDef) CheckType(value any) (any, error) {
+	switch p.Type {
+	case "":
+		return value, nil
+
+	case "string":
+		if _, ok := value.(string); ok {
+			return value, nil
+		}
+
+	case "int", "integer":
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return int(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			// I numeri decodificati da JSON sono float64
+			if f := rv.Float(); f == math.Trunc(f) {
+				return int(f), nil
+			}
+		}
+
+	case "float", "number":
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return float64(rv.Int()), nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return float64(rv.Uint()), nil
+		case reflect.Float32, reflect.Float64:
+			return rv.Float(), nil
+		}
+
+	case "bool", "boolean":
+		if _, ok := value.(bool); ok {
+			return value, nil
+		}
+
+	case "object":
+		if reflect.ValueOf(value).Kind() == reflect.Map {
+			return value, nil
+		}
+
+	case "array":
+		if kind := reflect.ValueOf(value).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return value, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported type '%s'", p.Type)
+	}
+
+	return nil, fmt.Errorf("expected %s, got %T", p.Type, value)
+}
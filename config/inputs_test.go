@@ -0,0 +1,70 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveInputs(t *testing.T) {
+	defs := map[string]ParameterDef{
+		"user_id": {Required: true, Type: "int"},
+		"format":  {Type: "string", Default: "json"},
+		"tags":    {Type: "array"},
+	}
+
+	resolved, err := ResolveInputs(defs, map[string]any{"user_id": float64(42)})
+	if err != nil {
+		t.Fatalf("ResolveInputs failed: %v", err)
+	}
+	if resolved["user_id"] != 42 {
+		t.Errorf("Expected user_id normalized to int 42, got %#v", resolved["user_id"])
+	}
+	if resolved["format"] != "json" {
+		t.Errorf("Expected default format, got %v", resolved["format"])
+	}
+	if _, exists := resolved["tags"]; exists {
+		t.Error("Optional input without default should be absent")
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]any
+		want   string
+	}{
+		{"missing required", map[string]any{}, "missing required input 'user_id'"},
+		{"wrong type", map[string]any{"user_id": "42"}, "invalid input 'user_id': expected int, got string"},
+		{"fractional int", map[string]any{"user_id": 4.2}, "invalid input 'user_id'"},
+		{"unknown input", map[string]any{"user_id": 1, "extra": true}, "unknown input 'extra'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ResolveInputs(defs, tt.values)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParameterDef_CheckType(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value any
+		ok    bool
+	}{
+		{"string", "a", true},
+		{"float", 3, true},
+		{"bool", "true", false},
+		{"object", map[string]any{"a": 1}, true},
+		{"array", []string{"a"}, true},
+		{"array", "a", false},
+		{"", struct{}{}, true},
+		{"date", "2024-01-01", false},
+	}
+	for _, tt := range tests {
+		_, err := ParameterDef{Type: tt.typ}.CheckType(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckType(%q, %#v): unexpected error %v", tt.typ, tt.value, err)
+		}
+	}
+}
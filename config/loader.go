@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadPipeline loads a pipeline configuration from a YAML file
+// Included files (paths relative to the including file) are merged first, so the including
+// file wins on variables, secrets, templates, inputs and outputs; their stages come first
+// Stage templates are instantiated, so the returned stages can be built directly
+func LoadPipeline(path string) (*PipelineConfig, error) {
+	cfg, err := loadPipelineFile(path, nil, make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	stages, err := cfg.ExpandStages()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Stages = stages
+
+	seen := make(map[string]bool, len(stages))
+	for _, stage := range stages {
+		if seen[stage.ID] {
+			return nil, fmt.Errorf("%s: duplicate stage id '%s'", path, stage.ID)
+		}
+		seen[stage.ID] = true
+	}
+
+	return cfg, nil
+}
+
+// loadPipelineFile loads a file and its includes
+// stack contains the files being loaded (to detect cycles), loaded the files already merged
+func loadPipelineFile(path string, stack []string, loaded map[string]bool) (*PipelineConfig, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	for i, including := range stack {
+		if including == absPath {
+			chain := append(append([]string{}, stack[i:]...), absPath)
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if loaded[absPath] {
+		// Già incluso da un altro file
+		return &PipelineConfig{}, nil
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline file: %w", err)
+	}
+
+	var cfg PipelineConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for i := range cfg.Stages {
+		cfg.Stages[i].BaseDir = filepath.Dir(absPath)
+		cfg.Stages[i].file = path
+	}
+	cfg.HTTP.resolvePaths(filepath.Dir(absPath))
+	cfg.State.resolvePaths(filepath.Dir(absPath))
+	cfg.DeadLetter.resolvePaths(filepath.Dir(absPath))
+
+	stack = append(stack, absPath)
+	merged := &PipelineConfig{}
+	for _, include := range cfg.Include {
+		includePath := include
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(absPath), includePath)
+		}
+
+		included, err := loadPipelineFile(includePath, stack, loaded)
+		if err != nil {
+			return nil, fmt.Errorf("%s: include %s: %w", path, include, err)
+		}
+		merged.merge(included)
+	}
+	merged.merge(&cfg)
+	merged.Include = nil
+	loaded[absPath] = true
+
+	return merged, nil
+}
+
+// merge merges other into c: maps and scalars of other win, stages are appended
+func (c *PipelineConfig) merge(other *PipelineConfig) {
+	if other.Name != "" {
+		c.Name = other.Name
+	}
+	if other.Description != "" {
+		c.Description = other.Description
+	}
+	if other.DeadLetter != nil {
+		c.DeadLetter = other.DeadLetter
+	}
+	if other.HTTP != nil {
+		c.HTTP = other.HTTP
+	}
+	if other.State != nil {
+		c.State = other.State
+	}
+	c.Stages = append(c.Stages, other.Stages...)
+
+	c.Variables = mergeInto(c.Variables, other.Variables)
+	c.Secrets = mergeInto(c.Secrets, other.Secrets)
+	c.StageTemplates = mergeInto(c.StageTemplates, other.StageTemplates)
+	c.Inputs = mergeInto(c.Inputs, other.Inputs)
+	c.Outputs = mergeInto(c.Outputs, other.Outputs)
+}
+
+// mergeInto copies the entries of src into dst, allocating dst if needed
+func mergeInto[V any](dst, src map[string]V) map[string]V {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]V, len(src))
+	}
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestLoadPipeline_IncludesAndTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "shared/templates.yaml", `
+variables:
+  base_url: "https://api.example.com"
+  timeout: 30
+http:
+  timeout: 5s
+  tls:
+    ca_file: certs/ca.pem
+stage_templates:
+  fetch:
+    params:
+      path:
+        $required: true
+        $type: string
+      method:
+        $default: GET
+    step_type: http_client
+    step_config:
+      url: $param:path
+      method: $param:method
+      headers:
+        Accept: application/json
+        X-Source: pipeline
+`)
+	path := writeFile(t, dir, "orders.yaml", `
+name: orders
+include:
+  - shared/templates.yaml
+variables:
+  timeout: 10
+stages:
+  - id: fetch_orders
+    uses: fetch
+    with:
+      path: /orders
+    step_config:
+      headers:
+        X-Source: orders
+  - id: log
+    step_type: js
+    step_config:
+      code: "return ctx.fetch_orders"
+    dependencies: [fetch_orders]
+`)
+
+	cfg, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+
+	if cfg.Name != "orders" || cfg.Variables["base_url"] != "https://api.example.com" || cfg.Variables["timeout"] != 10 {
+		t.Errorf("Unexpected merged config: name=%s variables=%v", cfg.Name, cfg.Variables)
+	}
+	if len(cfg.Stages) != 2 {
+		t.Fatalf("Expected 2 stages, got %d", len(cfg.Stages))
+	}
+
+	fetch := cfg.Stages[0]
+	if fetch.StepType != "http_client" || fetch.Uses != "" {
+		t.Errorf("Template not instantiated: %+v", fetch)
+	}
+	if fetch.StepConfig["url"] != "/orders" || fetch.StepConfig["method"] != "GET" {
+		t.Errorf("Unexpected parameters: %v", fetch.StepConfig)
+	}
+	headers := fetch.StepConfig["headers"].(map[string]any)
+	if headers["Accept"] != "application/json" || headers["X-Source"] != "orders" {
+		t.Errorf("Override not merged: %v", headers)
+	}
+	if fetch.BaseDir != dir {
+		t.Errorf("Expected base dir %s, got %s", dir, fetch.BaseDir)
+	}
+	// I percorsi TLS sono relativi al file che li dichiara
+	if cfg.HTTP == nil || cfg.HTTP.Timeout != "5s" || cfg.HTTP.TLS.CAFile != filepath.Join(dir, "shared", "certs", "ca.pem") {
+		t.Errorf("Unexpected http settings: %+v", cfg.HTTP)
+	}
+}
+
+func TestLoadPipeline_StatePath(t *testing.T) {
+	dir := t.TempDir()
+	absolute := filepath.Join(t.TempDir(), "state.json")
+	relative := writeFile(t, dir, "pipelines/relative.yaml", "name: relative\nstate:\n  type: file\n  path: state/orders.json\n")
+	fixed := writeFile(t, dir, "pipelines/absolute.yaml", "name: absolute\nstate:\n  type: file\n  path: "+absolute+"\n")
+
+	cfg, err := LoadPipeline(relative)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+	// Il percorso dello stato è relativo al file della pipeline, non alla directory corrente
+	if want := filepath.Join(dir, "pipelines", "state", "orders.json"); cfg.State.Path != want {
+		t.Errorf("Expected state path %s, got %s", want, cfg.State.Path)
+	}
+
+	cfg, err = LoadPipeline(fixed)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+	if cfg.State.Path != absolute {
+		t.Errorf("Expected state path %s, got %s", absolute, cfg.State.Path)
+	}
+}
+
+func TestLoadPipeline_DeadLetterPath(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "pipelines/orders.yaml", "name: orders\ndead_letter:\n  type: directory\n  path: failed\n")
+
+	cfg, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+	// Come lo stato, i dead letter sono relativi al file della pipeline
+	if want := filepath.Join(dir, "pipelines", "failed"); cfg.DeadLetter.Path != want {
+		t.Errorf("Expected dead letter path %s, got %s", want, cfg.DeadLetter.Path)
+	}
+}
+
+func TestLoadPipeline_TemplateParamsInStrings(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "items.yaml", `
+stage_templates:
+  get_item:
+    params:
+      id: {$required: true, $type: int}
+      fields: {$default: [name, price]}
+    step_type: http_client
+    step_config:
+      url: "https://api.example.com/items/$param:id?fields=$param:fields"
+      timeout: $param:id
+stages:
+  - {id: item, uses: get_item, with: {id: 42}}
+`)
+
+	cfg, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+	stepConfig := cfg.Stages[0].StepConfig
+	// Dentro una stringa il parametro diventa testo; da solo conserva il tipo
+	if want := `https://api.example.com/items/42?fields=["name","price"]`; stepConfig["url"] != want {
+		t.Errorf("Expected url %s, got %v", want, stepConfig["url"])
+	}
+	if stepConfig["timeout"] != 42 {
+		t.Errorf("Expected the typed value 42, got %#v", stepConfig["timeout"])
+	}
+}
+
+func TestLoadPipeline_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", "include: [sub/b.yaml]\n")
+	writeFile(t, dir, "sub/b.yaml", "include: [../a.yaml]\n")
+	writeFile(t, dir, "tmpl.yaml", `
+stage_templates:
+  notify:
+    params:
+      channel: {$required: true}
+    step_type: js
+    step_config:
+      code: $param:message
+stages:
+  - {id: first, uses: notify, with: {channel: ops}}
+`)
+	writeFile(t, dir, "partial.yaml", `
+stage_templates:
+  notify:
+    params:
+      channel: {$required: true}
+    step_type: js
+    step_config:
+      code: "return '$param:channel: $param:message'"
+stages:
+  - {id: first, uses: notify, with: {channel: ops}}
+`)
+	writeFile(t, dir, "missing.yaml", `
+stage_templates:
+  notify:
+    params:
+      channel: {$required: true}
+    step_type: js
+stages:
+  - {id: first, uses: notify}
+`)
+	writeFile(t, dir, "duplicate.yaml", `
+include: [dup_part.yaml]
+stages:
+  - {id: same, step_type: js}
+`)
+	writeFile(t, dir, "dup_part.yaml", "stages:\n  - {id: same, step_type: js}\n")
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"a.yaml", "include cycle"},
+		{"tmpl.yaml", "undeclared parameter 'message'"},
+		{"partial.yaml", "placeholder '$param:message' refers to undeclared parameter 'message'"},
+		{"missing.yaml", "stage 'first': template 'notify': missing required parameter 'channel'"},
+		{"duplicate.yaml", "duplicate stage id 'same'"},
+		{"nope.yaml", "failed to read pipeline file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			_, err := LoadPipeline(filepath.Join(dir, tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadPipeline_DiamondInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "common.yaml", "stages:\n  - {id: shared, step_type: js}\n")
+	writeFile(t, dir, "left.yaml", "include: [common.yaml]\n")
+	writeFile(t, dir, "right.yaml", "include: [common.yaml]\n")
+	path := writeFile(t, dir, "main.yaml", "include: [left.yaml, right.yaml]\n")
+
+	cfg, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+	if len(cfg.Stages) != 1 {
+		t.Errorf("Expected the common file to be merged once, got %d stages", len(cfg.Stages))
+	}
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// PathValue extracts a value from the step input with a path expression ($path:)
+// Evaluated in Go, without a JavaScript runtime. Syntax:
+//   - "fetch.Body.user.name": the first segment is a stage ID (or $vars, $secrets), then
+//     map keys or struct fields (Go name or json tag)
+//   - "items[0]", "items[-1]": array index (negative counts from the end)
+//   - "items[*].id": projection over the elements; elements without the rest of the path are
+//     skipped, but it is an error if none of the elements has it (e.g. a misspelled key)
+//   - "headers['Content-Type']": quoted key, for keys containing dots or brackets
+//
+// As in $js: expressions, a stage with only the "default" port is its value, otherwise a map of ports
+type PathValue struct {
+	Expression string
+	segments   []pathSegment // nil if the expression was not parsed yet
+}
+
+// pathSegment is a step of a path: a key, an index or a wildcard
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s pathSegment) String() string {
+	switch {
+	case s.wildcard:
+		return "[*]"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	default:
+		return "." + s.key
+	}
+}
+
+// ParsePath compiles a path expression
+func ParsePath(expression string) (PathValue, error) {
+	segments, err := parsePathSegments(strings.TrimSpace(expression))
+	if err != nil {
+		return PathValue{}, fmt.Errorf("invalid path %q: %w", expression, err)
+	}
+	return PathValue{Expression: expression, segments: segments}, nil
+}
+
+func (p PathValue) IsStatic() bool {
+	return false
+}
+
+func (p PathValue) GetStaticValue() (any, bool) {
+	return nil, false
+}
+
+func (p PathValue) GetDynamicExpression() (DynamicValue, bool) {
+	return DynamicValue{}, false
+}
+
+func (p PathValue) Resolve(state *models.StepInput) (any, error) {
+	segments := p.segments
+	if segments == nil {
+		parsed, err := ParsePath(p.Expression)
+		if err != nil {
+			return nil, err
+		}
+		segments = parsed.segments
+	}
+
+	root := segments[0].key
+	var value any
+	switch root {
+	case "$vars":
+		value = state.GlobalVariables
+	case "$secrets":
+		value = state.GlobalSecrets
+	default:
+		state.Lock()
+		outputs, exists := state.Data[root]
+		if exists {
+			value = models.OutputValue(outputs)
+		}
+		state.Unlock()
+		if !exists {
+			return nil, fmt.Errorf("path %q not found: no stage '%s' in the input", p.Expression, root)
+		}
+	}
+
+	result, err := walkPath(value, segments[1:], root)
+	if err != nil {
+		return nil, fmt.Errorf("path %q not found: %w", p.Expression, err)
+	}
+	return result, nil
+}
+
+// walkPath applies the segments to value; at is the path walked so far (for errors)
+func walkPath(value any, segments []pathSegment, at string) (any, error) {
+	for i, segment := range segments {
+		current := reflect.ValueOf(value)
+		for current.Kind() == reflect.Pointer || current.Kind() == reflect.Interface {
+			if current.IsNil() {
+				return nil, fmt.Errorf("%s is null", at)
+			}
+			current = current.Elem()
+		}
+
+		switch {
+		case segment.wildcard:
+			if current.Kind() != reflect.Slice && current.Kind() != reflect.Array {
+				return nil, fmt.Errorf("%s is not an array (%s)", at, kindName(current))
+			}
+			results := make([]any, 0, current.Len())
+			var firstErr error
+			for j := 0; j < current.Len(); j++ {
+				item, err := walkPath(current.Index(j).Interface(), segments[i+1:], fmt.Sprintf("%s[%d]", at, j))
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				results = append(results, item)
+			}
+			if len(results) == 0 && firstErr != nil {
+				return nil, firstErr
+			}
+			return results, nil
+
+		case segment.isIndex:
+			if current.Kind() != reflect.Slice && current.Kind() != reflect.Array {
+				return nil, fmt.Errorf("%s is not an array (%s)", at, kindName(current))
+			}
+			index := segment.index
+			if index < 0 {
+				index += current.Len()
+			}
+			if index < 0 || index >= current.Len() {
+				return nil, fmt.Errorf("index %d out of range at %s (length %d)", segment.index, at, current.Len())
+			}
+			value = current.Index(index).Interface()
+
+		default:
+			field, ok := lookupKey(current, segment.key)
+			if !ok {
+				return nil, fmt.Errorf("no key '%s' at %s", segment.key, at)
+			}
+			value = field
+		}
+		at += segment.String()
+	}
+	return value, nil
+}
+
+// lookupKey reads a map key or a struct field (by Go name or json tag)
+func lookupKey(value reflect.Value, key string) (any, bool) {
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key()))
+		if !item.IsValid() {
+			return nil, false
+		}
+		return item.Interface(), true
+
+	case reflect.Struct:
+		structType := value.Type()
+		for i := 0; i < structType.NumField(); i++ {
+			field := structType.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if field.Name == key || tag == key {
+				return value.Field(i).Interface(), true
+			}
+		}
+	}
+	return nil, false
+}
+
+func kindName(value reflect.Value) string {
+	if !value.IsValid() {
+		return "null"
+	}
+	return value.Kind().String()
+}
+
+// parsePathSegments splits a path expression into segments
+func parsePathSegments(expression string) ([]pathSegment, error) {
+	var segments []pathSegment
+	i := 0
+	expectKey := true // All'inizio e dopo un punto serve una chiave
+
+	for i < len(expression) {
+		c := expression[i]
+		switch {
+		case c == '.':
+			if expectKey {
+				return nil, fmt.Errorf("empty key at offset %d", i)
+			}
+			expectKey = true
+			i++
+
+		case c == '[':
+			if expectKey && len(segments) > 0 {
+				return nil, fmt.Errorf("unexpected '[' after '.' at offset %d", i)
+			}
+			// Le chiavi tra apici possono contenere ']'
+			searchFrom := 0
+			if rest := strings.TrimLeft(expression[i+1:], " "); rest != "" && (rest[0] == '\'' || rest[0] == '"') {
+				quoteStart := len(expression[i+1:]) - len(rest) + 1
+				quoteEnd := strings.IndexByte(expression[i+1+quoteStart:], rest[0])
+				if quoteEnd < 0 {
+					return nil, fmt.Errorf("unterminated quoted key at offset %d", i)
+				}
+				searchFrom = quoteStart + quoteEnd + 1
+			}
+			end := strings.IndexByte(expression[i+searchFrom:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' at offset %d", i)
+			}
+			end += searchFrom
+			content := strings.TrimSpace(expression[i+1 : i+end])
+			segment, err := parseBracket(content)
+			if err != nil {
+				return nil, fmt.Errorf("%w at offset %d", err, i)
+			}
+			if len(segments) == 0 && !segment.isKey() {
+				return nil, fmt.Errorf("path must start with a stage ID")
+			}
+			segments = append(segments, segment)
+			expectKey = false
+			i += end + 1
+
+		default:
+			if !expectKey {
+				return nil, fmt.Errorf("expected '.' or '[' at offset %d", i)
+			}
+			end := strings.IndexAny(expression[i:], ".[")
+			if end < 0 {
+				end = len(expression) - i
+			}
+			key := expression[i : i+end]
+			if strings.ContainsAny(key, " \t]") {
+				return nil, fmt.Errorf("invalid key %q", key)
+			}
+			segments = append(segments, pathSegment{key: key})
+			expectKey = false
+			i += end
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	if expectKey {
+		return nil, fmt.Errorf("path ends with '.'")
+	}
+	return segments, nil
+}
+
+// parseBracket parses the content of [...]: *, an integer or a quoted key
+func parseBracket(content string) (pathSegment, error) {
+	if content == "*" {
+		return pathSegment{wildcard: true}, nil
+	}
+	if len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0] {
+		return pathSegment{key: content[1 : len(content)-1]}, nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return pathSegment{}, fmt.Errorf("invalid index %q", content)
+	}
+	return pathSegment{index: index, isIndex: true}, nil
+}
+
+func (s pathSegment) isKey() bool {
+	return !s.wildcard && !s.isIndex
+}
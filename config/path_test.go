@@ -0,0 +1,102 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+type pathResponse struct {
+	StatusCode int `json:"statusCode"`
+	Body       any `json:"body"`
+}
+
+func pathState() *models.StepInput {
+	return &models.StepInput{
+		Data: map[string]map[string]*models.Data{
+			"fetch": models.CreateDefaultResultData(&pathResponse{
+				StatusCode: 200,
+				Body: map[string]any{
+					"items": []any{
+						map[string]any{"id": 1, "tags": []any{"a"}},
+						map[string]any{"id": 2},
+						map[string]any{"name": "no id"},
+					},
+					"meta.info": map[string]any{"total]": 3},
+				},
+			}),
+			"check": {
+				"true":  {Value: "yes"},
+				"extra": {Value: 7},
+			},
+		},
+		GlobalVariables: map[string]any{"region": "eu"},
+	}
+}
+
+func TestPathValue_Resolve(t *testing.T) {
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{"fetch.StatusCode", 200},
+		{"fetch.statusCode", 200},
+		{"fetch.Body.items[0].id", 1},
+		{"fetch.Body.items[-2].id", 2},
+		{"fetch.Body.items[*].id", []any{1, 2}},
+		{"fetch.Body.items[0].tags[0]", "a"},
+		{"fetch.Body['meta.info'][\"total]\"]", 3},
+		{"check.true", "yes"},
+		{"$vars.region", "eu"},
+	}
+
+	state := pathState()
+	for _, tt := range tests {
+		path, err := ParsePath(tt.expr)
+		if err != nil {
+			t.Errorf("ParsePath(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		got, err := path.Resolve(state)
+		if err != nil {
+			t.Errorf("Resolve(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Resolve(%q) = %#v, expected %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestPathValue_NotFound(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"missing.x", "no stage 'missing' in the input"},
+		{"fetch.Body.total", "no key 'total' at fetch.Body"},
+		{"fetch.Body.items[5]", "index 5 out of range at fetch.Body.items (length 3)"},
+		{"fetch.Body.items.id", "no key 'id' at fetch.Body.items"},
+		{"fetch.Body.items[*].idd", "no key 'idd' at fetch.Body.items[0]"}, // Nessun elemento ha la chiave
+		{"fetch.StatusCode[0]", "fetch.StatusCode is not an array (int)"},
+	}
+
+	state := pathState()
+	for _, tt := range tests {
+		// Unparsed paths are parsed when resolved
+		_, err := PathValue{Expression: tt.expr}.Resolve(state)
+		if err == nil || !strings.Contains(err.Error(), "not found: "+tt.want) {
+			t.Errorf("Resolve(%q): expected error containing %q, got %v", tt.expr, tt.want, err)
+		}
+	}
+}
+
+func TestParsePath_Errors(t *testing.T) {
+	for _, expr := range []string{"", "fetch.", "fetch..x", "[0].x", "fetch.items[x]", "fetch.items[0", "fetch.[0]", "fetch['x"} {
+		if _, err := ParsePath(expr); err == nil {
+			t.Errorf("ParsePath(%q): expected error", expr)
+		}
+	}
+}
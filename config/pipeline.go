@@ -1,5 +1,11 @@
 package config
 
+import (
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
 // PipelineConfig represents the complete pipeline configuration from YAML
 type PipelineConfig struct {
 	Name        string                 `yaml:"name"`
@@ -7,6 +13,55 @@ type PipelineConfig struct {
 	Variables   map[string]interface{} `yaml:"variables,omitempty"` // Global reusable variables
 	Secrets     map[string]interface{} `yaml:"secrets,omitempty"`   // Sensitive values (API keys, tokens)
 	Stages      []StageConfig          `yaml:"stages"`
+	DeadLetter  *DeadLetterConfig      `yaml:"dead_letter,omitempty"` // Where failed events are stored
+	HTTP        *HTTPConfig            `yaml:"http,omitempty"`        // Proxy, TLS and timeout of HTTP requests
+	State       *StateConfig           `yaml:"state,omitempty"`       // Where the $state of the stages is kept
+
+	// Reuse (resolved by LoadPipeline)
+	Include        []string                 `yaml:"include,omitempty"`         // Other pipeline files merged into this one (paths relative to this file)
+	StageTemplates map[string]StageTemplate `yaml:"stage_templates,omitempty"` // Stage definitions instantiated with "uses"
+
+	// Pipeline as a function (see Pipeline.Invoke)
+	Inputs  map[string]ParameterDef `yaml:"inputs,omitempty"`  // Parameters exposed to entry stages as "$inputs"
+	Outputs map[string]string       `yaml:"outputs,omitempty"` // Output name -> "stage_id" or "stage_id:port"
+}
+
+// DeadLetterConfig configures where events whose stage failed are sent
+// Supported types:
+//   - "file": appends JSON lines to Path
+//   - "directory": writes one JSON file per event into Path
+//   - "stage": sends the event to the stage Stage of the same pipeline
+type DeadLetterConfig struct {
+	Type  string `yaml:"type"`            // file, directory or stage
+	Path  string `yaml:"path,omitempty"`  // File or directory path (file and directory types), relative to the pipeline file
+	Stage string `yaml:"stage,omitempty"` // Stage ID (stage type)
+}
+
+// resolvePaths makes the file or directory path absolute, relative to dir
+func (d *DeadLetterConfig) resolvePaths(dir string) {
+	if d == nil || d.Path == "" || filepath.IsAbs(d.Path) {
+		return
+	}
+	d.Path = filepath.Join(dir, d.Path)
+}
+
+// StateConfig configures where the state of the stages ($state in js steps) is kept
+// Supported types:
+//   - "memory": kept for the life of the process (default)
+//   - "file": loaded from the JSON file Path and written back when a run ends
+//
+// Both keep the values as JSON: numbers are read back as float64
+type StateConfig struct {
+	Type string `yaml:"type"`           // memory or file
+	Path string `yaml:"path,omitempty"` // JSON file (file type), relative to the pipeline file
+}
+
+// resolvePaths makes the state file path absolute, relative to dir
+func (s *StateConfig) resolvePaths(dir string) {
+	if s == nil || s.Path == "" || filepath.IsAbs(s.Path) {
+		return
+	}
+	s.Path = filepath.Join(dir, s.Path)
 }
 
 // StageConfig represents the configuration of a stage from YAML
@@ -16,8 +71,29 @@ type StageConfig struct {
 	StepConfig   map[string]interface{} `yaml:"step_config"`  // Specific step configuration
 	Dependencies []string               `yaml:"dependencies"` // IDs of stages this depends on
 
+	// Template instantiation (see StageTemplate)
+	Uses string         `yaml:"uses,omitempty"` // Name of the stage template
+	With map[string]any `yaml:"with,omitempty"` // Template parameters
+
 	// Legacy support
 	Inputs []string `yaml:"inputs,omitempty"` // Deprecated: use Dependencies
+
+	// BaseDir is the directory of the file declaring the stage (set by LoadPipeline)
+	// Relative paths in the step, like require() in js steps, are resolved from it
+	BaseDir string `yaml:"-"`
+
+	file string     // File declaring the stage (set by LoadPipeline)
+	node *yaml.Node // Decoded YAML, for the positions reported by ValidatePipeline
+}
+
+// UnmarshalYAML decodes a stage keeping its YAML node
+func (s *StageConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain StageConfig
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	s.node = node
+	return nil
 }
 
 // DependencyRef represents a parsed dependency reference
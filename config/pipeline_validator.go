@@ -0,0 +1,411 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem found by ValidatePipeline
+type Diagnostic struct {
+	File    string // File declaring the stage (empty when the configuration was not loaded from a file)
+	Line    int    // YAML position, 1-based (0 when unknown)
+	Column  int
+	Stage   string // ID of the stage the problem belongs to
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	switch {
+	case d.File != "" && d.Line > 0:
+		fmt.Fprintf(&sb, "%s:%d:%d: ", d.File, d.Line, d.Column)
+	case d.File != "":
+		fmt.Fprintf(&sb, "%s: ", d.File)
+	case d.Line > 0:
+		fmt.Fprintf(&sb, "line %d, column %d: ", d.Line, d.Column)
+	}
+	if d.Stage != "" {
+		fmt.Fprintf(&sb, "stage '%s': ", d.Stage)
+	}
+	sb.WriteString(d.Message)
+	return sb.String()
+}
+
+// Diagnostics is the list of problems of a pipeline; it is an error when not empty
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	if len(d) == 1 {
+		return d[0].String()
+	}
+	lines := make([]string, len(d))
+	for i, diagnostic := range d {
+		lines[i] = "  " + diagnostic.String()
+	}
+	return fmt.Sprintf("%d problems in the pipeline configuration:\n%s", len(d), strings.Join(lines, "\n"))
+}
+
+// Err returns the diagnostics as an error, nil when there are none
+func (d Diagnostics) Err() error {
+	if len(d) == 0 {
+		return nil
+	}
+	return d
+}
+
+// ValidatePipeline checks a pipeline configuration and returns every problem found:
+//   - stages without id or step_type, unknown stage fields, duplicate stage ids and stage templates that cannot be instantiated
+//   - unknown step types (see DeclareStepType)
+//   - missing required and unknown step_config keys (from the metadata of the step type, see RegisterStepMetadata),
+//     and for service step types unknown operations and the keys of the operation
+//   - dependencies on undefined stages and on branches the upstream step never emits
+//   - "$var:" and "$secret:" references to undefined names
+//
+// Positions are available for configurations loaded with LoadPipeline or decoded from YAML
+func ValidatePipeline(cfg *PipelineConfig) Diagnostics {
+	v := &pipelineValidator{cfg: cfg, stageTypes: make(map[string]string)}
+
+	stages := make([]StageConfig, 0, len(cfg.Stages))
+	firstNode := make(map[string]*yaml.Node)
+	for _, stage := range cfg.Stages {
+		instantiated := true
+		if stage.Uses != "" {
+			expanded, err := cfg.instantiate(stage)
+			if err != nil {
+				v.report(stage, valueNode(stage.node, "uses"), "%v", err)
+				instantiated = false
+			}
+			stage = expanded
+		}
+
+		idNode := valueNode(stage.node, "id")
+		switch first, duplicate := firstNode[stage.ID]; {
+		case stage.ID == "":
+			v.report(stage, stage.node, "missing id")
+		case duplicate && first != nil:
+			v.report(stage, idNode, "duplicate stage id '%s' (first defined at line %d)", stage.ID, first.Line)
+		case duplicate:
+			v.report(stage, idNode, "duplicate stage id '%s'", stage.ID)
+		default:
+			firstNode[stage.ID] = idNode
+			v.stageTypes[stage.ID] = stage.StepType
+		}
+		if instantiated {
+			stages = append(stages, stage)
+		}
+	}
+
+	for _, stage := range stages {
+		v.checkFields(stage)
+		v.checkStepType(stage)
+		v.checkDependencies(stage)
+		v.checkReferences(stage)
+	}
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		a, b := v.diagnostics[i], v.diagnostics[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return v.diagnostics
+}
+
+// pipelineValidator collects the diagnostics of ValidatePipeline
+type pipelineValidator struct {
+	cfg         *PipelineConfig
+	stageTypes  map[string]string // Step type by stage ID
+	diagnostics Diagnostics
+}
+
+// report adds a diagnostic positioned at node (or at the stage when node is nil)
+func (v *pipelineValidator) report(stage StageConfig, node *yaml.Node, format string, args ...any) {
+	if node == nil {
+		node = stage.node
+	}
+	d := Diagnostic{File: stage.file, Stage: stage.ID, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		d.Line, d.Column = node.Line, node.Column
+	}
+	v.diagnostics = append(v.diagnostics, d)
+}
+
+// stageFields are the keys of a stage in YAML
+var stageFields = []string{"id", "step_type", "step_config", "dependencies", "uses", "with", "inputs"}
+
+// checkFields reports the keys of the stage that are not stage fields (ignored by the decoder)
+func (v *pipelineValidator) checkFields(stage StageConfig) {
+	if stage.node == nil || stage.node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(stage.node.Content); i += 2 {
+		key := stage.node.Content[i]
+		if !slices.Contains(stageFields, key.Value) {
+			v.report(stage, key, "unknown stage field '%s'%s", key.Value, suggest(key.Value, stageFields))
+		}
+	}
+}
+
+// checkStepType checks the step type and the step_config keys it accepts
+func (v *pipelineValidator) checkStepType(stage StageConfig) {
+	typeNode := valueNode(stage.node, "step_type")
+	if stage.StepType == "" {
+		v.report(stage, nil, "missing step_type")
+		return
+	}
+	if !IsStepTypeDeclared(stage.StepType) {
+		v.report(stage, typeNode, "unknown step type '%s'%s", stage.StepType, suggest(stage.StepType, ListDeclaredStepTypes()))
+		return
+	}
+
+	meta, ok := GetStepMetadata(stage.StepType)
+	if !ok {
+		// Senza metadati (es. servizi) non si possono controllare le chiavi
+		return
+	}
+
+	configNode := valueNode(stage.node, "step_config")
+	inputs := meta.Inputs
+	if len(meta.Operations) > 0 {
+		inputs = v.operationInputs(stage, meta, configNode)
+	}
+	for _, input := range inputs {
+		if _, set := stage.StepConfig[input.Name]; input.Required && !set {
+			node := keyNode(stage.node, "step_config")
+			v.report(stage, node, "missing required key '%s' in step_config", input.Name)
+		}
+	}
+
+	names := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		if !slices.Contains(names, input.Name) {
+			names = append(names, input.Name)
+		}
+	}
+	for _, key := range sortedKeys(stage.StepConfig) {
+		if strings.HasPrefix(key, "$") {
+			// Chiavi riservate impostate dal builder
+			continue
+		}
+		if !slices.Contains(names, key) {
+			v.report(stage, keyNode(configNode, key), "unknown key '%s' for step type '%s'%s", key, stage.StepType, suggest(key, names))
+		}
+	}
+}
+
+// operationInputs checks the operation of a service stage and returns the keys it accepts
+// When the operation is missing or unknown, its keys are not required and the keys of every
+// operation are accepted, so that only the operation itself is reported
+func (v *pipelineValidator) operationInputs(stage StageConfig, meta StepMetadata, configNode *yaml.Node) []InputMeta {
+	name, set := stage.StepConfig["operation"].(string)
+	if set {
+		if op, ok := meta.Operation(name); ok {
+			return append(slices.Clone(meta.Inputs), op.Inputs...)
+		}
+		names := make([]string, len(meta.Operations))
+		for i, op := range meta.Operations {
+			names[i] = op.Name
+		}
+		v.report(stage, valueNode(configNode, "operation"), "unknown operation '%s' for step type '%s'%s", name, stage.StepType, suggest(name, names))
+	}
+
+	inputs := slices.Clone(meta.Inputs)
+	for _, op := range meta.Operations {
+		for _, input := range op.Inputs {
+			input.Required = false
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+// checkDependencies checks that the dependencies exist and can receive the branch they filter
+func (v *pipelineValidator) checkDependencies(stage StageConfig) {
+	// Come BuildFromConfig: inputs solo se dependencies è vuoto
+	field, dependencies := "dependencies", stage.Dependencies
+	if len(dependencies) == 0 {
+		field, dependencies = "inputs", stage.Inputs
+	}
+	listNode := valueNode(stage.node, field)
+
+	for _, dep := range dependencies {
+		node := findScalar(listNode, dep)
+		ref := ParseDependency(dep)
+		stepType, exists := v.stageTypes[ref.StageID]
+		if !exists {
+			v.report(stage, node, "depends on non-existent stage '%s'%s", ref.StageID, suggest(ref.StageID, sortedKeys(v.stageTypes)))
+			continue
+		}
+		if ref.Branch == "" {
+			continue
+		}
+		if meta, ok := GetStepMetadata(stepType); ok && !meta.HasPort(ref.Branch) {
+			v.report(stage, node, "depends on branch '%s' of stage '%s', but step type '%s' only emits %s",
+				ref.Branch, ref.StageID, stepType, strings.Join(meta.Ports, ", "))
+		}
+	}
+}
+
+// referencePattern matches "$var:name" and "$secret:name", alone or inside "${{ }}"
+var referencePattern = regexp.MustCompile(`^\$(var|secret):\s*(.*?)\s*$|\$\{\{\s*\$(var|secret):\s*(.*?)\s*\}\}`)
+
+// checkReferences checks the "$var:" and "$secret:" references of step_config
+func (v *pipelineValidator) checkReferences(stage StageConfig) {
+	configNode := valueNode(stage.node, "step_config")
+	meta, _ := GetStepMetadata(stage.StepType)
+	checked := make(map[string]any, len(stage.StepConfig))
+	for key, value := range stage.StepConfig {
+		if input, ok := meta.Input(key); !ok || !input.Raw {
+			// Il codice sorgente (raw) non è preprocessato: niente riferimenti
+			checked[key] = value
+		}
+	}
+	walkStrings(checked, func(s string) {
+		for _, match := range referencePattern.FindAllStringSubmatchIndex(s, -1) {
+			if match[0] > 0 && s[match[0]-1] == '$' {
+				// "$${{" è un letterale
+				continue
+			}
+			kind, name := submatch(s, match, 1), submatch(s, match, 2)
+			if kind == "" {
+				kind, name = submatch(s, match, 3), submatch(s, match, 4)
+			}
+
+			defined := v.cfg.Variables
+			if kind == "secret" {
+				defined = v.cfg.Secrets
+			}
+			if _, ok := defined[name]; !ok {
+				v.report(stage, findScalar(configNode, s), "undefined %s '%s'%s", referenceName(kind), name, suggest(name, sortedKeys(defined)))
+			}
+		}
+	})
+}
+
+// referenceName returns the name used in messages for a reference kind
+func referenceName(kind string) string {
+	if kind == "var" {
+		return "variable"
+	}
+	return kind
+}
+
+// submatch returns the submatch n of a FindStringSubmatchIndex result ("" when it did not participate)
+func submatch(s string, match []int, n int) string {
+	if match[2*n] < 0 {
+		return ""
+	}
+	return s[match[2*n]:match[2*n+1]]
+}
+
+// walkStrings calls fn for every string inside value
+func walkStrings(value any, fn func(string)) {
+	switch v := value.(type) {
+	case string:
+		fn(v)
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			walkStrings(v[key], fn)
+		}
+	case []any:
+		for _, item := range v {
+			walkStrings(item, fn)
+		}
+	}
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyNode returns the key node of key in a mapping node
+func keyNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// valueNode returns the value node of key in a mapping node
+func valueNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// findScalar returns the first scalar node below node with the given value
+func findScalar(node *yaml.Node, value string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.ScalarNode && node.Value == value {
+		return node
+	}
+	for _, child := range node.Content {
+		if found := findScalar(child, value); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// suggest returns a " (did you mean 'x'?)" hint with the candidate closest to name, if any is close
+func suggest(name string, candidates []string) string {
+	best, bestDistance := "", 3 // Al massimo 2 modifiche
+	for _, candidate := range candidates {
+		if d := editDistance(name, candidate); d < bestDistance && d < len(name) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean '%s'?)", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
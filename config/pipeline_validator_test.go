@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	DeclareStepType("test_check")
+	RegisterStepMetadata(StepMetadata{
+		Name:   "test_check",
+		Inputs: []InputMeta{{Name: "condition", Required: true}},
+		Ports:  []string{"true", "false"},
+	})
+	DeclareStepType("test_call")
+	RegisterStepMetadata(StepMetadata{
+		Name:   "test_call",
+		Inputs: []InputMeta{{Name: "url", Required: true}, {Name: "method"}, {Name: "headers"}, {Name: "script", Raw: true}},
+		Ports:  []string{"default"},
+	})
+	DeclareStepType("test_script") // Senza metadati
+	DeclareStepType("test_service")
+	RegisterStepMetadata(StepMetadata{
+		Name:   "test_service",
+		Inputs: []InputMeta{{Name: "operation", Required: true}},
+		Operations: []OperationMeta{
+			{Name: "get_item", Inputs: []InputMeta{{Name: "item_id", Required: true}}},
+			{Name: "list_items", Inputs: []InputMeta{{Name: "limit"}}},
+		},
+	})
+}
+
+func parsePipeline(t *testing.T, source string) *PipelineConfig {
+	t.Helper()
+	var cfg PipelineConfig
+	if err := yaml.Unmarshal([]byte(source), &cfg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	return &cfg
+}
+
+func TestValidatePipeline(t *testing.T) {
+	cfg := parsePipeline(t, `
+variables:
+  base_url: https://api.example.com
+secrets:
+  token: abc
+stage_templates:
+  call:
+    params:
+      path:
+        $required: true
+    step_type: test_call
+    step_config:
+      url: $param:path
+stages:
+  - id: check
+    step_type: test_check
+    step_config:
+      condition: true
+  - id: fetch
+    step_type: test_call
+    step_config:
+      url: "${{ $var:base_url }}/items"
+      methd: GET
+      headers:
+        Authorization: "Bearer ${{ $secret:tokn }}"
+    dependencies:
+      - check:yes
+      - chek
+  - id: fetch
+    step_type: test_call
+    step_config:
+      url: $var:missing
+    dependencies:
+      - check:true
+  - id: run
+    step_type: test_scrpt
+    depends_on: [fetch]
+  - id: script
+    step_type: test_script
+    step_config:
+      anything: $${{ $var:literal }}
+    inputs:
+      - fetch:default
+  - id: noop
+    step_type: test_check
+  - id: templated
+    uses: call
+  - id: templated_ok
+    uses: call
+    with:
+      path: /ok
+`)
+
+	diagnostics := ValidatePipeline(cfg)
+	expected := []struct {
+		line, column int
+		stage        string
+		message      string
+	}{
+		{23, 7, "fetch", "unknown key 'methd' for step type 'test_call' (did you mean 'method'?)"},
+		{25, 24, "fetch", "undefined secret 'tokn' (did you mean 'token'?)"},
+		{27, 9, "fetch", "depends on branch 'yes' of stage 'check', but step type 'test_check' only emits true, false"},
+		{28, 9, "fetch", "depends on non-existent stage 'chek' (did you mean 'check'?)"},
+		{29, 9, "fetch", "duplicate stage id 'fetch' (first defined at line 19)"},
+		{32, 12, "fetch", "undefined variable 'missing'"},
+		{36, 16, "run", "unknown step type 'test_scrpt' (did you mean 'test_script'?)"},
+		{37, 5, "run", "unknown stage field 'depends_on'"},
+		{44, 5, "noop", "missing required key 'condition' in step_config"},
+		{47, 11, "templated", "template 'call': missing required parameter 'path'"},
+	}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %d:\n%v", len(expected), len(diagnostics), diagnostics)
+	}
+	for i, want := range expected {
+		got := diagnostics[i]
+		if got.Line != want.line || got.Column != want.column || got.Stage != want.stage || got.Message != want.message {
+			t.Errorf("Diagnostic %d: expected %d:%d stage '%s': %s, got %s", i, want.line, want.column, want.stage, want.message, got)
+		}
+	}
+
+	var err error = diagnostics
+	if !strings.HasPrefix(err.Error(), "10 problems in the pipeline configuration:\n  line 23, column 7: stage 'fetch': unknown key") {
+		t.Errorf("Unexpected error text: %v", err)
+	}
+	var target Diagnostics
+	if !errors.As(diagnostics.Err(), &target) || len(target) != len(expected) {
+		t.Errorf("Expected Err to return the diagnostics, got %v", diagnostics.Err())
+	}
+}
+
+func TestValidatePipeline_ServiceOperations(t *testing.T) {
+	cfg := parsePipeline(t, `
+stages:
+  - id: misspelled_key
+    step_type: test_service
+    step_config:
+      operaton: get_item
+      item_id: 1
+  - id: misspelled_operation
+    step_type: test_service
+    step_config:
+      operation: get_iten
+      item_id: 1
+  - id: wrong_keys
+    step_type: test_service
+    step_config:
+      operation: get_item
+      limit: 10
+  - id: valid
+    step_type: test_service
+    step_config:
+      operation: list_items
+      limit: 10
+`)
+
+	expected := []string{
+		"line 5, column 5: stage 'misspelled_key': missing required key 'operation' in step_config",
+		"line 6, column 7: stage 'misspelled_key': unknown key 'operaton' for step type 'test_service' (did you mean 'operation'?)",
+		"line 11, column 18: stage 'misspelled_operation': unknown operation 'get_iten' for step type 'test_service' (did you mean 'get_item'?)",
+		"line 15, column 5: stage 'wrong_keys': missing required key 'item_id' in step_config",
+		"line 17, column 7: stage 'wrong_keys': unknown key 'limit' for step type 'test_service'",
+	}
+	diagnostics := ValidatePipeline(cfg)
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %d:\n%v", len(expected), len(diagnostics), diagnostics)
+	}
+	for i, want := range expected {
+		if got := diagnostics[i].String(); got != want {
+			t.Errorf("Diagnostic %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestValidatePipeline_Valid(t *testing.T) {
+	cfg := parsePipeline(t, `
+variables:
+  base_url: https://api.example.com
+stages:
+  - id: check
+    step_type: test_check
+    step_config:
+      condition: true
+  - id: fetch
+    step_type: test_call
+    step_config:
+      url: "${{ $var:base_url }}/items"
+      script: "return '${{ $var:not_a_reference }}'"
+    dependencies:
+      - check:true
+`)
+	if diagnostics := ValidatePipeline(cfg); diagnostics.Err() != nil {
+		t.Errorf("Expected no diagnostics, got %v", diagnostics)
+	}
+
+	// Configurazione costruita in Go: niente posizioni
+	built := &PipelineConfig{Stages: []StageConfig{{ID: "a", StepType: "test_call", StepConfig: map[string]any{"url": "$secret:x"}}}}
+	diagnostics := ValidatePipeline(built)
+	if len(diagnostics) != 1 || diagnostics[0].String() != "stage 'a': undefined secret 'x'" {
+		t.Errorf("Unexpected diagnostics: %v", diagnostics)
+	}
+}
+
+func TestValidatePipeline_File(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "shared.yaml", `
+stages:
+  - id: shared
+    step_type: test_cal
+`)
+	path := writeFile(t, dir, "main.yaml", `
+include:
+  - shared.yaml
+stages:
+  - id: main
+    step_type: test_call
+    step_config:
+      url: /x
+    dependencies: [shared, other]
+`)
+	cfg, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+
+	diagnostics := ValidatePipeline(cfg)
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %v", diagnostics)
+	}
+	if want := path + ":9:28: stage 'main': depends on non-existent stage 'other'"; diagnostics[0].String() != want {
+		t.Errorf("Expected %q, got %q", want, diagnostics[0])
+	}
+	if !strings.HasSuffix(diagnostics[1].File, "shared.yaml") || diagnostics[1].Line != 4 ||
+		diagnostics[1].Message != "unknown step type 'test_cal' (did you mean 'test_call'?)" {
+		t.Errorf("Unexpected diagnostic for the included file: %s", diagnostics[1])
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"sort"
+	"sync"
+)
+
+// StepMetadata describes a step type and its configuration keys
+// The metadata of the built-in steps is generated by stepgen from the @step comments
+type StepMetadata struct {
+	Name        string      `json:"name"`
+	Category    string      `json:"category"`
+	Description string      `json:"description"`
+	Inputs      []InputMeta `json:"inputs"`
+	Ports       []string    `json:"ports,omitempty"`      // Output ports the step can emit (empty = not known in advance)
+	Continuous  bool        `json:"continuous,omitempty"` // The step emits events on its own, without inputs (cron)
+	// The step is continuous when its "continuous" key is true (webhook); Continuous is then false
+	ContinuousConfigurable bool `json:"continuous_configurable,omitempty"`
+	// Operations of a service step type (synthesised by the builder from the service definition)
+	Operations []OperationMeta `json:"operations,omitempty"`
+}
+
+// OperationMeta describes an operation of a service step type
+type OperationMeta struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Method      string      `json:"method"`
+	Path        string      `json:"path"`
+	Inputs      []InputMeta `json:"inputs"`
+}
+
+// InputMeta describes a configuration key of a step type
+type InputMeta struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	Raw         bool   `json:"raw,omitempty"` // Source code: passed as written, without $ references or ${{ }}
+}
+
+// Input returns the configuration key with the given name
+func (m StepMetadata) Input(name string) (InputMeta, bool) {
+	for _, input := range m.Inputs {
+		if input.Name == name {
+			return input, true
+		}
+	}
+	return InputMeta{}, false
+}
+
+// Operation returns the operation with the given name
+func (m StepMetadata) Operation(name string) (OperationMeta, bool) {
+	for _, op := range m.Operations {
+		if op.Name == name {
+			return op, true
+		}
+	}
+	return OperationMeta{}, false
+}
+
+// HasPort reports whether the step can emit the port (always true when the ports are not known)
+func (m StepMetadata) HasPort(port string) bool {
+	if len(m.Ports) == 0 {
+		return true
+	}
+	for _, p := range m.Ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// stepTypes contains the step types that can be built, stepMetadata their description
+	stepTypes    = make(map[string]bool)
+	stepMetadata = make(map[string]StepMetadata)
+	stepTypesMu  sync.RWMutex
+)
+
+// DeclareStepType records that a step type can be built (called by builder.RegisterStepType)
+// ValidatePipeline reports the stages using step types never declared
+func DeclareStepType(name string) {
+	stepTypesMu.Lock()
+	defer stepTypesMu.Unlock()
+	stepTypes[name] = true
+}
+
+// IsStepTypeDeclared reports whether a step type has been declared
+func IsStepTypeDeclared(name string) bool {
+	stepTypesMu.RLock()
+	defer stepTypesMu.RUnlock()
+	return stepTypes[name]
+}
+
+// RegisterStepMetadata records the metadata of a step type (called by the code generated by stepgen)
+// ValidatePipeline uses it to check the keys of step_config and the branches of dependencies
+func RegisterStepMetadata(meta StepMetadata) {
+	stepTypesMu.Lock()
+	defer stepTypesMu.Unlock()
+	stepMetadata[meta.Name] = meta
+}
+
+// GetStepMetadata returns the metadata of a step type
+func GetStepMetadata(name string) (StepMetadata, bool) {
+	stepTypesMu.RLock()
+	defer stepTypesMu.RUnlock()
+	meta, ok := stepMetadata[name]
+	return meta, ok
+}
+
+// ListDeclaredStepTypes returns the declared step types, sorted
+func ListDeclaredStepTypes() []string {
+	stepTypesMu.RLock()
+	defer stepTypesMu.RUnlock()
+
+	names := make([]string, 0, len(stepTypes))
+	for name := range stepTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ParamPrefix marks a template parameter placeholder in a stage template ("$param:name")
+const ParamPrefix = "$param:"
+
+// paramPattern matches a "$param:name" placeholder inside a string
+var paramPattern = regexp.MustCompile(`\$param:([A-Za-z_][A-Za-z0-9_]*)`)
+
+// StageTemplate is a reusable stage definition instantiated by stages with "uses"
+// A string value equal to "$param:name" in StepConfig is replaced with the parameter value;
+// a placeholder inside a longer string ("/items/$param:id") is replaced with its text
+type StageTemplate struct {
+	Description string                  `yaml:"description,omitempty"`
+	Params      map[string]ParameterDef `yaml:"params,omitempty"` // Parameters accepted in "with"
+	StepType    string                  `yaml:"step_type"`
+	StepConfig  map[string]any          `yaml:"step_config"`
+}
+
+// ExpandStages returns the stages with their templates instantiated
+// A stage using a template takes the template step type (unless it sets its own) and the
+// template step configuration, over which its own step_config is merged key by key
+func (c *PipelineConfig) ExpandStages() ([]StageConfig, error) {
+	stages := make([]StageConfig, len(c.Stages))
+	for i, stage := range c.Stages {
+		if stage.Uses == "" {
+			stages[i] = stage
+			continue
+		}
+
+		expanded, err := c.instantiate(stage)
+		if err != nil {
+			return nil, fmt.Errorf("stage '%s': %w", stage.ID, err)
+		}
+		stages[i] = expanded
+	}
+	return stages, nil
+}
+
+// instantiate builds a stage from its template
+func (c *PipelineConfig) instantiate(stage StageConfig) (StageConfig, error) {
+	tmpl, exists := c.StageTemplates[stage.Uses]
+	if !exists {
+		return stage, fmt.Errorf("unknown stage template '%s'", stage.Uses)
+	}
+
+	params, err := resolveParams("parameter", tmpl.Params, stage.With)
+	if err != nil {
+		return stage, fmt.Errorf("template '%s': %w", stage.Uses, err)
+	}
+
+	stepConfig, err := substituteParams(tmpl.StepConfig, tmpl.Params, params)
+	if err != nil {
+		return stage, fmt.Errorf("template '%s': %w", stage.Uses, err)
+	}
+
+	config, _ := stepConfig.(map[string]any)
+	if config == nil {
+		config = make(map[string]any)
+	}
+	stage.StepConfig = mergeMaps(config, stage.StepConfig)
+	if stage.StepType == "" {
+		stage.StepType = tmpl.StepType
+	}
+	stage.Uses = ""
+	stage.With = nil
+	return stage, nil
+}
+
+// substituteParams returns a copy of value with the "$param:name" placeholders replaced
+func substituteParams(value any, defs map[string]ParameterDef, params map[string]any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		// Il valore intero conserva il tipo del parametro
+		if name, ok := strings.CutPrefix(v, ParamPrefix); ok && paramPattern.FindString(v) == v {
+			if _, declared := defs[name]; !declared {
+				return nil, fmt.Errorf("placeholder '%s' refers to undeclared parameter '%s'", v, name)
+			}
+			return params[name], nil
+		}
+		return substituteParamsInString(v, defs, params)
+
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			substituted, err := substituteParams(item, defs, params)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = substituted
+		}
+		return result, nil
+
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			substituted, err := substituteParams(item, defs, params)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = substituted
+		}
+		return result, nil
+
+	default:
+		return value, nil
+	}
+}
+
+// substituteParamsInString replaces the placeholders inside a string with the text of the
+// parameter values (maps and arrays as JSON, nil as an empty string)
+func substituteParamsInString(value string, defs map[string]ParameterDef, params map[string]any) (string, error) {
+	var firstErr error
+	result := paramPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		name := strings.TrimPrefix(placeholder, ParamPrefix)
+		if _, declared := defs[name]; !declared {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("placeholder '%s' refers to undeclared parameter '%s'", placeholder, name)
+			}
+			return placeholder
+		}
+
+		switch v := params[name].(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		case map[string]any, []any:
+			encoded, err := json.Marshal(v)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to format parameter '%s': %w", name, err)
+			}
+			return string(encoded)
+		default:
+			return fmt.Sprint(v)
+		}
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return result, nil
+}
+
+// mergeMaps merges override into base recursively (override wins); base is modified
+func mergeMaps(base, override map[string]any) map[string]any {
+	for key, value := range override {
+		baseMap, baseIsMap := base[key].(map[string]any)
+		overrideMap, overrideIsMap := value.(map[string]any)
+		if baseIsMap && overrideIsMap {
+			base[key] = mergeMaps(baseMap, overrideMap)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
@@ -0,0 +1,421 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// templateEvaluator evaluates Go text/template expressions ($tmpl:), always producing a string
+// The data (dot) has the stage outputs by stage ID, as in $js: and $path:; var, secret and env
+// read the globals like $var:, $secret: and $env:. Missing map keys are errors (use index or
+// default for optional values). Templates are parsed once and cached by source
+type templateEvaluator struct{}
+
+// parsedTemplates caches the parsed templates by source
+var parsedTemplates sync.Map
+
+// templateStateFuncs are replaced on every execution with the globals of the input
+var templateStateFuncs = template.FuncMap{
+	"var":    func(string) (any, error) { return nil, nil },
+	"secret": func(string) (any, error) { return nil, nil },
+}
+
+func (templateEvaluator) Compile(expression string) error {
+	_, err := parseTemplate(expression)
+	return err
+}
+
+func (templateEvaluator) Evaluate(_ context.Context, expression string, state *models.StepInput) (any, error) {
+	parsed, err := parseTemplate(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template '%s': %w", expression, err)
+	}
+
+	tmpl, err := parsed.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare template: %w", err)
+	}
+	tmpl.Funcs(template.FuncMap{
+		"var": func(name string) (any, error) {
+			return VariableReference{Name: name}.Resolve(state)
+		},
+		"secret": func(name string) (any, error) {
+			return SecretReference{Name: name}.Resolve(state)
+		},
+	})
+
+	state.Lock()
+	data := make(map[string]any, len(state.Data))
+	for stageID, outputs := range state.Data {
+		data[stageID] = models.OutputValue(outputs)
+	}
+	state.Unlock()
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template '%s': %w", expression, err)
+	}
+	return sb.String(), nil
+}
+
+// parseTemplate parses a template, or returns it from the cache
+func parseTemplate(expression string) (*template.Template, error) {
+	if cached, ok := parsedTemplates.Load(expression); ok {
+		return cached.(*template.Template), nil
+	}
+	tmpl, err := template.New("tmpl").
+		Option("missingkey=error").
+		Funcs(templateFuncs).
+		Funcs(templateStateFuncs).
+		Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := parsedTemplates.LoadOrStore(expression, tmpl)
+	return actual.(*template.Template), nil
+}
+
+// templateFuncs are the helpers of $tmpl: templates, named and ordered like sprig
+// (the value comes last, so they work in pipelines: {{ .name | trim | upper }})
+var templateFuncs = template.FuncMap{
+	// Strings
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      titleCase,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"repeat":     func(count int, s string) string { return strings.Repeat(s, max(count, 0)) },
+	"trunc":      truncate,
+	"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       join,
+	"quote":      func(value any) string { return strconv.Quote(toString(value)) },
+	"indent":     func(spaces int, s string) string { return indent(spaces, s) },
+	"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+
+	// Defaults and conditions
+	"default":  func(fallback, value any) any { return ternary(value, fallback, !isEmpty(value)) },
+	"empty":    isEmpty,
+	"coalesce": coalesce,
+	"ternary":  ternary,
+
+	// Conversions
+	"toString":     toString,
+	"toJson":       toJSON,
+	"toPrettyJson": toPrettyJSON,
+	"fromJson":     fromJSON,
+	"int":          toInt,
+	"float64":      toFloat,
+
+	// Math
+	"add": func(a, b any) (any, error) { return arithmetic(a, b, "add") },
+	"sub": func(a, b any) (any, error) { return arithmetic(a, b, "sub") },
+	"mul": func(a, b any) (any, error) { return arithmetic(a, b, "mul") },
+	"div": func(a, b any) (any, error) { return arithmetic(a, b, "div") },
+	"mod": func(a, b any) (any, error) { return arithmetic(a, b, "mod") },
+
+	// Encoding
+	"b64enc":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec":    b64decode,
+	"sha256sum": func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+	"uuidv4":    uuidv4,
+
+	// Dates
+	"now":  time.Now,
+	"date": formatDate,
+
+	// Collections
+	"list":  func(items ...any) []any { return items },
+	"dict":  dict,
+	"keys":  keys,
+	"first": func(list any) (any, error) { return listItem(list, 0) },
+	"last":  func(list any) (any, error) { return listItem(list, -1) },
+
+	// Environment
+	"env": templateEnv,
+}
+
+// templateEnv reads an environment variable like $env:, failing when it is not set or empty
+func templateEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return "", fmt.Errorf("environment variable '%s' is not set or is empty", name)
+	}
+	return value, nil
+}
+
+// titleCase capitalises the first letter (rune) of each word
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(first)) + word[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// truncate keeps the first length characters (runes) of s
+func truncate(length int, s string) string {
+	if length < 0 || utf8.RuneCountInString(s) <= length {
+		return s
+	}
+	return string([]rune(s)[:length])
+}
+
+func join(sep string, list any) (string, error) {
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return "", fmt.Errorf("join expects a list, got %T", list)
+	}
+	items := make([]string, value.Len())
+	for i := range items {
+		items[i] = toString(value.Index(i).Interface())
+	}
+	return strings.Join(items, sep), nil
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", max(spaces, 0))
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// isEmpty reports whether a value is nil, false, zero or an empty string, list or map
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+func coalesce(values ...any) any {
+	for _, value := range values {
+		if !isEmpty(value) {
+			return value
+		}
+	}
+	return nil
+}
+
+func ternary(whenTrue, whenFalse any, condition bool) any {
+	if condition {
+		return whenTrue
+	}
+	return whenFalse
+}
+
+// toString formats a value like ${{ }} interpolation: maps and lists as JSON, nil as ""
+func toString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any, []any:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toJSON(value any) (string, error) {
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+func toPrettyJSON(value any) (string, error) {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	return string(encoded), err
+}
+
+func fromJSON(s string) (any, error) {
+	var value any
+	err := json.Unmarshal([]byte(s), &value)
+	return value, err
+}
+
+func toInt(value any) (int64, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	default:
+		f, err := toFloat(v)
+		return int64(f), err
+	}
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	case nil:
+		return 0, nil
+	}
+	number := reflect.ValueOf(value)
+	switch {
+	case number.CanInt():
+		return float64(number.Int()), nil
+	case number.CanUint():
+		return float64(number.Uint()), nil
+	case number.CanFloat():
+		return number.Float(), nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", value)
+	}
+}
+
+// isInteger reports whether a value is an integer type
+func isInteger(value any) bool {
+	number := reflect.ValueOf(value)
+	return number.CanInt() || number.CanUint()
+}
+
+// arithmetic applies op to two numbers; the result is an int64 when both are integers
+func arithmetic(a, b any, op string) (any, error) {
+	if isInteger(a) && isInteger(b) {
+		x, _ := toInt(a)
+		y, _ := toInt(b)
+		switch op {
+		case "add":
+			return x + y, nil
+		case "sub":
+			return x - y, nil
+		case "mul":
+			return x * y, nil
+		case "div", "mod":
+			if y == 0 {
+				return nil, errors.New("division by zero")
+			}
+			if op == "div" {
+				return x / y, nil
+			}
+			return x % y, nil
+		}
+	}
+
+	x, err := toFloat(a)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	y, err := toFloat(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	switch op {
+	case "add":
+		return x + y, nil
+	case "sub":
+		return x - y, nil
+	case "mul":
+		return x * y, nil
+	case "div":
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return x / y, nil
+	default:
+		return nil, fmt.Errorf("%s expects integers", op)
+	}
+}
+
+func b64decode(s string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	return string(decoded), err
+}
+
+func uuidv4() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// formatDate formats a time.Time, an RFC 3339 string or Unix seconds with a Go layout
+func formatDate(layout string, value any) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return "", fmt.Errorf("date: %w", err)
+		}
+		return parsed.Format(layout), nil
+	default:
+		seconds, err := toFloat(v)
+		if err != nil {
+			return "", fmt.Errorf("date: %w", err)
+		}
+		return time.Unix(int64(seconds), 0).UTC().Format(layout), nil
+	}
+}
+
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict expects key and value pairs")
+	}
+	result := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		result[toString(pairs[i])] = pairs[i+1]
+	}
+	return result, nil
+}
+
+func keys(value any) ([]string, error) {
+	m := reflect.ValueOf(value)
+	if m.Kind() != reflect.Map {
+		return nil, fmt.Errorf("keys expects a map, got %T", value)
+	}
+	result := make([]string, 0, m.Len())
+	for _, key := range m.MapKeys() {
+		result = append(result, fmt.Sprint(key.Interface()))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// listItem returns an element of a list (negative indexes count from the end)
+func listItem(list any, index int) (any, error) {
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", list)
+	}
+	if value.Len() == 0 {
+		return nil, nil
+	}
+	if index < 0 {
+		index += value.Len()
+	}
+	return value.Index(index).Interface(), nil
+}
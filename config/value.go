@@ -1,10 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
-	"github.com/dop251/goja"
 	"github.com/simon020286/go-pipeline/models"
 )
 
@@ -47,7 +48,7 @@ func (s StaticValue) Resolve(state *models.StepInput) (any, error) {
 
 // DynamicValue represents an expression to be evaluated at runtime
 type DynamicValue struct {
-	Language   string // "js", "python", "lua", etc.
+	Language   string // A registered evaluator: "js" (default), "tmpl" or one added with RegisterEvaluator
 	Expression string // the expression to evaluate
 	Type       string // optional: "string", "number", "boolean", etc.
 }
@@ -65,82 +66,16 @@ func (d DynamicValue) GetDynamicExpression() (DynamicValue, bool) {
 }
 
 func (d DynamicValue) Resolve(state *models.StepInput) (any, error) {
-	// Evaluate the expression using the appropriate runtime
-	switch d.Language {
-	case "js", "javascript", "":
-		return d.resolveJS(state)
-	// Future: other languages
-	// case "py", "python":
-	//     return d.resolvePython(state)
-	// case "lua":
-	//     return d.resolveLua(state)
-	default:
-		return nil, fmt.Errorf("unsupported language: %s", d.Language)
-	}
-}
-
-// resolveJS evaluates a JavaScript expression using Goja
-func (d DynamicValue) resolveJS(state *models.StepInput) (any, error) {
-	runtime := goja.New()
-
-	// Build the ctx context from pipeline state
-	ctx := make(map[string]any)
-	state.Lock()
-
-	for stepName, outputs := range state.Data {
-		// If there's only a "default" output, use the value directly
-		if len(outputs) == 1 {
-			if data, ok := outputs["default"]; ok {
-				ctx[stepName] = data.Value
-				continue
-			}
-		}
-
-		// Otherwise create a map of all outputs
-		stepCtx := make(map[string]any)
-		for outName, data := range outputs {
-			stepCtx[outName] = data.Value
-		}
-		ctx[stepName] = stepCtx
-	}
-
-	// Add execution metadata
-	if state.EventID != "" {
-		ctx["_execution"] = map[string]any{
-			"id": state.EventID,
-		}
-	}
-
-	state.Unlock()
-
-	// Set the context in the JS runtime
-	if err := runtime.Set("ctx", ctx); err != nil {
-		return nil, fmt.Errorf("failed to set context: %w", err)
-	}
-
-	// Set global variables and secrets in the JS runtime
-	if state.GlobalVariables != nil {
-		if err := runtime.Set("$vars", state.GlobalVariables); err != nil {
-			return nil, fmt.Errorf("failed to set global variables: %w", err)
-		}
-	}
-
-	if state.GlobalSecrets != nil {
-		if err := runtime.Set("$secrets", state.GlobalSecrets); err != nil {
-			return nil, fmt.Errorf("failed to set global secrets: %w", err)
-		}
+	// Evaluate the expression with the evaluator of its language (see RegisterEvaluator)
+	language := d.Language
+	if language == "" {
+		language = "js"
 	}
-
-	wrappedCode := "(function() {\n return " + d.Expression + "\n})()"
-
-	// Execute the expression
-	result, err := runtime.RunString(wrappedCode)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute JS expression '%s': %w", d.Expression, err)
+	evaluator, ok := GetEvaluator(language)
+	if !ok {
+		return nil, fmt.Errorf("unsupported language: %s", d.Language)
 	}
-
-	// Return the native Go value
-	return result.Export(), nil
+	return evaluator.Evaluate(state.Context(), d.Expression, state)
 }
 
 // HasDynamicValues checks if at least one value is dynamic
@@ -255,3 +190,47 @@ func (e EnvReference) Resolve(state *models.StepInput) (any, error) {
 
 	return value, nil
 }
+
+// InterpolatedValue is a string with inline expressions ("id=${{ $vars.id }}&page=2")
+// Parts are resolved in order and concatenated: literal text is a StaticValue, each
+// expression a ValueSpec; maps and slices are formatted as JSON, nil as an empty string
+type InterpolatedValue struct {
+	Parts []ValueSpec
+}
+
+func (i InterpolatedValue) IsStatic() bool {
+	return false
+}
+
+func (i InterpolatedValue) GetStaticValue() (any, bool) {
+	return nil, false
+}
+
+func (i InterpolatedValue) GetDynamicExpression() (DynamicValue, bool) {
+	return DynamicValue{}, false
+}
+
+func (i InterpolatedValue) Resolve(state *models.StepInput) (any, error) {
+	var sb strings.Builder
+	for _, part := range i.Parts {
+		value, err := part.Resolve(state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve interpolated value: %w", err)
+		}
+
+		switch v := value.(type) {
+		case nil:
+		case string:
+			sb.WriteString(v)
+		case map[string]any, []any:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to format interpolated value: %w", err)
+			}
+			sb.Write(encoded)
+		default:
+			fmt.Fprint(&sb, v)
+		}
+	}
+	return sb.String(), nil
+}
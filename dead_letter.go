@@ -0,0 +1,323 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// deadLetterInputKey is the key under which the dead-letter stage receives failed events
+const deadLetterInputKey = "dead_letter"
+
+// DeadLetter is an event whose stage failed, together with the input that caused the failure
+type DeadLetter struct {
+	StageID   string                             `json:"stage_id"`
+	StepID    string                             `json:"step_id"`
+	EventID   string                             `json:"event_id"`
+	Attempt   int                                `json:"attempt,omitempty"` // Delivery attempt that failed
+	Error     string                             `json:"error"`
+	Timestamp time.Time                          `json:"timestamp"`
+	Data      map[string]map[string]*models.Data `json:"data"` // StepInput data of the failed event
+}
+
+// DeadLetterSink stores failed events
+type DeadLetterSink interface {
+	Write(letter DeadLetter) error
+}
+
+// JSONLDeadLetterSink appends dead letters to a JSON Lines file
+type JSONLDeadLetterSink struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewJSONLDeadLetterSink creates a sink that appends one JSON object per line to path
+func NewJSONLDeadLetterSink(path string) *JSONLDeadLetterSink {
+	return &JSONLDeadLetterSink{path: path}
+}
+
+// Write appends the dead letter to the file, creating it if needed
+func (s *JSONLDeadLetterSink) Write(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create dead-letter directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// DirectoryDeadLetterSink writes each dead letter to its own JSON file
+type DirectoryDeadLetterSink struct {
+	dir string
+}
+
+// NewDirectoryDeadLetterSink creates a sink that writes one file per dead letter into dir
+func NewDirectoryDeadLetterSink(dir string) *DirectoryDeadLetterSink {
+	return &DirectoryDeadLetterSink{dir: dir}
+}
+
+// unsafeFileChars matches characters not allowed in dead-letter file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Write stores the dead letter as <timestamp>_<stage>_<event>.json
+func (s *DirectoryDeadLetterSink) Write(letter DeadLetter) error {
+	data, err := json.MarshalIndent(letter, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create dead-letter directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s_%s_%s.json",
+		letter.Timestamp.UTC().Format("20060102T150405.000000000"),
+		unsafeFileChars.ReplaceAllString(letter.StageID, "_"),
+		unsafeFileChars.ReplaceAllString(letter.EventID, "_"))
+
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0644); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
+
+// LoadDeadLetters reads dead letters written by a JSONL file sink or a directory sink
+func LoadDeadLetters(path string) ([]DeadLetter, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letters: %w", err)
+	}
+
+	if !info.IsDir() {
+		return loadDeadLettersFile(path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter directory: %w", err)
+	}
+
+	// File names start with the timestamp, so sorting keeps the original order
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	letters := make([]DeadLetter, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letter %s: %w", name, err)
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter %s: %w", name, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// loadDeadLettersFile reads a JSON Lines dead-letter file
+func loadDeadLettersFile(path string) ([]DeadLetter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	defer file.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(text), &letter); err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter at line %d: %w", line, err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dead-letter file: %w", err)
+	}
+	return letters, nil
+}
+
+// SetDeadLetterSink sets the sink that receives events whose stage failed
+func (p *Pipeline) SetDeadLetterSink(sink DeadLetterSink) {
+	p.deadLetterSink = sink
+}
+
+// SetDeadLetterStage routes failed events to a stage of this pipeline
+// The stage must have no dependencies: it receives each failed event as input
+// under the "dead_letter" key (stage_id, step_id, event_id, attempt, error, timestamp, data)
+func (p *Pipeline) SetDeadLetterStage(stageID string) {
+	p.deadLetterStage = stageID
+}
+
+// Replay re-injects dead letters into the stages that failed them
+// The pipeline must be running and the target stages must still be accepting inputs,
+// so replay is mostly useful with streaming pipelines
+// Each call returns once the steps took the events; replaying into a stage whose step
+// has ended (e.g. after failing) returns an error instead of losing the event.
+// To replay into such a stage, start a new run (Stop, then Start) and replay into it:
+// Replay is accepted as soon as Start returns
+func (p *Pipeline) Replay(ctx context.Context, letters ...DeadLetter) error {
+	for _, letter := range letters {
+		p.runMutex.RLock()
+		target, exists := p.replayTargets[letter.StageID]
+		p.runMutex.RUnlock()
+
+		if !exists {
+			if !p.IsRunning() {
+				return fmt.Errorf("cannot replay event '%s': pipeline not running", letter.EventID)
+			}
+			return fmt.Errorf("cannot replay event '%s': stage '%s' not found", letter.EventID, letter.StageID)
+		}
+
+		input := &models.StepInput{
+			Data:            letter.Data,
+			EventID:         letter.EventID,
+			Timestamp:       time.Now(),
+			Attempt:         max(letter.Attempt, 1) + 1,
+			GlobalVariables: p.globalVariables,
+			GlobalSecrets:   p.globalSecrets,
+		}
+		if input.Data == nil {
+			input.Data = make(map[string]map[string]*models.Data)
+		}
+
+		if err := target.Replay(ctx, input); err != nil {
+			if errors.Is(err, errStageStopped) {
+				return fmt.Errorf("cannot replay event '%s': stage '%s' is no longer accepting inputs", letter.EventID, letter.StageID)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// deadLetterSubgraph returns the dead-letter stage and all stages that depend on it
+// Their errors are not dead-lettered, to avoid feedback loops
+func (p *Pipeline) deadLetterSubgraph() map[string]bool {
+	subgraph := make(map[string]bool)
+	if p.deadLetterStage == "" {
+		return subgraph
+	}
+
+	queue := []string{p.deadLetterStage}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if subgraph[id] {
+			continue
+		}
+		subgraph[id] = true
+		queue = append(queue, p.dependents[id]...)
+	}
+	return subgraph
+}
+
+// deadLetter sends a failed event to the configured sink and dead-letter stage
+func (p *Pipeline) deadLetter(ctx context.Context, stageID, stepID string, input *models.StepInput, stageErr error, stageInputs chan<- *models.StepInput) {
+	if p.deadLetterSink == nil && stageInputs == nil {
+		return
+	}
+
+	input.Lock()
+	letter := DeadLetter{
+		StageID:   stageID,
+		StepID:    stepID,
+		EventID:   input.EventID,
+		Attempt:   inputAttempt(input),
+		Error:     stageErr.Error(),
+		Timestamp: time.Now(),
+		Data:      input.Data,
+	}
+	input.Unlock()
+
+	if p.deadLetterSink != nil {
+		if err := p.deadLetterSink.Write(letter); err != nil {
+			p.eventBus.EmitPipelineError(fmt.Errorf("failed to write dead letter for stage '%s': %w", stageID, err))
+		}
+	}
+
+	if stageInputs != nil {
+		select {
+		case stageInputs <- p.deadLetterInput(letter):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// deadLetterInput builds the input received by the dead-letter stage
+func (p *Pipeline) deadLetterInput(letter DeadLetter) *models.StepInput {
+	// Flatten the failed input like the JavaScript ctx does
+	data := make(map[string]any, len(letter.Data))
+	for stageID, outputs := range letter.Data {
+		if value, ok := outputs["default"]; ok && len(outputs) == 1 {
+			data[stageID] = value.Value
+			continue
+		}
+		ports := make(map[string]any, len(outputs))
+		for port, value := range outputs {
+			ports[port] = value.Value
+		}
+		data[stageID] = ports
+	}
+
+	return &models.StepInput{
+		Data: map[string]map[string]*models.Data{
+			deadLetterInputKey: models.CreateDefaultResultData(map[string]any{
+				"stage_id":  letter.StageID,
+				"step_id":   letter.StepID,
+				"event_id":  letter.EventID,
+				"attempt":   letter.Attempt,
+				"error":     letter.Error,
+				"timestamp": letter.Timestamp,
+				"data":      data,
+			}),
+		},
+		EventID:         letter.EventID,
+		Timestamp:       time.Now(),
+		GlobalVariables: p.globalVariables,
+		GlobalSecrets:   p.globalSecrets,
+	}
+}
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// recordingStep records every input it receives until its input channel is closed
+type recordingStep struct {
+	mutex    sync.Mutex
+	inputs   []*models.StepInput
+	received chan struct{}
+}
+
+func newRecordingStep() *recordingStep {
+	return &recordingStep{received: make(chan struct{}, 10)}
+}
+
+func (r *recordingStep) IsContinuous() bool {
+	return false
+}
+
+func (r *recordingStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		for input := range inputs {
+			r.mutex.Lock()
+			r.inputs = append(r.inputs, input)
+			r.mutex.Unlock()
+			r.received <- struct{}{}
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+func (r *recordingStep) recorded() []*models.StepInput {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*models.StepInput(nil), r.inputs...)
+}
+
+func TestDeadLetter_JSONLSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq", "failed.jsonl")
+
+	p := NewPipeline()
+	source := NewStage("source", &mockStep{output: "payload"})
+	failing := NewStage("failing", &mockStep{shouldFail: true})
+	p.AddStage(source)
+	if err := p.AddStage(failing).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+	p.SetDeadLetterSink(NewJSONLDeadLetterSink(path))
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	letters, err := LoadDeadLetters(path)
+	if err != nil {
+		t.Fatalf("LoadDeadLetters failed: %v", err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %d", len(letters))
+	}
+
+	letter := letters[0]
+	if letter.StageID != "failing" {
+		t.Errorf("Expected stage 'failing', got '%s'", letter.StageID)
+	}
+	if letter.EventID == "" {
+		t.Error("Expected event ID to be recorded")
+	}
+	if letter.Error != "mock step failed" {
+		t.Errorf("Unexpected error: %s", letter.Error)
+	}
+	if got := letter.Data["source"]["default"].Value; got != "payload" {
+		t.Errorf("Expected source data 'payload', got %v", got)
+	}
+}
+
+func TestDeadLetter_DirectorySink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewDirectoryDeadLetterSink(dir)
+
+	for _, eventID := range []string{"evt/1", "evt/2"} {
+		err := sink.Write(DeadLetter{
+			StageID:   "stage one",
+			EventID:   eventID,
+			Error:     "boom",
+			Timestamp: time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	letters, err := LoadDeadLetters(dir)
+	if err != nil {
+		t.Fatalf("LoadDeadLetters failed: %v", err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %d", len(letters))
+	}
+	if letters[0].EventID != "evt/1" || letters[1].EventID != "evt/2" {
+		t.Errorf("Dead letters not in write order: %s, %s", letters[0].EventID, letters[1].EventID)
+	}
+}
+
+func TestDeadLetter_Stage(t *testing.T) {
+	p := NewPipeline()
+	source := NewStage("source", &mockStep{output: "payload"})
+	failing := NewStage("failing", &mockStep{shouldFail: true})
+	recorder := newRecordingStep()
+	p.AddStage(source)
+	if err := p.AddStage(failing).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+	p.AddStage(NewStage("dlq", recorder))
+	p.SetDeadLetterStage("dlq")
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	inputs := recorder.recorded()
+	if len(inputs) != 1 {
+		t.Fatalf("Expected 1 dead letter input, got %d", len(inputs))
+	}
+
+	letter, ok := inputs[0].Data["dead_letter"]["default"].Value.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected dead_letter map, got %v", inputs[0].Data)
+	}
+	if letter["stage_id"] != "failing" {
+		t.Errorf("Expected stage 'failing', got %v", letter["stage_id"])
+	}
+	data := letter["data"].(map[string]any)
+	if data["source"] != "payload" {
+		t.Errorf("Expected flattened source data 'payload', got %v", data["source"])
+	}
+}
+
+func TestDeadLetter_StageWithDependencies(t *testing.T) {
+	p := NewPipeline()
+	source := NewStage("source", &mockStep{output: "payload"})
+	p.AddStage(source)
+	if err := p.AddStage(NewStage("dlq", newRecordingStep())).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+	p.SetDeadLetterStage("dlq")
+
+	if err := p.Validate(); err == nil {
+		t.Error("Expected validation error for dead-letter stage with dependencies")
+	}
+}
+
+func TestPipeline_Replay(t *testing.T) {
+	p := NewPipeline()
+	trigger := NewStage("trigger", &mockStep{continuous: true, output: "tick"})
+	recorder := newRecordingStep()
+	p.AddStage(trigger)
+	if err := p.AddStage(NewStage("consumer", recorder)).After(trigger); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+
+	if err := p.Replay(context.Background(), DeadLetter{StageID: "consumer"}); err == nil {
+		t.Error("Expected error when replaying into a stopped pipeline")
+	}
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer p.Stop()
+
+	select {
+	case <-recorder.received:
+	case <-time.After(time.Second):
+		t.Fatal("Consumer did not receive the trigger output")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := p.Replay(ctx, DeadLetter{
+		StageID: "consumer",
+		EventID: "evt_replayed",
+		Data: map[string]map[string]*models.Data{
+			"trigger": models.CreateDefaultResultData("replayed"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	select {
+	case <-recorder.received:
+	case <-time.After(time.Second):
+		t.Fatal("Consumer did not receive the replayed event")
+	}
+
+	inputs := recorder.recorded()
+	replayed := inputs[len(inputs)-1]
+	if replayed.EventID != "evt_replayed" {
+		t.Errorf("Expected event 'evt_replayed', got '%s'", replayed.EventID)
+	}
+	if replayed.Attempt != 2 {
+		t.Errorf("Expected replay attempt 2, got %d", replayed.Attempt)
+	}
+	if replayed.Data["trigger"]["default"].Value != "replayed" {
+		t.Errorf("Unexpected replayed data: %v", replayed.Data)
+	}
+}
+
+func TestPipeline_ReplayIntoFailedStage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.jsonl")
+
+	p := NewPipeline()
+	trigger := NewStage("trigger", &mockStep{continuous: true, output: "tick"})
+	failing := NewStage("failing", &mockStep{shouldFail: true})
+	p.AddStage(trigger)
+	if err := p.AddStage(failing).After(trigger); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+	p.SetDeadLetterSink(NewJSONLDeadLetterSink(path))
+
+	failed := make(chan struct{}, 1)
+	p.AddListener(models.EventListenerFunc(func(event models.Event) {
+		if _, ok := event.AsStageError(); ok {
+			failed <- struct{}{}
+		}
+	}))
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer p.Stop()
+
+	select {
+	case <-failed:
+	case <-time.After(time.Second):
+		t.Fatal("The failing stage did not fail")
+	}
+
+	// The step of the stage has ended: the event must not be accepted and dropped
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := p.Replay(ctx, DeadLetter{StageID: "failing", EventID: "evt_replayed"})
+	if err == nil || !strings.Contains(err.Error(), "no longer accepting inputs") {
+		t.Errorf("Expected a 'no longer accepting inputs' error, got %v", err)
+	}
+
+	// Restart: a new run (with the step fixed) takes the stored dead letter
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	letters, err := LoadDeadLetters(path)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("Expected 1 dead letter, got %v (%v)", letters, err)
+	}
+	recorder := newRecordingStep()
+	failing.Step = recorder
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Restart failed: %v", err)
+	}
+	if err := p.Replay(ctx, letters...); err != nil {
+		t.Fatalf("Replay after the restart failed: %v", err)
+	}
+
+	for {
+		select {
+		case <-recorder.received:
+		case <-time.After(time.Second):
+			t.Fatal("The restarted stage did not receive the replayed event")
+		}
+		for _, replayed := range recorder.recorded() {
+			if replayed.EventID != letters[0].EventID {
+				continue
+			}
+			if replayed.Attempt != 2 || replayed.Data["trigger"]["default"].Value != "tick" {
+				t.Errorf("Unexpected replayed input: attempt %d, data %v", replayed.Attempt, replayed.Data)
+			}
+			return
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"errors"
 	"sync"
 	"time"
 
@@ -8,118 +9,171 @@ import (
 )
 
 // eventBus manages event distribution to registered listeners (private)
+// Every listener has its own bounded queue drained by a single goroutine,
+// so each listener receives the events in the order they were emitted
 type eventBus struct {
-	listeners []models.EventListener
-	mutex     sync.RWMutex
-	pendingWg sync.WaitGroup // Tracks events being processed
+	subscriptions []*subscription
+	nextID        ListenerID
+	mutex         sync.RWMutex
+	emitMutex     sync.Mutex     // Serializes Emit so that all listeners see the same order
+	pendingWg     sync.WaitGroup // Tracks events being processed
 }
 
 // newEventBus creates a new eventBus instance (private)
 func newEventBus() *eventBus {
 	return &eventBus{
-		listeners: make([]models.EventListener, 0),
+		subscriptions: make([]*subscription, 0),
 	}
 }
 
 // addListener registers a new listener
-func (eb *eventBus) addListener(listener models.EventListener) {
+func (eb *eventBus) addListener(listener models.EventListener, opts ListenerOptions) ListenerID {
 	eb.mutex.Lock()
 	defer eb.mutex.Unlock()
-	eb.listeners = append(eb.listeners, listener)
+	eb.nextID++
+	sub := newSubscription(eb.nextID, listener, opts, &eb.pendingWg)
+	eb.subscriptions = append(eb.subscriptions, sub)
+	return sub.id
+}
+
+// removeListener unregisters a listener, discarding the events still queued for it
+func (eb *eventBus) removeListener(id ListenerID) bool {
+	eb.mutex.Lock()
+	defer eb.mutex.Unlock()
+	for i, sub := range eb.subscriptions {
+		if sub.id == id {
+			sub.close()
+			eb.subscriptions = append(eb.subscriptions[:i], eb.subscriptions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// droppedEvents returns the number of events dropped by the overflow policy of a listener
+func (eb *eventBus) droppedEvents(id ListenerID) uint64 {
+	eb.mutex.RLock()
+	defer eb.mutex.RUnlock()
+	for _, sub := range eb.subscriptions {
+		if sub.id == id {
+			return sub.droppedEvents()
+		}
+	}
+	return 0
 }
 
 // RemoveAllListeners removes all listeners
 func (eb *eventBus) RemoveAllListeners() {
 	eb.mutex.Lock()
 	defer eb.mutex.Unlock()
-	eb.listeners = make([]models.EventListener, 0)
+	for _, sub := range eb.subscriptions {
+		sub.close()
+	}
+	eb.subscriptions = make([]*subscription, 0)
 }
 
-// Emit sends an event to all registered listeners
-func (eb *eventBus) Emit(eventType models.EventType, data map[string]interface{}) {
+// Emit queues an event for all registered listeners
+// With the OverflowBlock policy it waits until the listener queues have room
+func (eb *eventBus) Emit(payload models.EventPayload) {
+	eb.emitMutex.Lock()
+	defer eb.emitMutex.Unlock()
+
 	eb.mutex.RLock()
-	listeners := make([]models.EventListener, len(eb.listeners))
-	copy(listeners, eb.listeners)
+	subscriptions := make([]*subscription, len(eb.subscriptions))
+	copy(subscriptions, eb.subscriptions)
 	eb.mutex.RUnlock()
 
-	event := models.Event{
-		Type:      eventType,
-		Timestamp: time.Now(),
-		Data:      data,
-	}
+	event := models.NewEvent(payload)
 
-	// Notify all listeners asynchronously to avoid blocking execution
-	for _, listener := range listeners {
-		eb.pendingWg.Add(1)
-		go func(l models.EventListener) {
-			defer eb.pendingWg.Done()
-			l.OnEvent(event)
-		}(listener)
+	for _, sub := range subscriptions {
+		if sub.accepts(event) {
+			sub.enqueue(event)
+		}
 	}
 }
 
-// Wait waits for all pending events to be processed
+// Wait waits for all queued events to be processed
 func (eb *eventBus) Wait() {
 	eb.pendingWg.Wait()
 }
 
 // EmitPipelineStarted emits a pipeline start event
 func (eb *eventBus) EmitPipelineStarted(mode string) {
-	eb.Emit(models.EventPipelineStarted, map[string]interface{}{
-		"mode": mode,
-	})
+	eb.Emit(models.PipelineStartedEvent{Mode: mode})
 }
 
 // EmitPipelineCompleted emits a pipeline completion event
 func (eb *eventBus) EmitPipelineCompleted(duration time.Duration) {
-	eb.Emit(models.EventPipelineCompleted, map[string]interface{}{
-		"duration": duration,
-	})
+	eb.Emit(models.PipelineCompletedEvent{Duration: duration})
 }
 
 // EmitPipelineError emits a pipeline error event
 func (eb *eventBus) EmitPipelineError(err error) {
-	eb.Emit(models.EventPipelineError, map[string]interface{}{
-		"error": err.Error(),
-	})
+	eb.Emit(models.PipelineErrorEvent{Error: err.Error(), Err: err})
 }
 
 // EmitStageStarted emits a stage start event
-func (eb *eventBus) EmitStageStarted(stageID, stepID, eventID string) {
-	eb.Emit(models.EventStageStarted, map[string]interface{}{
-		"stage_id": stageID,
-		"step_id":  stepID,
-		"event_id": eventID,
+func (eb *eventBus) EmitStageStarted(stageID, stepID, eventID string, attempt int) {
+	eb.Emit(models.StageStartedEvent{
+		StageID: stageID,
+		StepID:  stepID,
+		EventID: eventID,
+		Attempt: attempt,
 	})
 }
 
 // EmitStageCompleted emits a stage completion event
-func (eb *eventBus) EmitStageCompleted(stageID, stepID, eventID string, duration time.Duration) {
-	eb.Emit(models.EventStageCompleted, map[string]interface{}{
-		"stage_id": stageID,
-		"step_id":  stepID,
-		"event_id": eventID,
-		"duration": duration,
+func (eb *eventBus) EmitStageCompleted(stageID, stepID, eventID string, attempt int, duration time.Duration) {
+	eb.Emit(models.StageCompletedEvent{
+		StageID:  stageID,
+		StepID:   stepID,
+		EventID:  eventID,
+		Attempt:  attempt,
+		Duration: duration,
 	})
 }
 
 // EmitStageError emits a stage error event
-func (eb *eventBus) EmitStageError(stageID, stepID, eventID string, err error) {
-	eb.Emit(models.EventStageError, map[string]interface{}{
-		"stage_id": stageID,
-		"step_id":  stepID,
-		"event_id": eventID,
-		"error":    err.Error(),
-	})
+// Errors exposing HTTPStatusCode() (e.g. HTTP client failures) also report the status code
+func (eb *eventBus) EmitStageError(stageID, stepID, eventID string, attempt int, duration time.Duration, err error) {
+	payload := models.StageErrorEvent{
+		StageID:  stageID,
+		StepID:   stepID,
+		EventID:  eventID,
+		Attempt:  attempt,
+		Duration: duration,
+		Error:    err.Error(),
+		Err:      err,
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		payload.StatusCode = statusErr.HTTPStatusCode()
+	}
+
+	eb.Emit(payload)
 }
 
 // EmitStageOutput emits a stage output event
-func (eb *eventBus) EmitStageOutput(stageID, stepID, eventID string, output map[string]*models.Data, metadata map[string]interface{}) {
-	eb.Emit(models.EventStageOutput, map[string]interface{}{
-		"stage_id": stageID,
-		"step_id":  stepID,
-		"event_id": eventID,
-		"output":   output,
-		"metadata": metadata,
+func (eb *eventBus) EmitStageOutput(stageID, stepID string, attempt int, out models.StepOutput) {
+	eb.Emit(models.StageOutputEvent{
+		StageID:   stageID,
+		StepID:    stepID,
+		EventID:   out.EventID,
+		Attempt:   attempt,
+		Output:    out.Data,
+		Timestamp: out.Timestamp,
+		Metadata:  out.Metadata,
+	})
+}
+
+// EmitStageLog emits a log line written by a step
+func (eb *eventBus) EmitStageLog(stageID, stepID, eventID string, level models.LogLevel, message string) {
+	eb.Emit(models.StageLogEvent{
+		StageID: stageID,
+		StepID:  stepID,
+		EventID: eventID,
+		Level:   level,
+		Message: message,
 	})
 }
@@ -13,6 +13,7 @@ import (
 	pipeline "github.com/simon020286/go-pipeline"
 	"github.com/simon020286/go-pipeline/builder"
 	"github.com/simon020286/go-pipeline/models"
+	"github.com/simon020286/go-pipeline/tracing"
 )
 
 func main() {
@@ -24,20 +25,21 @@ func main() {
 	pipe.AddListener(models.EventListenerFunc(func(event models.Event) {
 		var message strings.Builder
 		message.WriteString(fmt.Sprintf("Event: %s at %s", event.Type, event.Timestamp.Format("15:04:05")))
-		if event.Type == models.EventStageError {
-			if errMsg, ok := event.Data["error"].(string); ok {
-				message.WriteString(fmt.Sprintf(" | Error: %s", errMsg))
-			}
+		if stageErr, ok := event.AsStageError(); ok {
+			message.WriteString(fmt.Sprintf(" | Error: %s", stageErr.Error))
 		}
-		if event.Type == models.EventStageOutput {
-			if output, ok := event.Data["output"]; ok {
-				outputJSON, _ := json.MarshalIndent(output, "", "  ")
-				message.WriteString(fmt.Sprintf(" | Output from %s: %s", event.Data["stage_id"], string(outputJSON)))
-			}
+		if output, ok := event.AsStageOutput(); ok {
+			outputJSON, _ := json.MarshalIndent(output.Output, "", "  ")
+			message.WriteString(fmt.Sprintf(" | Output from %s: %s", output.StageID, string(outputJSON)))
 		}
 		fmt.Println(message.String())
 	}))
 
+	// Tracing: ogni richiesta al webhook diventa una trace (span per stage)
+	tracer := tracing.NewTracer(tracing.NewJSONFileExporter("webhook_traces.jsonl"))
+	tracer.SetBatchSize(1)
+	pipe.AddListener(tracer)
+
 	webhookFactory, _ := builder.GetStepFactory("webhook")
 
 	// Step 1: Webhook trigger (continuous - entry point)
@@ -133,5 +135,9 @@ func main() {
 	}
 
 	pipe.Wait()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		fmt.Printf("❌ Trace export failed: %v\n", err)
+	}
 	fmt.Println("✓ Pipeline stopped gracefully")
+	fmt.Println("Traces written to webhook_traces.jsonl")
 }
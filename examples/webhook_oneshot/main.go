@@ -34,10 +34,8 @@ func main() {
 
 	// Aggiungi listener per vedere gli eventi
 	p.AddListener(models.EventListenerFunc(func(event models.Event) {
-		if event.Type == models.EventStageOutput {
-			stageID := event.Data["stage_id"].(string)
-			output := event.Data["output"]
-			fmt.Printf("✅ Stage '%s' completed with output: %v\n", stageID, output)
+		if output, ok := event.AsStageOutput(); ok {
+			fmt.Printf("✅ Stage '%s' completed with output: %v\n", output.StageID, output.Output)
 		}
 	}))
 
@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// errStageStopped is the result of a replay the step will never receive
+var errStageStopped = errors.New("stage is no longer accepting inputs")
+
+// inputTracker forwards inputs to a step and remembers the last input the step received
+// The output channel is unbuffered, so the last delivered input is the one the step
+// is currently processing (all steps consume their inputs sequentially)
+type inputTracker struct {
+	out      chan *models.StepInput
+	replay   chan replayRequest
+	queries  chan chan *models.StepInput
+	stopped  chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	last     *models.StepInput // Owned by run; readable by others only after done is closed
+}
+
+// replayRequest is an input replayed into a running stage
+type replayRequest struct {
+	input  *models.StepInput
+	result chan error // Buffered; nil once the step took the input, errStageStopped if it never will
+}
+
+// deliveryObserver is notified before an input is offered to the step and after the step took it
+type deliveryObserver interface {
+	offered(input *models.StepInput)
+	delivered(input *models.StepInput)
+}
+
+// newInputTracker starts forwarding inputs from source and replay requests to the tracker output
+// The observer (optional) is notified about every delivery
+// The output is closed when source is closed or the context is cancelled
+func newInputTracker(ctx context.Context, source <-chan *models.StepInput, observer deliveryObserver) *inputTracker {
+	t := &inputTracker{
+		out:     make(chan *models.StepInput),
+		replay:  make(chan replayRequest),
+		queries: make(chan chan *models.StepInput),
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run(ctx, source, observer)
+	return t
+}
+
+func (t *inputTracker) run(ctx context.Context, source <-chan *models.StepInput, observer deliveryObserver) {
+	defer close(t.done)
+	defer close(t.out)
+
+	for {
+		// Wait for the next input, answering queries in the meantime
+		var next *models.StepInput
+		var request *replayRequest
+		select {
+		case input, ok := <-source:
+			if !ok {
+				return
+			}
+			next = input
+		case req := <-t.replay:
+			request = &req
+			next = req.input
+		case reply := <-t.queries:
+			reply <- t.last
+			continue
+		case <-t.stopped:
+			t.discard(ctx, source)
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		if observer != nil {
+			observer.offered(next)
+		}
+
+		// Deliver it; a query answered here still refers to the previous input
+		for delivered := false; !delivered; {
+			select {
+			case t.out <- next:
+				t.last = next
+				delivered = true
+				if observer != nil {
+					observer.delivered(next)
+				}
+				if request != nil {
+					request.result <- nil
+				}
+			case reply := <-t.queries:
+				reply <- t.last
+			case <-t.stopped:
+				if request != nil {
+					request.result <- errStageStopped
+				}
+				t.discard(ctx, source)
+				return
+			case <-ctx.Done():
+				if request != nil {
+					request.result <- errStageStopped
+				}
+				return
+			}
+		}
+	}
+}
+
+// discard drops the inputs of a stopped step, so that its producers do not block,
+// and rejects the replay requests until source is closed
+func (t *inputTracker) discard(ctx context.Context, source <-chan *models.StepInput) {
+	for {
+		select {
+		case _, ok := <-source:
+			if !ok {
+				return
+			}
+		case req := <-t.replay:
+			req.result <- errStageStopped
+		case reply := <-t.queries:
+			reply <- t.last
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop is called when the step has ended: the inputs still coming are discarded
+func (t *inputTracker) stop() {
+	t.stopOnce.Do(func() { close(t.stopped) })
+}
+
+// Replay hands an input to the step, returning once the step took it
+// It returns errStageStopped if the step has ended or ends before taking it
+func (t *inputTracker) Replay(ctx context.Context, input *models.StepInput) error {
+	request := replayRequest{input: input, result: make(chan error, 1)}
+	select {
+	case t.replay <- request:
+	case <-t.done:
+		return errStageStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Current returns the input the step is processing (nil if it received none yet)
+func (t *inputTracker) Current() *models.StepInput {
+	reply := make(chan *models.StepInput, 1)
+	select {
+	case t.queries <- reply:
+		return <-reply
+	case <-t.done:
+		return t.last
+	}
+}
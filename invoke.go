@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/simon020286/go-pipeline/config"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// InputsKey is the key of the pipeline inputs in the data of the entry stages
+// In JavaScript expressions they are available as ctx.$inputs
+const InputsKey = "$inputs"
+
+// SetInputs declares the inputs accepted by Invoke
+func (p *Pipeline) SetInputs(defs map[string]config.ParameterDef) {
+	p.inputDefs = defs
+}
+
+// SetOutputs declares the outputs returned by Invoke
+// Each output maps a name to "stage_id" (the whole output) or "stage_id:port" (one port)
+func (p *Pipeline) SetOutputs(outputs map[string]string) error {
+	defs := make(map[string]config.DependencyRef, len(outputs))
+	for name, ref := range outputs {
+		def := config.ParseDependency(ref)
+		if _, exists := p.GetStage(def.StageID); !exists {
+			return fmt.Errorf("output '%s' refers to non-existent stage '%s'", name, def.StageID)
+		}
+		defs[name] = def
+	}
+	p.outputDefs = defs
+	return nil
+}
+
+// Invoke runs the pipeline like a function: it validates inputs against the declared inputs,
+// exposes them to the entry stages, waits for the run to end and returns the declared outputs
+//
+// Each output is the list of the values of the stage outputs, in order (the "default" port,
+// the declared port or a map of all ports): one element when the stage produced one output,
+// and absent when it produced none
+// The first stage error fails the invocation; only batch pipelines can be invoked
+// A pipeline runs once at a time: an Invoke (or Start) while a run is active fails with
+// "pipeline already running", so build one pipeline per concurrent caller
+func (p *Pipeline) Invoke(ctx context.Context, inputs map[string]any) (map[string][]any, error) {
+	resolved, err := config.ResolveInputs(p.inputDefs, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if p.detectExecutionMode() == ExecutionModeStreaming {
+		return nil, fmt.Errorf("cannot invoke a streaming pipeline")
+	}
+
+	// Raccoglie gli errori degli stage
+	var errMutex sync.Mutex
+	var runErr error
+	listenerID := p.AddListenerWithOptions(models.EventListenerFunc(func(event models.Event) {
+		payload, ok := event.AsStageError()
+		if !ok {
+			return
+		}
+		errMutex.Lock()
+		defer errMutex.Unlock()
+		if runErr == nil {
+			err := payload.Err
+			if err == nil {
+				err = errors.New(payload.Error)
+			}
+			runErr = fmt.Errorf("stage '%s' failed: %w", payload.StageID, err)
+		}
+	}), ListenerOptions{EventTypes: []models.EventType{models.EventStageError}})
+	defer p.RemoveListener(listenerID)
+
+	// Raccoglie gli output dichiarati: start registra la sottoscrizione solo se l'avvio riesce,
+	// così non riceve gli output di un'altra esecuzione in corso
+	sub := &outputSubscription{
+		all:  make(chan stageOutput),
+		done: make(chan struct{}),
+	}
+
+	collected := make(map[string][]any)
+	collectorDone := make(chan struct{})
+	go func() {
+		defer close(collectorDone)
+		for item := range sub.all {
+			for name, def := range p.outputDefs {
+				if def.StageID != item.stageID {
+					continue
+				}
+				if value, ok := outputValue(item.output, def.Branch); ok {
+					collected[name] = append(collected[name], value)
+				}
+			}
+		}
+	}()
+
+	if err := p.start(ctx, resolved, sub); err != nil {
+		p.outputs.remove(sub)
+		<-collectorDone
+		return nil, err
+	}
+	p.Wait()
+	<-collectorDone
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	errMutex.Lock()
+	defer errMutex.Unlock()
+	if runErr != nil {
+		return nil, runErr
+	}
+	return collected, nil
+}
+
+// outputValue extracts the value of a stage output: one port, or the "default" port if it is
+// the only one, or a map of all ports
+func outputValue(output models.StepOutput, port string) (any, bool) {
+	if port != "" {
+		data, ok := output.Data[port]
+		if !ok || data == nil {
+			return nil, false
+		}
+		return data.Value, true
+	}
+
+	if data, ok := output.Data["default"]; ok && len(output.Data) == 1 {
+		return data.Value, true
+	}
+	values := make(map[string]any, len(output.Data))
+	for name, data := range output.Data {
+		if data != nil {
+			values[name] = data.Value
+		}
+	}
+	return values, true
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/config"
+	"gopkg.in/yaml.v3"
+)
+
+const invokeYAML = `
+name: pricing
+inputs:
+  quantity:
+    $required: true
+    $type: int
+  unit_price:
+    $type: float
+    $default: 2.5
+outputs:
+  total: total
+  label: format
+stages:
+  - id: total
+    step_type: js
+    step_config:
+      code: "return ctx.$inputs.quantity * ctx.$inputs.unit_price"
+  - id: format
+    step_type: js
+    step_config:
+      code: "if (ctx.total > 100) { throw new Error('total too high') } return 'total: ' + ctx.total"
+    dependencies: [total]
+`
+
+func buildInvokePipeline(t *testing.T) *Pipeline {
+	t.Helper()
+	var cfg config.PipelineConfig
+	if err := yaml.Unmarshal([]byte(invokeYAML), &cfg); err != nil {
+		t.Fatalf("Invalid YAML: %v", err)
+	}
+	p, err := BuildFromConfig(&cfg)
+	if err != nil {
+		t.Fatalf("BuildFromConfig failed: %v", err)
+	}
+	return p
+}
+
+func TestPipeline_Invoke(t *testing.T) {
+	p := buildInvokePipeline(t)
+
+	outputs, err := p.Invoke(context.Background(), map[string]any{"quantity": 4})
+	if err != nil {
+		t.Fatalf("Invoke failed: %v", err)
+	}
+	// Un output è sempre la lista dei valori, anche con un solo valore
+	if total := outputs["total"]; len(total) != 1 || (total[0] != 10.0 && total[0] != int64(10)) {
+		t.Errorf("Unexpected total: %#v", total)
+	}
+	if !reflect.DeepEqual(outputs["label"], []any{"total: 10"}) {
+		t.Errorf("Unexpected label: %#v", outputs["label"])
+	}
+
+	// The pipeline can be invoked again
+	outputs, err = p.Invoke(context.Background(), map[string]any{"quantity": 1, "unit_price": 3})
+	if err != nil {
+		t.Fatalf("Second Invoke failed: %v", err)
+	}
+	if !reflect.DeepEqual(outputs["label"], []any{"total: 3"}) {
+		t.Errorf("Unexpected label: %#v", outputs["label"])
+	}
+}
+
+func TestPipeline_InvokeErrors(t *testing.T) {
+	p := buildInvokePipeline(t)
+
+	if _, err := p.Invoke(context.Background(), map[string]any{"quantity": "four"}); err == nil || !strings.Contains(err.Error(), "invalid input 'quantity'") {
+		t.Errorf("Expected input validation error, got %v", err)
+	}
+	if _, err := p.Invoke(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "missing required input") {
+		t.Errorf("Expected missing input error, got %v", err)
+	}
+
+	_, err := p.Invoke(context.Background(), map[string]any{"quantity": 100})
+	if err == nil || !strings.Contains(err.Error(), "stage 'format' failed") {
+		t.Errorf("Expected stage error, got %v", err)
+	}
+}
+
+func TestPipeline_StartChecksRequiredInputs(t *testing.T) {
+	p := buildInvokePipeline(t)
+
+	err := p.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "missing required input 'quantity'") {
+		t.Fatalf("Expected missing input error, got %v", err)
+	}
+	if p.IsRunning() {
+		t.Error("Pipeline should not be running")
+	}
+}
+
+func TestPipeline_ConcurrentInvoke(t *testing.T) {
+	p := NewPipeline()
+	p.AddStage(NewStage("slow", &mockStep{delay: 100 * time.Millisecond, output: "first"}))
+	if err := p.SetOutputs(map[string]string{"result": "slow"}); err != nil {
+		t.Fatalf("SetOutputs failed: %v", err)
+	}
+
+	type result struct {
+		outputs map[string][]any
+		err     error
+	}
+	first := make(chan result, 1)
+	go func() {
+		outputs, err := p.Invoke(context.Background(), nil)
+		first <- result{outputs, err}
+	}()
+	for !p.IsRunning() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Una seconda invocazione fallisce senza sottrarre output alla prima
+	if _, err := p.Invoke(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("Expected already running error, got %v", err)
+	}
+
+	got := <-first
+	if got.err != nil {
+		t.Fatalf("First Invoke failed: %v", got.err)
+	}
+	if !reflect.DeepEqual(got.outputs["result"], []any{"first"}) {
+		t.Errorf("Unexpected outputs: %#v", got.outputs)
+	}
+}
+
+func TestBuildFromConfig_UnknownOutputStage(t *testing.T) {
+	cfg := &config.PipelineConfig{
+		Stages:  []config.StageConfig{{ID: "a", StepType: "js", StepConfig: map[string]any{"code": "return 1"}}},
+		Outputs: map[string]string{"result": "missing:default"},
+	}
+	if _, err := BuildFromConfig(cfg); err == nil || !strings.Contains(err.Error(), "non-existent stage 'missing'") {
+		t.Errorf("Expected unknown stage error, got %v", err)
+	}
+}
+
+func TestInvoke_JsBranchRouting(t *testing.T) {
+	cfg := &config.PipelineConfig{
+		Stages: []config.StageConfig{
+			{ID: "route", StepType: "js", StepConfig: map[string]any{"code": `
+for (const n of [1, -2, 3]) {
+  emit(n > 0 ? "positive" : "negative", n);
+}`}},
+			{ID: "on_positive", StepType: "js", StepConfig: map[string]any{"code": "return ctx.route.positive * 10;"}, Dependencies: []string{"route:positive"}},
+			{ID: "on_negative", StepType: "js", StepConfig: map[string]any{"code": "return ctx.route.negative * 10;"}, Dependencies: []string{"route:negative"}},
+		},
+		Outputs: map[string]string{"positive": "on_positive", "negative": "on_negative"},
+	}
+
+	p, err := BuildFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("BuildFromConfig failed: %v", err)
+	}
+	outputs, err := p.Invoke(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Invoke failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(outputs["positive"], []any{int64(10), int64(30)}) {
+		t.Errorf("Unexpected positive outputs: %v", outputs["positive"])
+	}
+	if !reflect.DeepEqual(outputs["negative"], []any{int64(-20)}) {
+		t.Errorf("Unexpected negative output: %v", outputs["negative"])
+	}
+}
@@ -0,0 +1,84 @@
+package jsruntime
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// eventLoop delivers the results of asynchronous operations (e.g. fetch) to the runtime goroutine
+// It exists only for runs that start one, and ends with the run
+type eventLoop struct {
+	jobs    chan func() error // Completions to run on the runtime goroutine
+	done    chan struct{}     // Closed when the run ends: late completions are dropped
+	pending int               // Operations started and not completed yet
+}
+
+// startAsync registers an asynchronous operation and returns the function that queues its
+// completion; the completion runs on the runtime goroutine while the run awaits a promise
+func (e *engine) startAsync() func(completion func() error) {
+	if e.loop == nil {
+		e.loop = &eventLoop{jobs: make(chan func() error), done: make(chan struct{})}
+	}
+	loop := e.loop
+	loop.pending++
+	return func(completion func() error) {
+		select {
+		case loop.jobs <- completion:
+		case <-loop.done:
+		}
+	}
+}
+
+// stopLoop ends the event loop of the run, if any
+func (e *engine) stopLoop() {
+	if e.loop != nil {
+		close(e.loop.done)
+		e.loop = nil
+	}
+}
+
+// settle waits for a returned promise (e.g. from an async function) and returns its value
+// Other values are returned as they are. The wait ends with ctx or Limits.MaxExecutionTime
+func (e *engine) settle(ctx context.Context, result goja.Value, limits Limits, start time.Time) (goja.Value, error) {
+	object, ok := result.(*goja.Object)
+	if !ok {
+		return result, nil
+	}
+	promise, ok := object.Export().(*goja.Promise)
+	if !ok {
+		return result, nil
+	}
+
+	var deadline <-chan time.Time
+	if limits.MaxExecutionTime > 0 && promise.State() == goja.PromiseStatePending {
+		timer := time.NewTimer(max(limits.MaxExecutionTime-time.Since(start), 0))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for promise.State() == goja.PromiseStatePending {
+		if e.loop == nil || e.loop.pending == 0 {
+			return nil, errors.New("the returned promise never settles: nothing left to wait for")
+		}
+		select {
+		case completion := <-e.loop.jobs:
+			e.loop.pending--
+			// Risolvere la promise esegue anche le continuazioni (await) dello script
+			if err := completion(); err != nil {
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, &JSTimeoutError{Timeout: limits.MaxExecutionTime}
+		}
+	}
+
+	if promise.State() == goja.PromiseStateRejected {
+		return nil, &JSRuntimeError{Message: "Uncaught (in promise) " + promise.Result().String()}
+	}
+	return promise.Result(), nil
+}
@@ -0,0 +1,96 @@
+package jsruntime
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// benchExpression is a typical webhook transformation
+const benchExpression = "({ id: ctx.webhook.body.order_id, total: ctx.webhook.body.amount * $vars.rate, request: ctx.webhook.headers['X-Request-Id'] })"
+
+// runFresh evaluates an expression as before the pool: new runtime, full ctx map, parse on every run
+func runFresh(expression string, state *models.StepInput) (any, error) {
+	vm := goja.New()
+	ctx := make(map[string]any)
+	state.Lock()
+	for stepName, outputs := range state.Data {
+		ctx[stepName] = models.OutputValue(outputs)
+	}
+	if state.EventID != "" {
+		ctx["_execution"] = map[string]any{"id": state.EventID}
+	}
+	state.Unlock()
+
+	vm.Set("ctx", ctx)
+	if state.GlobalVariables != nil {
+		vm.Set("$vars", state.GlobalVariables)
+	}
+	if state.GlobalSecrets != nil {
+		vm.Set("$secrets", state.GlobalSecrets)
+	}
+	result, err := vm.RunString("(function() {\n return " + expression + "\n})()")
+	if err != nil {
+		return nil, err
+	}
+	return result.Export(), nil
+}
+
+func BenchmarkExpression_FreshRuntime(b *testing.B) {
+	input := webhookInput(1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := runFresh(benchExpression, input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExpression_Pooled(b *testing.B) {
+	program, err := CompileExpression(benchExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := webhookInput(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Run(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The parallel benchmarks simulate a webhook receiving concurrent requests
+
+func BenchmarkExpression_FreshRuntimeParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		input := webhookInput(1)
+		for pb.Next() {
+			if _, err := runFresh(benchExpression, input); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkExpression_PooledParallel(b *testing.B) {
+	program, err := CompileExpression(benchExpression)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		input := webhookInput(1)
+		for pb.Next() {
+			if _, err := program.Run(input); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
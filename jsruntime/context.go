@@ -0,0 +1,96 @@
+package jsruntime
+
+import (
+	"sort"
+
+	"github.com/dop251/goja"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// executionKey exposes the execution metadata (ctx._execution.id)
+const executionKey = "_execution"
+
+// inputContext is the ctx object of a script: stage outputs are converted when first read
+type inputContext struct {
+	vm     *goja.Runtime
+	input  *models.StepInput
+	values map[string]goja.Value // Values already read or assigned by the script (nil = deleted)
+}
+
+func newInputContext(vm *goja.Runtime, input *models.StepInput) *inputContext {
+	return &inputContext{vm: vm, input: input, values: make(map[string]goja.Value)}
+}
+
+// Get implements goja.DynamicObject
+func (c *inputContext) Get(key string) goja.Value {
+	if value, ok := c.values[key]; ok {
+		return value
+	}
+
+	var value any
+	if key == executionKey {
+		if c.input.EventID == "" {
+			return nil
+		}
+		value = map[string]any{"id": c.input.EventID}
+	} else {
+		c.input.Lock()
+		outputs, exists := c.input.Data[key]
+		if exists {
+			value = models.OutputValue(outputs)
+		}
+		c.input.Unlock()
+		if !exists {
+			return nil
+		}
+	}
+
+	// Stessa istanza ad ogni lettura (ctx.a === ctx.a)
+	converted := c.vm.ToValue(value)
+	c.values[key] = converted
+	return converted
+}
+
+// Set implements goja.DynamicObject: assignments stay in the script
+func (c *inputContext) Set(key string, value goja.Value) bool {
+	c.values[key] = value
+	return true
+}
+
+// Has implements goja.DynamicObject
+func (c *inputContext) Has(key string) bool {
+	return c.Get(key) != nil
+}
+
+// Delete implements goja.DynamicObject
+func (c *inputContext) Delete(key string) bool {
+	c.values[key] = nil
+	return true
+}
+
+// Keys implements goja.DynamicObject
+func (c *inputContext) Keys() []string {
+	keys := make(map[string]bool)
+
+	c.input.Lock()
+	for key := range c.input.Data {
+		keys[key] = true
+	}
+	c.input.Unlock()
+	if c.input.EventID != "" {
+		keys[executionKey] = true
+	}
+
+	for key, value := range c.values {
+		keys[key] = value != nil
+	}
+
+	result := make([]string, 0, len(keys))
+	for key, present := range keys {
+		if present {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,176 @@
+package jsruntime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// MaxFetchResponseSize is the largest response body read by fetch()
+const MaxFetchResponseSize = 32 << 20
+
+// EnableFetch returns a copy of the program where fetch(url, options) sends requests with client
+// (the HTTP client of the pipeline, with its proxy, TLS and timeout settings)
+//
+// fetch returns a Promise of a response with status, statusText, ok, url, headers.get(name),
+// text() and json(); options are method, headers and body (objects are sent as JSON).
+// Requests are cancelled when the run ends
+func (p *Program) EnableFetch(client *http.Client) *Program {
+	enabled := *p
+	enabled.fetchClient = client
+	return &enabled
+}
+
+// fetchResult is a response read by the request goroutine
+type fetchResult struct {
+	response *http.Response
+	body     []byte
+}
+
+// fetchFunction returns the fetch() of the current run
+func (e *engine) fetchFunction(client *http.Client) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		vm := e.vm
+		promise, resolve, reject := vm.NewPromise()
+
+		req, err := e.newFetchRequest(call)
+		if err != nil {
+			reject(vm.NewTypeError("%s", err.Error()))
+			return vm.ToValue(promise)
+		}
+
+		complete := e.startAsync()
+		go func() {
+			result, err := doFetch(client, req)
+			complete(func() error {
+				if err != nil {
+					// Query string e credenziali dell'URL restano fuori da errori ed eventi
+					var urlErr *neturl.Error
+					if errors.As(err, &urlErr) {
+						urlErr.URL = models.RedactedURL(req.URL)
+					}
+					return reject(vm.NewTypeError("fetch %s failed: %v", models.RedactedURL(req.URL), err))
+				}
+				return resolve(e.newFetchResponse(req, result))
+			})
+		}()
+		return vm.ToValue(promise)
+	}
+}
+
+// newFetchRequest builds the request of fetch(url, options)
+func (e *engine) newFetchRequest(call goja.FunctionCall) (*http.Request, error) {
+	url := call.Argument(0)
+	if goja.IsUndefined(url) || goja.IsNull(url) {
+		return nil, fmt.Errorf("fetch requires a URL")
+	}
+
+	method := http.MethodGet
+	var headers map[string]any
+	var body io.Reader
+	contentType := ""
+	if options, ok := call.Argument(1).Export().(map[string]any); ok {
+		if value, ok := options["method"].(string); ok && value != "" {
+			method = strings.ToUpper(value)
+		}
+		headers, _ = options["headers"].(map[string]any)
+		switch value := options["body"].(type) {
+		case nil:
+		case string:
+			body = strings.NewReader(value)
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fetch body: %w", err)
+			}
+			body = bytes.NewReader(encoded)
+			contentType = "application/json"
+		}
+	}
+
+	req, err := http.NewRequestWithContext(e.ctx, method, url.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fetch request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, fmt.Sprint(value))
+	}
+	return req, nil
+}
+
+// doFetch sends a request and reads the response body
+func doFetch(client *http.Client, req *http.Request) (*fetchResult, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxFetchResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MaxFetchResponseSize {
+		return nil, fmt.Errorf("response body larger than %d bytes", MaxFetchResponseSize)
+	}
+	return &fetchResult{response: resp, body: body}, nil
+}
+
+// newFetchResponse builds the response object of fetch()
+func (e *engine) newFetchResponse(req *http.Request, result *fetchResult) *goja.Object {
+	vm := e.vm
+	resp := result.response
+
+	headers := vm.NewObject()
+	for name, values := range resp.Header {
+		headers.Set(strings.ToLower(name), strings.Join(values, ", "))
+	}
+	headers.Set("get", func(name string) goja.Value {
+		if values, ok := resp.Header[http.CanonicalHeaderKey(name)]; ok {
+			return vm.ToValue(strings.Join(values, ", "))
+		}
+		return goja.Null()
+	})
+
+	response := vm.NewObject()
+	response.Set("status", resp.StatusCode)
+	response.Set("statusText", http.StatusText(resp.StatusCode))
+	response.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
+	response.Set("url", req.URL.String())
+	response.Set("headers", headers)
+	response.Set("text", func() goja.Value {
+		return e.resolved(vm.ToValue(string(result.body)), nil)
+	})
+	response.Set("json", func() goja.Value {
+		parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+		value, err := parse(goja.Undefined(), vm.ToValue(string(result.body)))
+		return e.resolved(value, err)
+	})
+	return response
+}
+
+// resolved returns a promise already settled with value, or rejected with err
+func (e *engine) resolved(value goja.Value, err error) goja.Value {
+	promise, resolve, reject := e.vm.NewPromise()
+	var exception *goja.Exception
+	switch {
+	case errors.As(err, &exception):
+		reject(exception.Value())
+	case err != nil:
+		reject(e.vm.NewGoError(err))
+	default:
+		resolve(value)
+	}
+	return e.vm.ToValue(promise)
+}
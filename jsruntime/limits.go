@@ -0,0 +1,208 @@
+package jsruntime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultMaxCallStackSize is the call depth allowed when Limits.MaxCallStackSize is 0
+const DefaultMaxCallStackSize = 1024
+
+// allocCheckInterval is how often the allocation guard samples the heap
+const allocCheckInterval = 10 * time.Millisecond
+
+// Limits bounds the execution of a script
+type Limits struct {
+	// MaxExecutionTime interrupts the script with a *JSTimeoutError (0 = no limit)
+	MaxExecutionTime time.Duration
+	// MaxCallStackSize is the maximum function call depth (0 = DefaultMaxCallStackSize)
+	MaxCallStackSize int
+	// MaxAllocBytes interrupts the script when the heap allocations made while it runs exceed
+	// this size (0 = no limit). Approximate: allocations are sampled for the whole process, so
+	// concurrent work counts too; use it as a guard against runaway scripts, not as a quota
+	MaxAllocBytes uint64
+}
+
+// ExpressionLimits are the limits of $js: expressions and ${{ }} interpolations
+var ExpressionLimits = Limits{MaxExecutionTime: 5 * time.Second}
+
+// ErrAllocationLimit is the cause of the *JSRuntimeError returned when MaxAllocBytes is exceeded
+var ErrAllocationLimit = errors.New("allocation limit exceeded")
+
+// JSTimeoutError is returned when a script runs longer than Limits.MaxExecutionTime
+type JSTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *JSTimeoutError) Error() string {
+	return fmt.Sprintf("JavaScript execution timed out after %s", e.Timeout)
+}
+
+// JSRuntimeError is an exception thrown by a script (including stack overflows and the
+// allocation guard), with its position in the script source
+type JSRuntimeError struct {
+	Message string // e.g. "TypeError: Cannot read property 'id' of undefined"
+	Line    int    // 1-based line in the script (0 if unknown)
+	Column  int    // 1-based column in the script (0 if unknown)
+	Cause   error  // Go error thrown by native code or ErrAllocationLimit, if any
+}
+
+func (e *JSRuntimeError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s (line %d, column %d)", e.Message, e.Line, e.Column)
+	}
+	return e.Message
+}
+
+func (e *JSRuntimeError) Unwrap() error {
+	return e.Cause
+}
+
+// JSSyntaxError is returned by Compile and CompileExpression for invalid source
+type JSSyntaxError struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (e *JSSyntaxError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("SyntaxError: %s (line %d, column %d)", e.Message, e.Line, e.Column)
+	}
+	return "SyntaxError: " + e.Message
+}
+
+// watch interrupts vm when ctx is done or a limit is exceeded; the returned function
+// stops the watchdog and waits for it, so no interrupt can happen afterwards
+func watch(ctx context.Context, vm *goja.Runtime, limits Limits) func() {
+	if ctx.Done() == nil && limits.MaxExecutionTime <= 0 && limits.MaxAllocBytes == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+
+		var timeout <-chan time.Time
+		if limits.MaxExecutionTime > 0 {
+			timer := time.NewTimer(limits.MaxExecutionTime)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		var check <-chan time.Time
+		var startAllocs uint64
+		if limits.MaxAllocBytes > 0 {
+			ticker := time.NewTicker(allocCheckInterval)
+			defer ticker.Stop()
+			check = ticker.C
+			startAllocs = heapAllocs()
+		}
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				vm.Interrupt(ctx.Err())
+				return
+			case <-timeout:
+				vm.Interrupt(&JSTimeoutError{Timeout: limits.MaxExecutionTime})
+				return
+			case <-check:
+				if allocated := heapAllocs() - startAllocs; allocated > limits.MaxAllocBytes {
+					vm.Interrupt(&JSRuntimeError{
+						Message: fmt.Sprintf("allocation limit of %d bytes exceeded", limits.MaxAllocBytes),
+						Cause:   ErrAllocationLimit,
+					})
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// heapAllocs returns the bytes allocated on the heap since the process started
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// convertError turns the errors of goja into the errors of this package
+func (p *Program) convertError(err error, limits Limits) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if cause, ok := interrupted.Value().(error); ok {
+			return cause
+		}
+		return err
+	}
+
+	var overflow *goja.StackOverflowError
+	if errors.As(err, &overflow) {
+		runtimeErr := &JSRuntimeError{Message: fmt.Sprintf("RangeError: maximum call stack size exceeded (%d)", callStackSize(limits))}
+		runtimeErr.Line, runtimeErr.Column = p.position(overflow.Stack())
+		return runtimeErr
+	}
+
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		runtimeErr := &JSRuntimeError{Message: exception.Error(), Cause: exception.Unwrap()}
+		if value := exception.Value(); value != nil {
+			runtimeErr.Message = value.String()
+		}
+		runtimeErr.Line, runtimeErr.Column = p.position(exception.Stack())
+		return runtimeErr
+	}
+
+	return err
+}
+
+// position returns the position in the user source of the innermost script frame
+func (p *Program) position(stack []goja.StackFrame) (int, int) {
+	for _, frame := range stack {
+		position := frame.Position()
+		if position.Line > 0 {
+			return p.sourcePosition(position.Line, position.Column)
+		}
+	}
+	return 0, 0
+}
+
+// sourcePosition converts a position in the wrapped source into one in the user source
+func (p *Program) sourcePosition(line, column int) (int, int) {
+	// La prima riga è "(function() {": il codice utente inizia alla riga 2
+	line--
+	if line < 1 {
+		return 0, 0
+	}
+	if line == 1 {
+		column -= p.columnOffset
+		if column < 1 {
+			column = 1
+		}
+	}
+	return line, column
+}
+
+func callStackSize(limits Limits) int {
+	if limits.MaxCallStackSize > 0 {
+		return limits.MaxCallStackSize
+	}
+	return DefaultMaxCallStackSize
+}
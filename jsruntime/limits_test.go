@@ -0,0 +1,178 @@
+package jsruntime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func emptyInput() *models.StepInput {
+	return &models.StepInput{Data: map[string]map[string]*models.Data{}}
+}
+
+func TestRunContext_Timeout(t *testing.T) {
+	program, err := Compile("while (true) {}")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	start := time.Now()
+	_, err = program.RunContext(context.Background(), emptyInput(), Limits{MaxExecutionTime: 50 * time.Millisecond})
+	var timeoutErr *JSTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected *JSTimeoutError, got %v", err)
+	}
+	if timeoutErr.Timeout != 50*time.Millisecond {
+		t.Errorf("expected timeout 50ms, got %s", timeoutErr.Timeout)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("script was not interrupted in time (%s)", elapsed)
+	}
+
+	// Il runtime torna nel pool e deve essere riutilizzabile
+	for i := 0; i < 4; i++ {
+		ok, err := CompileExpression("1 + 1")
+		if err != nil {
+			t.Fatalf("CompileExpression failed: %v", err)
+		}
+		result, err := ok.Run(emptyInput())
+		if err != nil {
+			t.Fatalf("run after interrupt failed: %v", err)
+		}
+		if result != int64(2) {
+			t.Errorf("expected 2, got %v", result)
+		}
+	}
+}
+
+func TestRunContext_Cancel(t *testing.T) {
+	program, err := Compile("while (true) {}")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err = program.RunContext(ctx, emptyInput(), Limits{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRunContext_CallStack(t *testing.T) {
+	program, err := Compile("function f(n) { return f(n + 1); }\nreturn f(0);")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	_, err = program.RunContext(context.Background(), emptyInput(), Limits{MaxCallStackSize: 100})
+	var runtimeErr *JSRuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *JSRuntimeError, got %v", err)
+	}
+	if !strings.Contains(runtimeErr.Message, "maximum call stack") {
+		t.Errorf("unexpected message: %s", runtimeErr.Message)
+	}
+	if runtimeErr.Line != 1 {
+		t.Errorf("expected line 1, got %d", runtimeErr.Line)
+	}
+}
+
+func TestRunContext_AllocationLimit(t *testing.T) {
+	program, err := Compile("var items = []; while (true) { items.push({ value: 'x'.repeat(64) }); }")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	_, err = program.RunContext(context.Background(), emptyInput(), Limits{
+		MaxAllocBytes:    1 << 20,
+		MaxExecutionTime: 10 * time.Second,
+	})
+	if !errors.Is(err, ErrAllocationLimit) {
+		t.Fatalf("expected ErrAllocationLimit, got %v", err)
+	}
+}
+
+func TestRunContext_ExceptionPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		program func() (*Program, error)
+		message string
+		line    int
+		column  int
+	}{
+		{
+			name: "throw",
+			program: func() (*Program, error) {
+				return Compile("var a = 1;\n  throw new Error('boom');")
+			},
+			message: "Error: boom",
+			line:    2,
+			column:  9, // Dove viene creato l'errore
+		},
+		{
+			name: "type error",
+			program: func() (*Program, error) {
+				return Compile("var order = ctx.order;\nreturn order.id;")
+			},
+			message: "TypeError",
+			line:    2,
+		},
+		{
+			name: "expression",
+			program: func() (*Program, error) {
+				return CompileExpression("missing.value")
+			},
+			message: "ReferenceError: missing is not defined",
+			line:    1,
+			column:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := tt.program()
+			if err != nil {
+				t.Fatalf("compile failed: %v", err)
+			}
+			_, err = program.Run(emptyInput())
+			var runtimeErr *JSRuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected *JSRuntimeError, got %v", err)
+			}
+			if !strings.Contains(runtimeErr.Message, tt.message) {
+				t.Errorf("expected message containing %q, got %q", tt.message, runtimeErr.Message)
+			}
+			if runtimeErr.Line != tt.line {
+				t.Errorf("expected line %d, got %d", tt.line, runtimeErr.Line)
+			}
+			if tt.column > 0 && runtimeErr.Column != tt.column {
+				t.Errorf("expected column %d, got %d", tt.column, runtimeErr.Column)
+			}
+		})
+	}
+}
+
+func TestCompile_SyntaxError(t *testing.T) {
+	_, err := Compile("var a = 1;\nvar b = ;")
+	var syntaxErr *JSSyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected *JSSyntaxError, got %v", err)
+	}
+	if syntaxErr.Line != 2 {
+		t.Errorf("expected line 2, got %d (%v)", syntaxErr.Line, err)
+	}
+	if !strings.HasPrefix(err.Error(), "SyntaxError: ") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err = CompileExpression("a +")
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected *JSSyntaxError, got %v", err)
+	}
+}
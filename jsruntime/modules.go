@@ -0,0 +1,183 @@
+package jsruntime
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+)
+
+// modules contains the compiled module files by absolute path
+// An entry is reused while the file keeps its modification time and size, so edits are picked up
+var modules sync.Map
+
+// module is a compiled CommonJS module: a .js file wrapped in a function, or a .json file
+type module struct {
+	program *goja.Program // nil for JSON modules
+	json    any
+	modTime time.Time // Of the file that was compiled
+	size    int64
+}
+
+// loadedModule is a module evaluated in a runtime
+type loadedModule struct {
+	source *module
+	object *goja.Object
+}
+
+// EnableRequire returns a copy of the program where require() loads local modules, with
+// relative paths resolved from baseDir (the directory of the pipeline file, "" = working directory)
+//
+// Modules are CommonJS files (module.exports, exports, require, __filename, __dirname) or JSON files.
+// They must be inside baseDir or one of allowedDirs (relative to baseDir), symbolic links included.
+// A module is evaluated once per runtime and its exports are reused by the following runs,
+// until the file changes
+func (p *Program) EnableRequire(baseDir string, allowedDirs ...string) *Program {
+	enabled := *p
+	enabled.requireEnabled = true
+	enabled.baseDir = baseDir
+	enabled.requireRoots = []string{baseDir}
+	for _, dir := range allowedDirs {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(baseDir, dir)
+		}
+		enabled.requireRoots = append(enabled.requireRoots, dir)
+	}
+	return &enabled
+}
+
+// requireFunction returns the require() of the code in dir, loading modules from roots
+func (e *engine) requireFunction(roots []string, dir string) func(string) (goja.Value, error) {
+	return func(path string) (goja.Value, error) {
+		return e.require(roots, dir, path)
+	}
+}
+
+// require loads a module relative to dir and returns its exports
+func (e *engine) require(roots []string, dir, path string) (goja.Value, error) {
+	if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") && !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("cannot require '%s': only local modules are supported (use a path starting with ./ or ../)", path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot require '%s': %w", path, err)
+	}
+	if filepath.Ext(absPath) == "" {
+		absPath += ".js"
+	}
+	if !insideRoots(roots, absPath) {
+		return nil, fmt.Errorf("cannot require '%s': the module is outside the directory of the pipeline and the allowed module directories", absPath)
+	}
+
+	compiled, err := loadModule(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Già caricato in questo runtime (anche se ancora in esecuzione, per i cicli)
+	if loaded, ok := e.modules[absPath]; ok && loaded.source == compiled {
+		return loaded.object.Get("exports"), nil
+	}
+
+	moduleObject := e.vm.NewObject()
+	if compiled.program == nil {
+		moduleObject.Set("exports", e.vm.ToValue(compiled.json))
+		e.modules[absPath] = loadedModule{source: compiled, object: moduleObject}
+		return moduleObject.Get("exports"), nil
+	}
+
+	exports := e.vm.NewObject()
+	moduleObject.Set("exports", exports)
+	e.modules[absPath] = loadedModule{source: compiled, object: moduleObject}
+
+	wrapper, err := e.vm.RunProgram(compiled.program)
+	if err != nil {
+		delete(e.modules, absPath)
+		return nil, err
+	}
+	function, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		delete(e.modules, absPath)
+		return nil, fmt.Errorf("cannot require '%s': invalid module", absPath)
+	}
+	moduleDir := filepath.Dir(absPath)
+	if _, err := function(exports, exports, e.vm.ToValue(e.requireFunction(roots, moduleDir)), moduleObject,
+		e.vm.ToValue(absPath), e.vm.ToValue(moduleDir)); err != nil {
+		delete(e.modules, absPath)
+		return nil, err
+	}
+	return moduleObject.Get("exports"), nil
+}
+
+// insideRoots reports whether path is inside one of the roots, after resolving symbolic links
+func insideRoots(roots []string, path string) bool {
+	path = realPath(path)
+	for _, root := range roots {
+		rel, err := filepath.Rel(realPath(root), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// realPath returns the absolute path with its symbolic links resolved (as far as it exists)
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	// Un file mancante: risolve la directory, l'errore arriva alla lettura
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		return filepath.Join(dir, filepath.Base(path))
+	}
+	return path
+}
+
+// loadModule reads and compiles a module file, again only when the file changed
+func loadModule(absPath string) (*module, error) {
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot require '%s': %w", absPath, err)
+	}
+	if cached, ok := modules.Load(absPath); ok {
+		if cached := cached.(*module); cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			return cached, nil
+		}
+	}
+
+	source, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot require '%s': %w", absPath, err)
+	}
+
+	compiled := &module{modTime: info.ModTime(), size: info.Size()}
+	if filepath.Ext(absPath) == ".json" {
+		if err := json.Unmarshal(source, &compiled.json); err != nil {
+			return nil, fmt.Errorf("cannot require '%s': %w", absPath, err)
+		}
+	} else {
+		wrapped := "(function(exports, require, module, __filename, __dirname) {\n" + string(source) + "\n})"
+		ast, err := parser.ParseFile(nil, absPath, wrapped, 0)
+		if err != nil {
+			return nil, fmt.Errorf("cannot require '%s': %w", absPath, err)
+		}
+		if compiled.program, err = goja.CompileAST(ast, false); err != nil {
+			return nil, fmt.Errorf("cannot require '%s': %w", absPath, err)
+		}
+	}
+
+	modules.Store(absPath, compiled)
+	return compiled, nil
+}
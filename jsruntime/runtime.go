@@ -0,0 +1,237 @@
+// Package jsruntime runs the JavaScript of $js: expressions and js steps.
+//
+// Sources are compiled once (programs are cached by source) and run on a pool of reusable
+// goja runtimes. The step input is bound lazily: ctx converts a stage output only when the
+// script reads it, $vars and $secrets wrap the global maps without copying them.
+//
+// Every runtime has a helper library (console, base64, hex, crypto, dates, url, see installStdlib);
+// programs of js steps can also require() local modules (see EnableRequire) and send HTTP
+// requests with fetch() (see EnableFetch). A returned Promise is awaited before the run ends.
+//
+// Runtimes are reused across the runs of the same program only (every program has its own pool),
+// and the globals a script assigns are deleted when its run ends: ctx, $vars and $secrets are
+// rebound on every run. Values meant to outlive a run go in $state, the store of the stage
+// (see stateObject).
+package jsruntime
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// Program is a compiled script, safe for concurrent use
+type Program struct {
+	source       string
+	program      *goja.Program
+	columnOffset int // Characters added before the user code on its first line
+
+	requireEnabled bool         // require() loads local modules (see EnableRequire)
+	baseDir        string       // Directory of relative require() paths
+	requireRoots   []string     // Directories require() can load modules from
+	fetchClient    *http.Client // Client of fetch() (nil = fetch is not available, see EnableFetch)
+	runtimes       *sync.Pool   // Warmed runtimes of this program, shared with its copies
+}
+
+// cache contains the compiled programs by source
+// The sources come from the pipeline configuration, so the cache does not need eviction
+var cache sync.Map
+
+// engine is a pooled runtime with the helper library installed
+type engine struct {
+	vm      *goja.Runtime
+	ctx     context.Context         // Context of the current run (cancels fetch requests)
+	cancel  context.CancelFunc      // Cancels ctx when the run ends (nil without fetch)
+	input   *models.StepInput       // Input of the current run (nil between runs)
+	modules map[string]loadedModule // Modules loaded by require(), by absolute path
+	loop    *eventLoop              // Asynchronous operations of the current run (nil if none)
+	pool    *sync.Pool              // Pool the runtime returns to
+	globals map[string]bool         // Enumerable globals of the helper library, kept on release
+}
+
+// newRuntimePool returns a pool of warmed runtimes
+func newRuntimePool() *sync.Pool {
+	pool := &sync.Pool{}
+	pool.New = func() any {
+		e := &engine{vm: goja.New(), modules: make(map[string]loadedModule), pool: pool, globals: make(map[string]bool)}
+		e.installStdlib()
+		for _, key := range e.vm.GlobalObject().Keys() {
+			e.globals[key] = true
+		}
+		return e
+	}
+	return pool
+}
+
+// Compile compiles a function body: the code can use return, e.g. "return ctx.a + 1;"
+// Code using await runs in an async function and the run waits for its result.
+// Syntax errors are returned as *JSSyntaxError
+func Compile(code string) (*Program, error) {
+	program, err := compile("(function() {\n"+code+"\n})()", 0)
+	var syntaxErr *JSSyntaxError
+	if errors.As(err, &syntaxErr) && strings.Contains(code, "await") {
+		// await è valido solo dentro una funzione async
+		if async, asyncErr := compile("(async function() {\n"+code+"\n})()", 0); asyncErr == nil {
+			return async, nil
+		}
+	}
+	return program, err
+}
+
+// CompileExpression compiles an expression, e.g. "ctx.a + 1"
+func CompileExpression(expression string) (*Program, error) {
+	const prefix = " return "
+	return compile("(function() {\n"+prefix+expression+"\n})()", len(prefix))
+}
+
+func compile(source string, columnOffset int) (*Program, error) {
+	if cached, ok := cache.Load(source); ok {
+		return cached.(*Program), nil
+	}
+
+	p := &Program{source: source, columnOffset: columnOffset, runtimes: newRuntimePool()}
+	// Parse separato dalla compilazione: gli errori del parser hanno la posizione
+	ast, err := parser.ParseFile(nil, "", source, 0)
+	if err != nil {
+		var syntaxErrs parser.ErrorList
+		if errors.As(err, &syntaxErrs) && len(syntaxErrs) > 0 {
+			converted := &JSSyntaxError{Message: syntaxErrs[0].Message}
+			converted.Line, converted.Column = p.sourcePosition(syntaxErrs[0].Position.Line, syntaxErrs[0].Position.Column)
+			return nil, converted
+		}
+		return nil, &JSSyntaxError{Message: err.Error()}
+	}
+	program, err := goja.CompileAST(ast, false)
+	if err != nil {
+		var syntaxErr *goja.CompilerSyntaxError
+		if errors.As(err, &syntaxErr) {
+			converted := &JSSyntaxError{Message: syntaxErr.Message}
+			if syntaxErr.File != nil {
+				position := syntaxErr.File.Position(syntaxErr.Offset)
+				converted.Line, converted.Column = p.sourcePosition(position.Line, position.Column)
+			}
+			return nil, converted
+		}
+		return nil, err
+	}
+	p.program = program
+
+	actual, _ := cache.LoadOrStore(source, p)
+	return actual.(*Program), nil
+}
+
+// Source returns the compiled source (the code wrapped in a function)
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Run executes the program with the default limits (see RunContext)
+func (p *Program) Run(input *models.StepInput) (any, error) {
+	return p.RunContext(context.Background(), input, Limits{})
+}
+
+// RunContext executes the program with ctx, $vars and $secrets bound to input and returns
+// the result exported to Go; a returned promise is awaited. The script is interrupted when ctx
+// is done (the error is ctx.Err()) or a limit is exceeded; script exceptions and rejected
+// promises are returned as *JSRuntimeError
+func (p *Program) RunContext(ctx context.Context, input *models.StepInput, limits Limits) (any, error) {
+	return p.RunWithEmit(ctx, input, limits, nil)
+}
+
+// EmitFunc receives the values passed to emit(port, value) by a script
+type EmitFunc func(port string, value any) error
+
+// RunWithEmit is RunContext with an emit(port, value) function available to the script
+// An error returned by emit is thrown in the script
+func (p *Program) RunWithEmit(ctx context.Context, input *models.StepInput, limits Limits, emit EmitFunc) (any, error) {
+	start := time.Now()
+	e := p.acquire(ctx, input, limits)
+	defer e.release()
+	if emit != nil {
+		e.vm.Set("emit", emitFunction(emit))
+	}
+
+	stop := watch(ctx, e.vm, limits)
+	result, err := e.vm.RunProgram(p.program)
+	if err == nil {
+		result, err = e.settle(ctx, result, limits, start)
+	}
+	stop()
+	if err != nil {
+		return nil, p.convertError(err, limits)
+	}
+	return result.Export(), nil
+}
+
+// acquire takes a runtime from the pool and binds the input
+func (p *Program) acquire(ctx context.Context, input *models.StepInput, limits Limits) *engine {
+	e := p.runtimes.Get().(*engine)
+	e.ctx = ctx
+	if p.fetchClient != nil {
+		e.ctx, e.cancel = context.WithCancel(ctx)
+	}
+	e.input = input
+	vm := e.vm
+	vm.SetMaxCallStackSize(callStackSize(limits))
+
+	vm.Set("ctx", vm.NewDynamicObject(newInputContext(vm, input)))
+	// Come prima del pool: senza globali $vars/$secrets non sono definiti
+	if input.GlobalVariables != nil {
+		vm.Set("$vars", input.GlobalVariables)
+	}
+	if input.GlobalSecrets != nil {
+		vm.Set("$secrets", input.GlobalSecrets)
+	}
+	if input.State != nil {
+		vm.Set("$state", stateObject(vm, input.State))
+	}
+	if p.requireEnabled {
+		vm.Set("require", e.requireFunction(p.requireRoots, p.baseDir))
+	}
+	if p.fetchClient != nil {
+		vm.Set("fetch", e.fetchFunction(p.fetchClient))
+	}
+	return e
+}
+
+// release unbinds the input (so it can be garbage collected), deletes the globals set by the run
+// (bindings and implicit globals of the script) and returns the runtime to the pool
+// The watchdog has already stopped, so clearing a late interrupt is safe
+func (e *engine) release() {
+	e.vm.ClearInterrupt()
+	e.stopLoop()
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.ctx = nil
+	e.input = nil
+	global := e.vm.GlobalObject()
+	for _, key := range global.Keys() {
+		if !e.globals[key] {
+			global.Delete(key)
+		}
+	}
+	e.pool.Put(e)
+}
+
+// emitFunction adapts an EmitFunc to the emit(port, value) of scripts
+func emitFunction(emit EmitFunc) func(port string, value goja.Value) error {
+	return func(port string, value goja.Value) error {
+		if port == "" {
+			return errors.New("emit requires a port name")
+		}
+		var exported any
+		if value != nil {
+			exported = value.Export()
+		}
+		return emit(port, exported)
+	}
+}
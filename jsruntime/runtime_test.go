@@ -0,0 +1,162 @@
+package jsruntime
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func webhookInput(n int) *models.StepInput {
+	return &models.StepInput{
+		Data: map[string]map[string]*models.Data{
+			"webhook": models.CreateDefaultResultData(map[string]any{
+				"body":    map[string]any{"order_id": n, "amount": 12.5, "currency": "EUR"},
+				"headers": map[string]any{"X-Request-Id": fmt.Sprintf("req-%d", n)},
+			}),
+			"check": {
+				"true":   {Value: "ok"},
+				"reason": {Value: "valid"},
+			},
+		},
+		EventID:         fmt.Sprintf("evt_%d", n),
+		GlobalVariables: map[string]any{"rate": 2},
+	}
+}
+
+func TestProgram_Run(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{"ctx.webhook.body.order_id", int64(7)},
+		{"ctx.webhook.body.amount * $vars.rate", int64(25)},
+		{"ctx.check.true + ':' + ctx.check.reason", "ok:valid"},
+		{"ctx._execution.id", "evt_7"},
+		{"Object.keys(ctx).join(',')", "_execution,check,webhook"},
+		{"ctx.missing === undefined", true},
+		{"typeof $secrets", "undefined"},
+		{"ctx.webhook === ctx.webhook", true},
+	}
+
+	for _, tt := range tests {
+		program, err := CompileExpression(tt.expression)
+		if err != nil {
+			t.Fatalf("CompileExpression(%q) failed: %v", tt.expression, err)
+		}
+		got, err := program.Run(webhookInput(7))
+		if err != nil {
+			t.Errorf("Run(%q) failed: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Run(%q) = %#v, expected %#v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestProgram_RuntimeReuse(t *testing.T) {
+	program, err := Compile("ctx.extra = 1; delete ctx.webhook; return Object.keys(ctx).join(',');")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	check, err := CompileExpression("[typeof ctx.webhook, typeof ctx.extra, typeof $vars].join(',')")
+	if err != nil {
+		t.Fatalf("CompileExpression failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := program.Run(webhookInput(i))
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if got != "_execution,check,extra" {
+			t.Errorf("Unexpected keys after assignments: %v", got)
+		}
+
+		// Assignments stay in the run; $vars is unbound when the input has no variables
+		got, err = check.Run(&models.StepInput{Data: webhookInput(i).Data})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if got != "object,undefined,undefined" {
+			t.Errorf("State leaked between runs: %v", got)
+		}
+	}
+}
+
+func TestProgram_GlobalsDoNotLeak(t *testing.T) {
+	// Due pipeline diverse: la prima assegna globali impliciti, la seconda non deve vederli
+	writer, err := Compile("const seen = typeof leaked; leaked = 42; globalThis.shared = 'a'; return seen;")
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	reader, err := CompileExpression("[typeof leaked, typeof shared, typeof console].join(',')")
+	if err != nil {
+		t.Fatalf("CompileExpression failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := writer.Run(emptyInput())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if got != "undefined" {
+			t.Errorf("Run %d: implicit global survived the previous run of the same program", i)
+		}
+
+		got, err = reader.Run(emptyInput())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if got != "undefined,undefined,object" {
+			t.Errorf("Run %d: globals leaked into another program: %v", i, got)
+		}
+	}
+}
+
+func TestCompile_CacheAndErrors(t *testing.T) {
+	first, err := CompileExpression("1 + 1")
+	if err != nil {
+		t.Fatalf("CompileExpression failed: %v", err)
+	}
+	second, _ := CompileExpression("1 + 1")
+	if first != second {
+		t.Error("Expected the cached program")
+	}
+
+	if _, err := Compile("return ctx.a +;"); err == nil {
+		t.Error("Expected syntax error")
+	}
+
+	program, _ := CompileExpression("ctx.missing.field")
+	if _, err := program.Run(webhookInput(1)); err == nil || !strings.Contains(err.Error(), "TypeError") {
+		t.Errorf("Expected TypeError, got %v", err)
+	}
+}
+
+func TestProgram_Concurrent(t *testing.T) {
+	program, err := CompileExpression("ctx.webhook.body.order_id * $vars.rate")
+	if err != nil {
+		t.Fatalf("CompileExpression failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				n := worker*1000 + i
+				got, err := program.Run(webhookInput(n))
+				if err != nil || got != int64(n*2) {
+					t.Errorf("Run = %v, %v; expected %d", got, err, n*2)
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,70 @@
+package jsruntime
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// stateObject returns the $state of a stage:
+//   - get(key, fallback?) returns a copy of the value, or fallback when the key is missing or expired
+//   - set(key, value, ttl?) stores a JSON-serializable value; ttl is a duration ("10m") or milliseconds
+//   - delete(key) removes the key
+func stateObject(vm *goja.Runtime, state *models.StageState) *goja.Object {
+	object := vm.NewObject()
+	object.Set("get", func(key string, fallback goja.Value) (goja.Value, error) {
+		value, ok, err := state.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if fallback == nil {
+				return goja.Undefined(), nil
+			}
+			return fallback, nil
+		}
+		return vm.ToValue(value), nil
+	})
+	object.Set("set", func(key string, value goja.Value, ttl goja.Value) error {
+		duration, err := stateTTL(ttl)
+		if err != nil {
+			return err
+		}
+		return state.Set(key, exportValue(value), duration)
+	})
+	object.Set("delete", func(key string) error {
+		return state.Delete(key)
+	})
+	return object
+}
+
+// exportValue converts a script value for the store (undefined becomes null)
+func exportValue(value goja.Value) any {
+	if value == nil || goja.IsUndefined(value) {
+		return nil
+	}
+	return value.Export()
+}
+
+// stateTTL parses the ttl argument of $state.set (missing = no expiry)
+func stateTTL(ttl goja.Value) (time.Duration, error) {
+	if ttl == nil || goja.IsUndefined(ttl) || goja.IsNull(ttl) {
+		return 0, nil
+	}
+	switch value := ttl.Export().(type) {
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid $state ttl: %w", err)
+		}
+		return duration, nil
+	case int64:
+		return time.Duration(value) * time.Millisecond, nil
+	case float64:
+		return time.Duration(value * float64(time.Millisecond)), nil
+	default:
+		return 0, fmt.Errorf("invalid $state ttl: expected a duration or milliseconds, got %T", value)
+	}
+}
@@ -0,0 +1,73 @@
+package jsruntime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// recordingStore is a StateStore keeping values and ttls in maps
+type recordingStore struct {
+	values map[string]any
+	ttls   map[string]time.Duration
+}
+
+func (s *recordingStore) Get(scope, key string) (any, bool, error) {
+	value, ok := s.values[scope+"/"+key]
+	return value, ok, nil
+}
+
+func (s *recordingStore) Set(scope, key string, value any, ttl time.Duration) error {
+	s.values[scope+"/"+key] = value
+	s.ttls[scope+"/"+key] = ttl
+	return nil
+}
+
+func (s *recordingStore) Delete(scope, key string) error {
+	delete(s.values, scope+"/"+key)
+	return nil
+}
+
+func (s *recordingStore) Flush() error { return nil }
+
+func TestRun_State(t *testing.T) {
+	store := &recordingStore{values: map[string]any{"stage/seen": int64(3)}, ttls: map[string]time.Duration{}}
+	input := emptyInput()
+	input.State = &models.StageState{Store: store, Scope: "stage"}
+
+	program, err := Compile(`
+$state.set("count", $state.get("seen") + $state.get("missing", 1));
+$state.set("token", "abc", "10m");
+$state.set("flag", true, 500);
+$state.delete("seen");
+return $state.get("seen");`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	result, err := program.Run(input)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result != nil {
+		t.Errorf("Expected a deleted key to be undefined, got %v", result)
+	}
+	if store.values["stage/count"] != int64(4) {
+		t.Errorf("Expected count 4, got %v", store.values["stage/count"])
+	}
+	if store.ttls["stage/token"] != 10*time.Minute || store.ttls["stage/flag"] != 500*time.Millisecond || store.ttls["stage/count"] != 0 {
+		t.Errorf("Unexpected ttls: %v", store.ttls)
+	}
+
+	invalid, _ := Compile(`$state.set("a", 1, "soon");`)
+	if _, err := invalid.Run(input); err == nil || !strings.Contains(err.Error(), "invalid $state ttl") {
+		t.Errorf("Expected an invalid ttl error, got %v", err)
+	}
+
+	// Fuori da una pipeline $state non esiste
+	undefined, _ := Compile(`return typeof $state;`)
+	if result, _ := undefined.Run(emptyInput()); result != "undefined" {
+		t.Errorf("Expected $state to be undefined without a stage state, got %v", result)
+	}
+}
@@ -0,0 +1,266 @@
+package jsruntime
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// installStdlib defines the helper globals of every runtime:
+//   - console.log/info/debug/warn/error: stage.log events of the current input
+//   - base64 and hex: encode/decode
+//   - crypto: md5/sha1/sha256/sha512, hmac and randomUUID
+//   - dates: format and parse with Go layouts or named formats
+//   - url: encode/decode of query components and paths, query strings from objects
+func (e *engine) installStdlib() {
+	vm := e.vm
+
+	console := vm.NewObject()
+	for name, level := range map[string]models.LogLevel{
+		"log":   models.LogLevelInfo,
+		"info":  models.LogLevelInfo,
+		"debug": models.LogLevelDebug,
+		"warn":  models.LogLevelWarn,
+		"error": models.LogLevelError,
+	} {
+		console.Set(name, func(call goja.FunctionCall) goja.Value {
+			if e.input != nil {
+				e.input.WriteLog(level, formatLogArgs(call.Arguments))
+			}
+			return goja.Undefined()
+		})
+	}
+	vm.Set("console", console)
+
+	b64 := vm.NewObject()
+	b64.Set("encode", func(value goja.Value) string {
+		return base64.StdEncoding.EncodeToString(toBytes(value))
+	})
+	b64.Set("decode", func(text string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		return string(decoded), err
+	})
+	b64.Set("encodeURL", func(value goja.Value) string {
+		return base64.RawURLEncoding.EncodeToString(toBytes(value))
+	})
+	b64.Set("decodeURL", func(text string) (string, error) {
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(text, "="))
+		return string(decoded), err
+	})
+	vm.Set("base64", b64)
+
+	hexObject := vm.NewObject()
+	hexObject.Set("encode", func(value goja.Value) string {
+		return hex.EncodeToString(toBytes(value))
+	})
+	hexObject.Set("decode", func(text string) (string, error) {
+		decoded, err := hex.DecodeString(text)
+		return string(decoded), err
+	})
+	vm.Set("hex", hexObject)
+
+	cryptoObject := vm.NewObject()
+	for name, newHash := range hashes {
+		cryptoObject.Set(name, func(value goja.Value, encoding string) (string, error) {
+			h := newHash()
+			h.Write(toBytes(value))
+			return encodeDigest(h.Sum(nil), encoding)
+		})
+	}
+	cryptoObject.Set("hmac", func(algorithm string, key, value goja.Value, encoding string) (string, error) {
+		newHash, ok := hashes[strings.ToLower(algorithm)]
+		if !ok {
+			return "", fmt.Errorf("unsupported hmac algorithm '%s' (use md5, sha1, sha256 or sha512)", algorithm)
+		}
+		mac := hmac.New(newHash, toBytes(key))
+		mac.Write(toBytes(value))
+		return encodeDigest(mac.Sum(nil), encoding)
+	})
+	cryptoObject.Set("randomUUID", newUUID)
+	vm.Set("crypto", cryptoObject)
+
+	dates := vm.NewObject()
+	dates.Set("format", func(value goja.Value, layout, timezone string) (string, error) {
+		t, err := toTime(value)
+		if err != nil {
+			return "", err
+		}
+		location, err := loadLocation(timezone)
+		if err != nil {
+			return "", err
+		}
+		return t.In(location).Format(dateLayout(layout)), nil
+	})
+	dates.Set("parse", func(text, layout, timezone string) (goja.Value, error) {
+		location, err := loadLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.ParseInLocation(dateLayout(layout), text, location)
+		if err != nil {
+			return nil, err
+		}
+		return e.newDate(t)
+	})
+	vm.Set("dates", dates)
+
+	urlObject := vm.NewObject()
+	urlObject.Set("encode", url.QueryEscape)
+	urlObject.Set("decode", url.QueryUnescape)
+	urlObject.Set("encodePath", url.PathEscape)
+	urlObject.Set("decodePath", url.PathUnescape)
+	urlObject.Set("query", func(params map[string]any) string {
+		return encodeQuery(params)
+	})
+	vm.Set("url", urlObject)
+}
+
+// hashes are the algorithms of crypto.<name>() and crypto.hmac()
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// encodeDigest encodes a digest as "hex" (default), "base64" or "base64url"
+func encodeDigest(sum []byte, encoding string) (string, error) {
+	switch encoding {
+	case "", "hex":
+		return hex.EncodeToString(sum), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(sum), nil
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(sum), nil
+	default:
+		return "", fmt.Errorf("unsupported encoding '%s' (use hex, base64 or base64url)", encoding)
+	}
+}
+
+// toBytes converts a string or an ArrayBuffer/typed array to bytes
+func toBytes(value goja.Value) []byte {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+	switch exported := value.Export().(type) {
+	case []byte:
+		return exported
+	case goja.ArrayBuffer:
+		return exported.Bytes()
+	}
+	return []byte(value.String())
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// namedLayouts are the layout names accepted by dates.format and dates.parse
+var namedLayouts = map[string]string{
+	"":            time.RFC3339,
+	"iso":         time.RFC3339,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC822":      time.RFC822,
+	"date":        time.DateOnly,
+	"time":        time.TimeOnly,
+	"datetime":    time.DateTime,
+}
+
+// dateLayout returns the Go layout for a layout name, or the layout itself
+func dateLayout(layout string) string {
+	if named, ok := namedLayouts[layout]; ok {
+		return named
+	}
+	return layout
+}
+
+// loadLocation loads an IANA time zone ("" = UTC)
+func loadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// toTime converts a Date, a timestamp in milliseconds or an RFC 3339 string
+func toTime(value goja.Value) (time.Time, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return time.Now(), nil
+	}
+	switch exported := value.Export().(type) {
+	case time.Time:
+		return exported, nil
+	case int64:
+		return time.UnixMilli(exported), nil
+	case float64:
+		return time.UnixMilli(int64(exported)), nil
+	case string:
+		return time.Parse(time.RFC3339Nano, exported)
+	}
+	return time.Time{}, fmt.Errorf("cannot convert %s to a date", value.String())
+}
+
+// newDate converts a time to a JavaScript Date
+func (e *engine) newDate(t time.Time) (goja.Value, error) {
+	return e.vm.New(e.vm.Get("Date"), e.vm.ToValue(t.UnixMilli()))
+}
+
+// encodeQuery builds a query string with sorted keys; arrays repeat the key
+func encodeQuery(params map[string]any) string {
+	values := url.Values{}
+	for key, value := range params {
+		switch v := value.(type) {
+		case nil:
+		case []any:
+			for _, item := range v {
+				values.Add(key, fmt.Sprint(item))
+			}
+		default:
+			values.Add(key, fmt.Sprint(v))
+		}
+	}
+	return values.Encode()
+}
+
+// formatLogArgs joins the arguments of console.log: objects as JSON, other values as strings
+func formatLogArgs(args []goja.Value) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = formatLogArg(arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatLogArg(arg goja.Value) string {
+	object, ok := arg.(*goja.Object)
+	if !ok || object.ClassName() == "Error" || object.ClassName() == "Function" {
+		return arg.String()
+	}
+	encoded, err := json.Marshal(object.Export())
+	if err != nil {
+		return arg.String()
+	}
+	return string(encoded)
+}
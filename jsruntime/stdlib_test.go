@@ -0,0 +1,280 @@
+package jsruntime
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func TestStdlib(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{"base64.encode('hello')", "aGVsbG8="},
+		{"base64.decode('aGVsbG8=')", "hello"},
+		{"base64.encodeURL('??>')", "Pz8-"},
+		{"base64.decodeURL('Pz8-')", "??>"},
+		{"hex.encode('hi')", "6869"},
+		{"hex.decode('6869')", "hi"},
+		{"hex.encode(new Uint8Array([1, 255]).buffer)", "01ff"},
+		{"crypto.md5('abc')", "900150983cd24fb0d6963f7d28e17f72"},
+		{"crypto.sha1('abc')", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"crypto.sha256('abc')", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"crypto.sha256('abc', 'base64')", "ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0="},
+		{"crypto.hmac('sha256', 'key', 'The quick brown fox jumps over the lazy dog')", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"dates.format(0)", "1970-01-01T00:00:00Z"},
+		{"dates.format(Date.UTC(2024, 4, 1, 10, 30), 'datetime')", "2024-05-01 10:30:00"},
+		{"dates.format('2024-05-01T10:30:00Z', '02/01/2006 15:04', 'Europe/Rome')", "01/05/2024 12:30"},
+		{"dates.parse('2024-05-01', 'date').toISOString()", "2024-05-01T00:00:00.000Z"},
+		{"dates.parse('01/05/2024 12:30', '02/01/2006 15:04', 'Europe/Rome').getTime() === Date.UTC(2024, 4, 1, 10, 30)", true},
+		{"url.encode('a b&c')", "a+b%26c"},
+		{"url.decode('a+b%26c')", "a b&c"},
+		{"url.encodePath('a b/c')", "a%20b%2Fc"},
+		{"url.query({ q: 'go pipeline', page: 2, tag: ['a', 'b'] })", "page=2&q=go+pipeline&tag=a&tag=b"},
+	}
+
+	for _, tt := range tests {
+		program, err := CompileExpression(tt.expression)
+		if err != nil {
+			t.Fatalf("CompileExpression(%q) failed: %v", tt.expression, err)
+		}
+		got, err := program.Run(emptyInput())
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.expression, got, tt.want)
+		}
+	}
+}
+
+func TestStdlib_Errors(t *testing.T) {
+	for _, expression := range []string{
+		"base64.decode('%%%')",
+		"crypto.hmac('sha3', 'key', 'value')",
+		"crypto.sha256('abc', 'binary')",
+		"dates.parse('yesterday', 'date')",
+		"dates.format(0, 'date', 'Mars/Olympus')",
+	} {
+		program, err := CompileExpression(expression)
+		if err != nil {
+			t.Fatalf("CompileExpression(%q) failed: %v", expression, err)
+		}
+		_, err = program.Run(emptyInput())
+		var runtimeErr *JSRuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected *JSRuntimeError, got %v", expression, err)
+		}
+	}
+}
+
+func TestStdlib_RandomUUID(t *testing.T) {
+	program, err := CompileExpression("crypto.randomUUID()")
+	if err != nil {
+		t.Fatalf("CompileExpression failed: %v", err)
+	}
+	first, _ := program.Run(emptyInput())
+	second, _ := program.Run(emptyInput())
+
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !pattern.MatchString(first.(string)) {
+		t.Errorf("Invalid UUID: %v", first)
+	}
+	if first == second {
+		t.Errorf("Expected different UUIDs, got %v twice", first)
+	}
+}
+
+func TestStdlib_Console(t *testing.T) {
+	type line struct {
+		level   models.LogLevel
+		message string
+	}
+	var lines []line
+	input := emptyInput()
+	input.Log = func(level models.LogLevel, message string) {
+		lines = append(lines, line{level, message})
+	}
+
+	program, err := Compile(`
+console.log("total", 3, { ok: true });
+console.warn("slow");
+console.error(new Error("boom"));
+console.debug([1, 2]);
+return null;`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if _, err := program.Run(input); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	want := []line{
+		{models.LogLevelInfo, `total 3 {"ok":true}`},
+		{models.LogLevelWarn, "slow"},
+		{models.LogLevelError, "Error: boom"},
+		{models.LogLevelDebug, "[1,2]"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %v", len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("Line %d: expected %v, got %v", i, want[i], lines[i])
+		}
+	}
+
+	// Senza Log (fuori da una pipeline) le righe vengono scartate
+	if _, err := program.Run(emptyInput()); err != nil {
+		t.Errorf("Run without Log failed: %v", err)
+	}
+}
+
+func writeModule(t *testing.T, dir, name, source string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "lib/money.js", `
+const rates = require("./rates.json");
+exports.toEUR = function(amount, currency) { return amount * rates[currency]; };
+exports.dir = __dirname;`)
+	writeModule(t, dir, "lib/rates.json", `{"USD": 0.5, "EUR": 1}`)
+	writeModule(t, dir, "lib/format.js", `module.exports = (value) => value.toFixed(2) + " EUR";`)
+
+	program, err := Compile(`
+const money = require("./lib/money");
+const format = require("./lib/format.js");
+return [format(money.toEUR(ctx.webhook.amount, "USD")), money.dir, require("./lib/money") === money];`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	input := &models.StepInput{Data: map[string]map[string]*models.Data{
+		"webhook": models.CreateDefaultResultData(map[string]any{"amount": 10}),
+	}}
+	result, err := program.EnableRequire(dir).Run(input)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	values := result.([]any)
+	if values[0] != "5.00 EUR" {
+		t.Errorf("Expected '5.00 EUR', got %v", values[0])
+	}
+	if values[1] != filepath.Join(dir, "lib") {
+		t.Errorf("Expected __dirname %s, got %v", filepath.Join(dir, "lib"), values[1])
+	}
+	if values[2] != true {
+		t.Error("Expected require to return the cached module")
+	}
+}
+
+func TestRequire_OutsideBaseDir(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "pipelines")
+	writeModule(t, root, "shared/lib.js", "exports.name = 'shared';")
+	writeModule(t, base, "local.js", "exports.name = 'local';")
+	if err := os.Symlink(filepath.Join(root, "shared", "lib.js"), filepath.Join(base, "link.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(code string, allowedDirs ...string) (any, error) {
+		program, err := Compile(code)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", code, err)
+		}
+		return program.EnableRequire(base, allowedDirs...).Run(emptyInput())
+	}
+
+	for _, code := range []string{
+		`return require("../shared/lib").name;`,
+		`return require("` + filepath.ToSlash(filepath.Join(root, "shared", "lib.js")) + `").name;`,
+		`return require("./link").name;`, // Un link simbolico verso l'esterno
+	} {
+		if _, err := run(code); err == nil || !strings.Contains(err.Error(), "outside the directory of the pipeline") {
+			t.Errorf("%s: expected error, got %v", code, err)
+		}
+	}
+
+	if result, err := run(`return require("./local").name;`); err != nil || result != "local" {
+		t.Errorf("Expected local module, got %v (%v)", result, err)
+	}
+	if result, err := run(`return require("../shared/lib").name;`, "../shared"); err != nil || result != "shared" {
+		t.Errorf("Expected module of an allowed directory, got %v (%v)", result, err)
+	}
+}
+
+func TestRequire_ReloadsChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "config.js", "exports.version = 1;")
+
+	program, err := Compile(`return require("./config").version;`)
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	program = program.EnableRequire(dir)
+	if result, err := program.Run(emptyInput()); err != nil || result != int64(1) {
+		t.Fatalf("Expected version 1, got %v (%v)", result, err)
+	}
+
+	writeModule(t, dir, "config.js", "exports.version = 22;")
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(filepath.Join(dir, "config.js"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := program.Run(emptyInput()); err != nil || result != int64(22) {
+		t.Errorf("Expected the edited module, got %v (%v)", result, err)
+	}
+}
+
+func TestRequire_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "broken.js", "exports.x = ;")
+	writeModule(t, dir, "throws.js", "throw new Error('not ready');")
+
+	tests := []struct {
+		code    string
+		message string
+	}{
+		{`return require("lodash");`, "only local modules are supported"},
+		{`return require("./missing");`, "missing.js"},
+		{`return require("./broken");`, "broken.js"},
+		{`return require("./throws");`, "not ready"},
+	}
+
+	for _, tt := range tests {
+		program, err := Compile(tt.code)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", tt.code, err)
+		}
+		_, err = program.EnableRequire(dir).Run(emptyInput())
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.code, tt.message, err)
+		}
+	}
+
+	// Senza EnableRequire (espressioni $js:) require non esiste
+	program, err := CompileExpression("typeof require")
+	if err != nil {
+		t.Fatalf("CompileExpression failed: %v", err)
+	}
+	if result, _ := program.Run(emptyInput()); result != "undefined" {
+		t.Errorf("Expected require to be undefined, got %v", result)
+	}
+}
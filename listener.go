@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// DefaultListenerQueueSize is the queue size of listeners added without options
+const DefaultListenerQueueSize = 1024
+
+// ListenerID identifies a registered listener
+type ListenerID uint64
+
+// OverflowPolicy decides what happens when the queue of a listener is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the pipeline wait for the listener (no event is lost)
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest discards the event being emitted
+	OverflowDropNewest
+	// OverflowDropOldest discards the oldest queued event
+	OverflowDropOldest
+)
+
+// ListenerOptions configures the delivery of events to a listener
+type ListenerOptions struct {
+	QueueSize  int                // Events buffered for the listener (default DefaultListenerQueueSize)
+	Overflow   OverflowPolicy     // What to do when the queue is full (default OverflowBlock)
+	EventTypes []models.EventType // Only deliver these event types (empty: all)
+	StageIDs   []string           // Only deliver stage events of these stages (empty: all); pipeline events are not filtered
+}
+
+// AddListenerWithOptions adds a listener with its own queue size, overflow policy and filters
+func (p *Pipeline) AddListenerWithOptions(listener models.EventListener, opts ListenerOptions) ListenerID {
+	return p.eventBus.addListener(listener, opts)
+}
+
+// RemoveListener stops delivering events to a listener
+// Events still queued for it are discarded; an event being delivered is not interrupted
+func (p *Pipeline) RemoveListener(id ListenerID) bool {
+	return p.eventBus.removeListener(id)
+}
+
+// DroppedEvents returns the number of events a listener lost because of its overflow policy
+func (p *Pipeline) DroppedEvents(id ListenerID) uint64 {
+	return p.eventBus.droppedEvents(id)
+}
+
+// subscription is the queue of a listener
+// A worker goroutine is started when events are queued and exits when the queue is empty
+type subscription struct {
+	id       ListenerID
+	listener models.EventListener
+	size     int
+	overflow OverflowPolicy
+	types    map[models.EventType]bool
+	stages   map[string]bool
+	pending  *sync.WaitGroup // Shared with the bus: one count per queued event
+
+	mutex   sync.Mutex
+	cond    *sync.Cond // Signalled when the queue has room or is closed
+	queue   []models.Event
+	running bool
+	closed  bool
+	dropped uint64
+}
+
+func newSubscription(id ListenerID, listener models.EventListener, opts ListenerOptions, pending *sync.WaitGroup) *subscription {
+	sub := &subscription{
+		id:       id,
+		listener: listener,
+		size:     opts.QueueSize,
+		overflow: opts.Overflow,
+		pending:  pending,
+	}
+	if sub.size <= 0 {
+		sub.size = DefaultListenerQueueSize
+	}
+	if len(opts.EventTypes) > 0 {
+		sub.types = make(map[models.EventType]bool, len(opts.EventTypes))
+		for _, eventType := range opts.EventTypes {
+			sub.types[eventType] = true
+		}
+	}
+	if len(opts.StageIDs) > 0 {
+		sub.stages = make(map[string]bool, len(opts.StageIDs))
+		for _, stageID := range opts.StageIDs {
+			sub.stages[stageID] = true
+		}
+	}
+	sub.cond = sync.NewCond(&sub.mutex)
+	return sub
+}
+
+// accepts reports whether the event passes the subscription filters
+func (s *subscription) accepts(event models.Event) bool {
+	if s.types != nil && !s.types[event.Type] {
+		return false
+	}
+	if s.stages != nil {
+		if stageID := event.StageID(); stageID != "" && !s.stages[stageID] {
+			return false
+		}
+	}
+	return true
+}
+
+// enqueue adds an event to the queue, applying the overflow policy if it is full
+func (s *subscription) enqueue(event models.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for len(s.queue) >= s.size && !s.closed {
+		switch s.overflow {
+		case OverflowDropNewest:
+			s.dropped++
+			return
+		case OverflowDropOldest:
+			s.queue = s.queue[1:]
+			s.dropped++
+			s.pending.Done()
+		default:
+			s.cond.Wait()
+		}
+	}
+	if s.closed {
+		return
+	}
+
+	s.queue = append(s.queue, event)
+	s.pending.Add(1)
+	if !s.running {
+		s.running = true
+		go s.deliver()
+	}
+}
+
+// deliver sends the queued events to the listener, one at a time
+func (s *subscription) deliver() {
+	s.mutex.Lock()
+	for len(s.queue) > 0 && !s.closed {
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+
+		s.listener.OnEvent(event)
+		s.pending.Done()
+
+		s.mutex.Lock()
+	}
+	s.running = false
+	s.mutex.Unlock()
+}
+
+// close discards the queued events and releases the emitters waiting for room
+func (s *subscription) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	for range s.queue {
+		s.pending.Done()
+	}
+	s.queue = nil
+	s.cond.Broadcast()
+}
+
+func (s *subscription) droppedEvents() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
@@ -0,0 +1,196 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// eventRecorder records the events it receives
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []models.Event
+}
+
+func (r *eventRecorder) OnEvent(event models.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) recorded() []models.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]models.Event(nil), r.events...)
+}
+
+func TestEventBus_OrderedDelivery(t *testing.T) {
+	p := NewPipeline()
+	source := NewStage("source", &mockStep{output: "data"})
+	p.AddStage(source)
+	if err := p.AddStage(NewStage("sink", &mockStep{output: "done"})).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+
+	first, second := &eventRecorder{}, &eventRecorder{}
+	p.AddListener(first)
+	p.AddListener(second)
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	events := first.recorded()
+	if len(events) == 0 || events[0].Type != models.EventPipelineStarted || events[len(events)-1].Type != models.EventPipelineCompleted {
+		t.Fatalf("Pipeline events out of order: %v", events)
+	}
+
+	// Every stage reports started -> output -> completed
+	for _, stageID := range []string{"source", "sink"} {
+		var sequence []models.EventType
+		for _, event := range events {
+			if event.StageID() == stageID {
+				sequence = append(sequence, event.Type)
+			}
+		}
+		expected := []models.EventType{models.EventStageStarted, models.EventStageOutput, models.EventStageCompleted}
+		if fmt.Sprint(sequence) != fmt.Sprint(expected) {
+			t.Errorf("Stage '%s' events: %v, expected %v", stageID, sequence, expected)
+		}
+	}
+
+	// All listeners see the same order
+	other := second.recorded()
+	if len(other) != len(events) {
+		t.Fatalf("Listeners received %d and %d events", len(events), len(other))
+	}
+	for i := range events {
+		if events[i].Type != other[i].Type || events[i].StageID() != other[i].StageID() {
+			t.Errorf("Event %d differs between listeners: %s / %s", i, events[i].Type, other[i].Type)
+		}
+	}
+}
+
+// blockingListener blocks on every event until released
+type blockingListener struct {
+	eventRecorder
+	release chan struct{}
+	started chan struct{}
+}
+
+func (b *blockingListener) OnEvent(event models.Event) {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+	b.eventRecorder.OnEvent(event)
+}
+
+func emitNumbered(eb *eventBus, from, count int) {
+	for i := from; i < from+count; i++ {
+		eb.Emit(models.StageStartedEvent{StageID: "s", Attempt: i})
+	}
+}
+
+func TestEventBus_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		expected []int
+	}{
+		// The first event is taken by the listener, the queue holds 2 more
+		{OverflowDropNewest, []int{0, 1, 2}},
+		{OverflowDropOldest, []int{0, 4, 5}},
+	}
+
+	for _, tt := range tests {
+		eb := newEventBus()
+		listener := &blockingListener{release: make(chan struct{}), started: make(chan struct{}, 1)}
+		id := eb.addListener(listener, ListenerOptions{QueueSize: 2, Overflow: tt.policy})
+
+		emitNumbered(eb, 0, 1)
+		<-listener.started
+		emitNumbered(eb, 1, 5)
+		close(listener.release)
+		eb.Wait()
+
+		var got []int
+		for _, event := range listener.recorded() {
+			started, _ := event.AsStageStarted()
+			got = append(got, started.Attempt)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("Policy %d: received %v, expected %v", tt.policy, got, tt.expected)
+		}
+		if dropped := eb.droppedEvents(id); dropped != 3 {
+			t.Errorf("Policy %d: expected 3 dropped events, got %d", tt.policy, dropped)
+		}
+	}
+}
+
+func TestEventBus_BlockPolicy(t *testing.T) {
+	eb := newEventBus()
+	listener := &blockingListener{release: make(chan struct{}), started: make(chan struct{}, 1)}
+	eb.addListener(listener, ListenerOptions{QueueSize: 1})
+
+	emitNumbered(eb, 0, 1)
+	<-listener.started
+	emitNumbered(eb, 1, 1)
+
+	emitted := make(chan struct{})
+	go func() {
+		emitNumbered(eb, 2, 1)
+		close(emitted)
+	}()
+
+	select {
+	case <-emitted:
+		t.Fatal("Emit should block while the listener queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(listener.release)
+	<-emitted
+	eb.Wait()
+	if got := len(listener.recorded()); got != 3 {
+		t.Errorf("Expected 3 events, got %d", got)
+	}
+}
+
+func TestEventBus_FiltersAndRemove(t *testing.T) {
+	p := NewPipeline()
+	filtered := &eventRecorder{}
+	removed := &eventRecorder{}
+	p.AddListenerWithOptions(filtered, ListenerOptions{
+		EventTypes: []models.EventType{models.EventStageOutput, models.EventPipelineStarted},
+		StageIDs:   []string{"b"},
+	})
+	id := p.AddListener(removed)
+
+	p.eventBus.EmitPipelineStarted("batch")
+	p.eventBus.EmitStageOutput("a", "step", 1, models.StepOutput{EventID: "evt_1"})
+	p.eventBus.EmitStageOutput("b", "step", 1, models.StepOutput{EventID: "evt_1"})
+	p.eventBus.EmitStageStarted("b", "step", "evt_2", 1)
+	p.eventBus.Wait()
+
+	if !p.RemoveListener(id) {
+		t.Fatal("RemoveListener returned false for a registered listener")
+	}
+	if p.RemoveListener(id) {
+		t.Error("RemoveListener returned true for a removed listener")
+	}
+	p.eventBus.EmitPipelineCompleted(time.Second)
+	p.eventBus.Wait()
+
+	events := filtered.recorded()
+	if len(events) != 2 || events[0].Type != models.EventPipelineStarted || events[1].StageID() != "b" {
+		t.Errorf("Unexpected filtered events: %v", events)
+	}
+	if got := len(removed.recorded()); got != 4 {
+		t.Errorf("Removed listener received %d events, expected 4", got)
+	}
+}
@@ -0,0 +1,209 @@
+// Package logging provides an EventListener that writes pipeline events to a log/slog
+// handler, with a level per event type, sampling of stage outputs and redaction of secrets.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// DefaultLevels are the levels used for event types not set in Options.Levels
+// stage.log records use the level of the line written by the step, unless set in Options.Levels
+var DefaultLevels = map[models.EventType]slog.Level{
+	models.EventPipelineStarted:   slog.LevelInfo,
+	models.EventPipelineCompleted: slog.LevelInfo,
+	models.EventPipelineError:     slog.LevelError,
+	models.EventStageStarted:      slog.LevelDebug,
+	models.EventStageCompleted:    slog.LevelDebug,
+	models.EventStageOutput:       slog.LevelInfo,
+	models.EventStageError:        slog.LevelError,
+}
+
+// Options configures a Listener
+type Options struct {
+	// Level is the minimum level logged by the handlers created by NewJSON and NewText
+	Level slog.Leveler
+	// Levels overrides the level of some event types (see DefaultLevels)
+	Levels map[models.EventType]slog.Level
+	// OutputSampling logs one stage.output event out of N for each stage (0 or 1 logs all)
+	OutputSampling int
+	// IncludeOutput adds the output values to stage.output records
+	IncludeOutput bool
+	// Secrets are redacted from every logged value (typically Pipeline.GlobalSecrets())
+	Secrets map[string]any
+}
+
+// Listener is an EventListener that logs pipeline events with log/slog
+type Listener struct {
+	handler        slog.Handler
+	levels         map[models.EventType]slog.Level
+	fixedLogLevel  bool // stage.log level set in Options.Levels
+	outputSampling uint64
+	includeOutput  bool
+
+	mutex    sync.Mutex
+	outputs  map[string]uint64 // Stage ID -> stage.output events seen
+	redactor *redactor
+}
+
+// New creates a listener writing to handler
+func New(handler slog.Handler, opts Options) *Listener {
+	levels := make(map[models.EventType]slog.Level, len(DefaultLevels))
+	for eventType, level := range DefaultLevels {
+		levels[eventType] = level
+	}
+	for eventType, level := range opts.Levels {
+		levels[eventType] = level
+	}
+
+	_, fixedLogLevel := opts.Levels[models.EventStageLog]
+
+	sampling := uint64(1)
+	if opts.OutputSampling > 1 {
+		sampling = uint64(opts.OutputSampling)
+	}
+
+	return &Listener{
+		handler:        handler,
+		levels:         levels,
+		fixedLogLevel:  fixedLogLevel,
+		outputSampling: sampling,
+		includeOutput:  opts.IncludeOutput,
+		outputs:        make(map[string]uint64),
+		redactor:       newRedactor(opts.Secrets),
+	}
+}
+
+// NewJSON creates a listener writing JSON records to w
+func NewJSON(w io.Writer, opts Options) *Listener {
+	return New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: opts.Level}), opts)
+}
+
+// NewText creates a listener writing key=value records to w
+func NewText(w io.Writer, opts Options) *Listener {
+	return New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: opts.Level}), opts)
+}
+
+// SetSecrets replaces the values redacted from the logs
+func (l *Listener) SetSecrets(secrets map[string]any) {
+	r := newRedactor(secrets)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.redactor = r
+}
+
+// OnEvent implements models.EventListener
+func (l *Listener) OnEvent(event models.Event) {
+	ctx := context.Background()
+	level, ok := l.levels[event.Type]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	if payload, isLog := event.AsStageLog(); isLog && !l.fixedLogLevel {
+		level = stepLogLevel(payload.Level)
+	}
+	if !l.handler.Enabled(ctx, level) {
+		return
+	}
+
+	stageID := event.StageID()
+
+	l.mutex.Lock()
+	redact := l.redactor
+	if event.Type == models.EventStageOutput {
+		seen := l.outputs[stageID]
+		l.outputs[stageID] = seen + 1
+		if seen%l.outputSampling != 0 {
+			l.mutex.Unlock()
+			return
+		}
+	}
+	l.mutex.Unlock()
+
+	record := slog.NewRecord(event.Timestamp, level, string(event.Type), 0)
+	stageAttrs := func(stageID, stepID, eventID string, attempt int) {
+		record.AddAttrs(
+			slog.String("stage_id", stageID),
+			slog.String("step_id", stepID),
+			slog.String("event_id", eventID),
+			slog.Int("attempt", attempt),
+		)
+	}
+
+	switch payload := event.Payload.(type) {
+	case models.PipelineStartedEvent:
+		record.AddAttrs(slog.String("mode", payload.Mode))
+
+	case models.PipelineCompletedEvent:
+		record.AddAttrs(slog.Duration("duration", payload.Duration))
+
+	case models.PipelineErrorEvent:
+		record.AddAttrs(slog.String("error", redact.string(payload.Error)))
+
+	case models.StageStartedEvent:
+		stageAttrs(payload.StageID, payload.StepID, payload.EventID, payload.Attempt)
+
+	case models.StageCompletedEvent:
+		stageAttrs(payload.StageID, payload.StepID, payload.EventID, payload.Attempt)
+		record.AddAttrs(slog.Duration("duration", payload.Duration))
+
+	case models.StageErrorEvent:
+		stageAttrs(payload.StageID, payload.StepID, payload.EventID, payload.Attempt)
+		record.AddAttrs(slog.Duration("duration", payload.Duration))
+		if payload.StatusCode != 0 {
+			record.AddAttrs(slog.Int("status_code", payload.StatusCode))
+		}
+		record.AddAttrs(slog.String("error", redact.string(payload.Error)))
+
+	case models.StageLogEvent:
+		record.AddAttrs(
+			slog.String("stage_id", payload.StageID),
+			slog.String("step_id", payload.StepID),
+			slog.String("event_id", payload.EventID),
+			slog.String("message", redact.string(payload.Message)),
+		)
+
+	case models.StageOutputEvent:
+		stageAttrs(payload.StageID, payload.StepID, payload.EventID, payload.Attempt)
+		if l.outputSampling > 1 {
+			record.AddAttrs(slog.Uint64("sampling", l.outputSampling))
+		}
+		if len(payload.Metadata) > 0 {
+			record.AddAttrs(slog.Any("metadata", redact.value(payload.Metadata)))
+		}
+		if l.includeOutput {
+			record.AddAttrs(slog.Any("output", redact.value(outputValues(payload.Output))))
+		}
+	}
+
+	l.handler.Handle(ctx, record)
+}
+
+// outputValues returns the values of a stage output by port
+func outputValues(output map[string]*models.Data) map[string]any {
+	values := make(map[string]any, len(output))
+	for port, data := range output {
+		if data != nil {
+			values[port] = data.Value
+		}
+	}
+	return values
+}
+
+// stepLogLevel converts the level of a line written by a step
+func stepLogLevel(level models.LogLevel) slog.Level {
+	switch level {
+	case models.LogLevelDebug:
+		return slog.LevelDebug
+	case models.LogLevelWarn:
+		return slog.LevelWarn
+	case models.LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
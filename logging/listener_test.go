@@ -0,0 +1,182 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func outputEvent(stageID string, value any, metadata map[string]any) models.Event {
+	return models.NewEvent(models.StageOutputEvent{
+		StageID:  stageID,
+		StepID:   "http_client",
+		EventID:  "evt_1",
+		Attempt:  1,
+		Output:   models.CreateDefaultResultData(value),
+		Metadata: metadata,
+	})
+}
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid JSON record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestListener_RedactsSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	listener := NewJSON(&buf, Options{
+		IncludeOutput: true,
+		Secrets: map[string]any{
+			"api_token": "s3cr3t-token",
+			"nested":    map[string]any{"pin": 987654},
+		},
+	})
+
+	listener.OnEvent(outputEvent("fetch", map[string]any{
+		"echo":  "Bearer s3cr3t-token",
+		"items": []any{"ok", "s3cr3t-token"},
+		"pin":   987654,
+	}, map[string]any{
+		"http": map[string]any{"url": "https://api.example.com?token=s3cr3t-token", "status_code": 200},
+	}))
+	err := errors.New("invalid token s3cr3t-token")
+	listener.OnEvent(models.NewEvent(models.StageErrorEvent{
+		StageID: "fetch",
+		EventID: "evt_1",
+		Error:   err.Error(),
+		Err:     err,
+	}))
+
+	text := buf.String()
+	if strings.Contains(text, "s3cr3t-token") || strings.Contains(text, "987654") {
+		t.Fatalf("Secret leaked in logs:\n%s", text)
+	}
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	output := records[0]["output"].(map[string]any)["default"].(map[string]any)
+	if output["echo"] != "Bearer "+Redacted {
+		t.Errorf("Unexpected echo value: %v", output["echo"])
+	}
+	if records[1]["level"] != "ERROR" || records[1]["error"] != "invalid token "+Redacted {
+		t.Errorf("Unexpected error record: %v", records[1])
+	}
+}
+
+func TestListener_RedactsShortSecretsOnlyAsWholeValues(t *testing.T) {
+	var buf bytes.Buffer
+	listener := NewJSON(&buf, Options{
+		IncludeOutput: true,
+		Secrets:       map[string]any{"db_index": 1, "port": 5432, "code": "ab"},
+	})
+
+	listener.OnEvent(outputEvent("fetch", map[string]any{
+		"summary": "fetched 12 items from db:5432 in 1.5s (tab)",
+		"index":   1,
+		"port":    5432,
+		"code":    "ab",
+		"count":   12,
+	}, nil))
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	if records[0]["stage_id"] != "fetch" || records[0]["attempt"] != float64(1) {
+		t.Errorf("Unexpected record attributes: %v", records[0])
+	}
+	output := records[0]["output"].(map[string]any)["default"].(map[string]any)
+	// Il testo normale che contiene i secret corti resta intatto
+	if want := "fetched 12 items from db:5432 in 1.5s (tab)"; output["summary"] != want {
+		t.Errorf("Expected summary %q, got %v", want, output["summary"])
+	}
+	if output["count"] != float64(12) {
+		t.Errorf("Expected count 12, got %v", output["count"])
+	}
+	for _, key := range []string{"index", "port", "code"} {
+		if output[key] != Redacted {
+			t.Errorf("Expected %s to be redacted, got %v", key, output[key])
+		}
+	}
+}
+
+func TestListener_LevelsAndSampling(t *testing.T) {
+	var buf bytes.Buffer
+	listener := NewJSON(&buf, Options{
+		Level:          slog.LevelInfo,
+		Levels:         map[models.EventType]slog.Level{models.EventPipelineStarted: slog.LevelWarn},
+		OutputSampling: 3,
+	})
+
+	listener.OnEvent(models.NewEvent(models.PipelineStartedEvent{Mode: "batch"}))
+	// Debug by default: filtered by the handler level
+	listener.OnEvent(models.NewEvent(models.StageStartedEvent{StageID: "fetch"}))
+	for i := 0; i < 7; i++ {
+		listener.OnEvent(outputEvent("fetch", i, nil))
+	}
+	listener.OnEvent(outputEvent("other", "x", nil))
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 5 {
+		t.Fatalf("Expected 5 records (1 started, 3 sampled fetch outputs, 1 other output), got %d:\n%s", len(records), buf.String())
+	}
+	if records[0]["level"] != "WARN" || records[0]["mode"] != "batch" {
+		t.Errorf("Unexpected pipeline record: %v", records[0])
+	}
+	if records[1]["sampling"] != float64(3) {
+		t.Errorf("Expected sampling attribute, got %v", records[1])
+	}
+	if _, ok := records[1]["output"]; ok {
+		t.Error("Output should not be logged unless IncludeOutput is set")
+	}
+}
+
+func TestListener_StageLogLevels(t *testing.T) {
+	var buf bytes.Buffer
+	listener := NewJSON(&buf, Options{Level: slog.LevelInfo})
+
+	listener.OnEvent(models.NewEvent(models.StageLogEvent{StageID: "transform", Level: models.LogLevelDebug, Message: "hidden"}))
+	listener.OnEvent(models.NewEvent(models.StageLogEvent{StageID: "transform", Level: models.LogLevelError, Message: "failed"}))
+
+	records := decodeRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record (debug filtered), got %d:\n%s", len(records), buf.String())
+	}
+	if records[0]["level"] != "ERROR" || records[0]["msg"] != "stage.log" || records[0]["message"] != "failed" {
+		t.Errorf("Unexpected stage.log record: %v", records[0])
+	}
+}
+
+func TestListener_TextHandler(t *testing.T) {
+	var buf bytes.Buffer
+	listener := NewText(&buf, Options{})
+	listener.SetSecrets(map[string]any{"password": "hunter22"})
+
+	listener.OnEvent(models.NewEvent(models.PipelineErrorEvent{Error: "login failed for hunter22"}))
+
+	line := buf.String()
+	if !strings.Contains(line, "level=ERROR") || !strings.Contains(line, "msg=pipeline.error") {
+		t.Errorf("Unexpected text record: %s", line)
+	}
+	if strings.Contains(line, "hunter22") {
+		t.Errorf("Secret leaked: %s", line)
+	}
+}
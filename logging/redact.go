@@ -0,0 +1,152 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// Redacted replaces secret values in the logs
+const Redacted = "[REDACTED]"
+
+// MinSecretLength is the length from which string secrets are redacted inside longer strings
+// Shorter secrets and numbers are redacted only from values equal to them: replacing "1" or
+// a port number everywhere would wipe unrelated text
+const MinSecretLength = 4
+
+// redactor replaces secret values found in strings
+type redactor struct {
+	replacer *strings.Replacer // Secrets redacted inside strings (nil if there are none)
+	exact    map[string]bool   // All the secrets, redacted from the values equal to them
+}
+
+// newRedactor collects the secret values (nested maps and lists included)
+func newRedactor(secrets map[string]any) *redactor {
+	var values, numbers []string
+	collectSecrets(secrets, &values, &numbers)
+	if len(values) == 0 && len(numbers) == 0 {
+		return &redactor{}
+	}
+
+	exact := make(map[string]bool, len(values)+len(numbers))
+	var long []string
+	for _, value := range values {
+		exact[value] = true
+		if len(value) >= MinSecretLength {
+			long = append(long, value)
+		}
+	}
+	for _, number := range numbers {
+		exact[number] = true
+	}
+	if len(long) == 0 {
+		return &redactor{exact: exact}
+	}
+
+	// Longer secrets first, so a secret containing another one is fully redacted
+	sort.Slice(long, func(i, j int) bool { return len(long[i]) > len(long[j]) })
+	pairs := make([]string, 0, 2*len(long))
+	for _, value := range long {
+		pairs = append(pairs, value, Redacted)
+	}
+	return &redactor{replacer: strings.NewReplacer(pairs...), exact: exact}
+}
+
+// collectSecrets collects the string secrets into values and the numeric ones into numbers
+func collectSecrets(value any, values, numbers *[]string) {
+	switch v := value.(type) {
+	case nil, bool:
+	case string:
+		if v != "" {
+			*values = append(*values, v)
+		}
+	case map[string]any:
+		for _, item := range v {
+			collectSecrets(item, values, numbers)
+		}
+	case []any:
+		for _, item := range v {
+			collectSecrets(item, values, numbers)
+		}
+	default:
+		if kind := reflect.ValueOf(v).Kind(); kind >= reflect.Int && kind <= reflect.Float64 {
+			*numbers = append(*numbers, fmt.Sprint(v))
+		}
+	}
+}
+
+// string redacts the secrets contained in s
+func (r *redactor) string(s string) string {
+	if r.exact[s] {
+		return Redacted
+	}
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// value returns a copy of v with the secrets redacted from all strings
+// Values that are not strings, numbers, maps or lists are converted through JSON first
+func (r *redactor) value(v any) any {
+	if len(r.exact) == 0 {
+		return v
+	}
+
+	switch v := v.(type) {
+	case nil, bool:
+		return v
+	case string:
+		return r.string(v)
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = r.value(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = r.value(item)
+		}
+		return result
+	case *models.Data:
+		if v == nil {
+			return nil
+		}
+		return r.value(v.Value)
+	case models.Data:
+		return r.value(v.Value)
+	case error:
+		return r.string(v.Error())
+	case fmt.Stringer:
+		return r.string(v.String())
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// A numeric secret is still a secret, but only as a whole value
+		if r.exact[fmt.Sprint(v)] {
+			return Redacted
+		}
+		return v
+	}
+
+	// Structs, typed maps and slices: redact their JSON representation
+	data, err := json.Marshal(v)
+	if err != nil {
+		return r.string(fmt.Sprint(v))
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return r.string(string(data))
+	}
+	return r.value(generic)
+}
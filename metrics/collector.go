@@ -0,0 +1,146 @@
+// Package metrics provides an EventListener that aggregates pipeline events into
+// Prometheus metrics and serves them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"sync"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// DefaultBuckets are the histogram buckets (in seconds) used for durations
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// stageKey identifies a stage in metric labels
+type stageKey struct {
+	stage string
+	step  string
+}
+
+// httpKey identifies an HTTP status code returned to a service
+type httpKey struct {
+	service string
+	code    int
+}
+
+// histogram is a cumulative Prometheus histogram
+type histogram struct {
+	counts []uint64 // One counter per bucket (non cumulative)
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(buckets []float64, value float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// Collector is an EventListener that keeps counters, gauges and histograms about
+// pipeline runs, stage executions and HTTP responses
+type Collector struct {
+	mutex   sync.Mutex
+	buckets []float64
+
+	pipelineRuns     map[string]uint64 // mode -> runs started
+	pipelineRunning  int64
+	pipelineDuration histogram
+	pipelineErrors   uint64
+
+	stageStarted   map[stageKey]uint64
+	stageCompleted map[stageKey]uint64
+	stageOutputs   map[stageKey]uint64
+	stageErrors    map[stageKey]uint64
+	stageInFlight  map[stageKey]map[string]bool // Event IDs started and not yet completed or failed
+	stageDuration  map[stageKey]*histogram
+
+	httpResponses map[httpKey]uint64
+
+	queueDepth func() map[string]int // Optional: source of the stage queue depths
+}
+
+// NewCollector creates a collector using DefaultBuckets
+func NewCollector() *Collector {
+	return &Collector{
+		buckets:        DefaultBuckets,
+		pipelineRuns:   make(map[string]uint64),
+		stageStarted:   make(map[stageKey]uint64),
+		stageCompleted: make(map[stageKey]uint64),
+		stageOutputs:   make(map[stageKey]uint64),
+		stageErrors:    make(map[stageKey]uint64),
+		stageInFlight:  make(map[stageKey]map[string]bool),
+		stageDuration:  make(map[stageKey]*histogram),
+		httpResponses:  make(map[httpKey]uint64),
+	}
+}
+
+// SetQueueDepthFunc sets the function used to read the number of outputs waiting
+// to be consumed by each stage (typically Pipeline.QueueDepths)
+func (c *Collector) SetQueueDepthFunc(fn func() map[string]int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.queueDepth = fn
+}
+
+// OnEvent implements models.EventListener
+func (c *Collector) OnEvent(event models.Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch payload := event.Payload.(type) {
+	case models.PipelineStartedEvent:
+		c.pipelineRuns[payload.Mode]++
+		c.pipelineRunning++
+
+	case models.PipelineCompletedEvent:
+		c.pipelineRunning--
+		c.pipelineDuration.observe(c.buckets, payload.Duration.Seconds())
+
+	case models.PipelineErrorEvent:
+		c.pipelineErrors++
+
+	case models.StageStartedEvent:
+		key := stageKey{stage: payload.StageID, step: payload.StepID}
+		c.stageStarted[key]++
+		if c.stageInFlight[key] == nil {
+			c.stageInFlight[key] = make(map[string]bool)
+		}
+		c.stageInFlight[key][payload.EventID] = true
+
+	case models.StageCompletedEvent:
+		key := stageKey{stage: payload.StageID, step: payload.StepID}
+		c.stageCompleted[key]++
+		delete(c.stageInFlight[key], payload.EventID)
+		h, exists := c.stageDuration[key]
+		if !exists {
+			h = &histogram{}
+			c.stageDuration[key] = h
+		}
+		h.observe(c.buckets, payload.Duration.Seconds())
+
+	case models.StageOutputEvent:
+		key := stageKey{stage: payload.StageID, step: payload.StepID}
+		c.stageOutputs[key]++
+		if httpInfo, ok := payload.Metadata["http"].(map[string]any); ok {
+			if code, ok := httpInfo["status_code"].(int); ok {
+				c.httpResponses[httpKey{service: key.step, code: code}]++
+			}
+		}
+
+	case models.StageErrorEvent:
+		key := stageKey{stage: payload.StageID, step: payload.StepID}
+		c.stageErrors[key]++
+		delete(c.stageInFlight[key], payload.EventID)
+		if payload.StatusCode != 0 {
+			c.httpResponses[httpKey{service: key.step, code: payload.StatusCode}]++
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func TestCollector_StageMetrics(t *testing.T) {
+	c := NewCollector()
+
+	c.OnEvent(models.NewEvent(models.PipelineStartedEvent{Mode: "batch"}))
+	for _, eventID := range []string{"evt_1", "evt_2", "evt_3"} {
+		c.OnEvent(models.NewEvent(models.StageStartedEvent{StageID: "fetch", StepID: "http", EventID: eventID, Attempt: 1}))
+	}
+	c.OnEvent(models.NewEvent(models.StageOutputEvent{
+		StageID: "fetch", StepID: "http", EventID: "evt_1", Attempt: 1,
+		Metadata: map[string]any{"http": map[string]any{"status_code": 200}},
+	}))
+	c.OnEvent(models.NewEvent(models.StageCompletedEvent{
+		StageID: "fetch", StepID: "http", EventID: "evt_1", Attempt: 1, Duration: 30 * time.Millisecond,
+	}))
+	c.OnEvent(models.NewEvent(models.StageErrorEvent{
+		StageID: "fetch", StepID: "http", EventID: "evt_2", Attempt: 1, StatusCode: 503,
+	}))
+
+	var out strings.Builder
+	if _, err := c.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	text := out.String()
+
+	expected := []string{
+		`go_pipeline_pipeline_runs_total{mode="batch"} 1`,
+		`go_pipeline_pipeline_running 1`,
+		`go_pipeline_stage_started_total{stage="fetch",step="http"} 3`,
+		`go_pipeline_stage_completed_total{stage="fetch",step="http"} 1`,
+		`go_pipeline_stage_outputs_total{stage="fetch",step="http"} 1`,
+		`go_pipeline_stage_errors_total{stage="fetch",step="http"} 1`,
+		`go_pipeline_stage_events_in_flight{stage="fetch",step="http"} 1`,
+		`go_pipeline_stage_duration_seconds_bucket{stage="fetch",step="http",le="0.025"} 0`,
+		`go_pipeline_stage_duration_seconds_bucket{stage="fetch",step="http",le="0.05"} 1`,
+		`go_pipeline_stage_duration_seconds_bucket{stage="fetch",step="http",le="+Inf"} 1`,
+		`go_pipeline_stage_duration_seconds_count{stage="fetch",step="http"} 1`,
+		`go_pipeline_http_responses_total{service="http",code="200"} 1`,
+		`go_pipeline_http_responses_total{service="http",code="503"} 1`,
+		`# TYPE go_pipeline_stage_duration_seconds histogram`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Missing line %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestCollector_Handler(t *testing.T) {
+	c := NewCollector()
+	c.SetQueueDepthFunc(func() map[string]int {
+		return map[string]int{`we"ird`: 4}
+	})
+
+	server := httptest.NewServer(c.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type: %s", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `go_pipeline_stage_queue_depth{stage="we\"ird"} 4`) {
+		t.Errorf("Missing escaped queue depth in:\n%s", body)
+	}
+}
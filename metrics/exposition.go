@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// namespace is the prefix of all exported metric names
+const namespace = "go_pipeline"
+
+// contentType is the Prometheus text exposition format content type
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// label is a metric label pair
+type label struct {
+	name  string
+	value string
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mutex.Lock()
+	queueDepth := c.queueDepth
+	c.mutex.Unlock()
+
+	// Read the queue depths outside the collector lock
+	var depths map[string]int
+	if queueDepth != nil {
+		depths = queueDepth()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ew := &expositionWriter{w: bufio.NewWriter(w)}
+
+	ew.header("pipeline_runs_total", "counter", "Pipeline runs started, by execution mode")
+	for _, mode := range sortedKeys(c.pipelineRuns) {
+		ew.sample("pipeline_runs_total", []label{{"mode", mode}}, float64(c.pipelineRuns[mode]))
+	}
+
+	ew.header("pipeline_running", "gauge", "Pipelines currently running")
+	ew.sample("pipeline_running", nil, float64(c.pipelineRunning))
+
+	ew.header("pipeline_errors_total", "counter", "Pipeline-level errors")
+	ew.sample("pipeline_errors_total", nil, float64(c.pipelineErrors))
+
+	ew.header("pipeline_duration_seconds", "histogram", "Duration of completed pipeline runs")
+	ew.histogram("pipeline_duration_seconds", nil, c.buckets, &c.pipelineDuration)
+
+	ew.stageCounter("stage_started_total", "counter", "Events received by stages", c.stageStarted)
+	ew.stageCounter("stage_completed_total", "counter", "Events processed successfully by stages", c.stageCompleted)
+	ew.stageCounter("stage_outputs_total", "counter", "Outputs produced by stages", c.stageOutputs)
+	ew.stageCounter("stage_errors_total", "counter", "Errors returned by stages", c.stageErrors)
+
+	inFlight := make(map[stageKey]uint64, len(c.stageInFlight))
+	for key, events := range c.stageInFlight {
+		inFlight[key] = uint64(len(events))
+	}
+	ew.stageCounter("stage_events_in_flight", "gauge", "Events currently being processed by stages", inFlight)
+
+	ew.header("stage_duration_seconds", "histogram", "Time spent by stages processing an event")
+	for _, key := range sortedStageKeys(c.stageDuration) {
+		ew.histogram("stage_duration_seconds", key.labels(), c.buckets, c.stageDuration[key])
+	}
+
+	ew.header("stage_queue_depth", "gauge", "Outputs waiting to be consumed by stages")
+	for _, stage := range sortedKeys(depths) {
+		ew.sample("stage_queue_depth", []label{{"stage", stage}}, float64(depths[stage]))
+	}
+
+	ew.header("http_responses_total", "counter", "HTTP responses received by service and status code")
+	httpKeys := make([]httpKey, 0, len(c.httpResponses))
+	for key := range c.httpResponses {
+		httpKeys = append(httpKeys, key)
+	}
+	sort.Slice(httpKeys, func(i, j int) bool {
+		if httpKeys[i].service != httpKeys[j].service {
+			return httpKeys[i].service < httpKeys[j].service
+		}
+		return httpKeys[i].code < httpKeys[j].code
+	})
+	for _, key := range httpKeys {
+		labels := []label{{"service", key.service}, {"code", strconv.Itoa(key.code)}}
+		ew.sample("http_responses_total", labels, float64(c.httpResponses[key]))
+	}
+
+	if ew.err == nil {
+		ew.err = ew.w.Flush()
+	}
+	return ew.n, ew.err
+}
+
+// Handler returns an http.Handler serving the metrics
+func (c *Collector) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		c.WriteTo(w)
+	})
+}
+
+// ListenAndServe serves the metrics on addr (e.g. "127.0.0.1:9090") at path (default "/metrics")
+// It blocks until the context is cancelled or the server fails
+func (c *Collector) ListenAndServe(ctx context.Context, addr, path string) error {
+	if path == "" {
+		path = "/metrics"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, c.Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("metrics server failed: %w", err)
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			return fmt.Errorf("metrics server shutdown failed: %w", err)
+		}
+		if err := <-errChan; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("metrics server failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// labels returns the metric labels of a stage
+func (k stageKey) labels() []label {
+	return []label{{"stage", k.stage}, {"step", k.step}}
+}
+
+// expositionWriter writes samples and remembers the first error
+type expositionWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (ew *expositionWriter) printf(format string, args ...any) {
+	if ew.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(ew.w, format, args...)
+	ew.n += int64(n)
+	ew.err = err
+}
+
+func (ew *expositionWriter) header(name, metricType, help string) {
+	ew.printf("# HELP %s_%s %s\n", namespace, name, help)
+	ew.printf("# TYPE %s_%s %s\n", namespace, name, metricType)
+}
+
+func (ew *expositionWriter) sample(name string, labels []label, value float64) {
+	ew.printf("%s_%s%s %s\n", namespace, name, formatLabels(labels), formatValue(value))
+}
+
+func (ew *expositionWriter) stageCounter(name, metricType, help string, values map[stageKey]uint64) {
+	ew.header(name, metricType, help)
+	for _, key := range sortedStageKeys(values) {
+		ew.sample(name, key.labels(), float64(values[key]))
+	}
+}
+
+func (ew *expositionWriter) histogram(name string, labels []label, buckets []float64, h *histogram) {
+	cumulative := uint64(0)
+	for i, bound := range buckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		bucketLabels := append(append([]label{}, labels...), label{"le", formatValue(bound)})
+		ew.sample(name+"_bucket", bucketLabels, float64(cumulative))
+	}
+	ew.sample(name+"_bucket", append(append([]label{}, labels...), label{"le", "+Inf"}), float64(h.count))
+	ew.sample(name+"_sum", labels, h.sum)
+	ew.sample(name+"_count", labels, float64(h.count))
+}
+
+// labelEscaper escapes label values as required by the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf(`%s="%s"`, l.name, labelEscaper.Replace(l.value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedStageKeys[V any](m map[stageKey]V) []stageKey {
+	keys := make([]stageKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].stage != keys[j].stage {
+			return keys[i].stage < keys[j].stage
+		}
+		return keys[i].step < keys[j].step
+	})
+	return keys
+}
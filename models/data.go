@@ -3,7 +3,7 @@ package models
 import "fmt"
 
 type Data struct {
-	Value any
+	Value any `json:"value"`
 }
 
 func (d *Data) String() string {
@@ -21,3 +21,18 @@ func CreateResultData(name string, value any) map[string]*Data {
 		},
 	}
 }
+
+// OutputValue returns the value of a stage output as seen by expressions: the value of the
+// "default" port if it is the only one, otherwise a map of the port values
+func OutputValue(outputs map[string]*Data) any {
+	if data, ok := outputs["default"]; ok && len(outputs) == 1 && data != nil {
+		return data.Value
+	}
+	values := make(map[string]any, len(outputs))
+	for name, data := range outputs {
+		if data != nil {
+			values[name] = data.Value
+		}
+	}
+	return values
+}
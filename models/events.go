@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -18,6 +20,7 @@ const (
 	EventStageCompleted EventType = "stage.completed"
 	EventStageError     EventType = "stage.error"
 	EventStageOutput    EventType = "stage.output"
+	EventStageLog       EventType = "stage.log"
 
 	// Eventi degli step
 	EventStepStarted   EventType = "step.started"
@@ -25,11 +28,26 @@ const (
 	EventStepError     EventType = "step.error"
 )
 
+// EventSchemaVersion is the version of the JSON representation of events
+// It changes only when a field is removed or changes meaning
+const EventSchemaVersion = 1
+
+// EventPayload is the typed content of an event (one of the *Event structs below)
+type EventPayload interface {
+	EventType() EventType
+}
+
 // Event rappresenta un evento generico della pipeline
+// Payload contains one of the *Event structs of this package; use the As* accessors to read it
 type Event struct {
-	Type      EventType              `json:"type"`
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data"`
+	Type      EventType
+	Timestamp time.Time
+	Payload   EventPayload
+}
+
+// NewEvent creates an event for payload with the current time
+func NewEvent(payload EventPayload) Event {
+	return Event{Type: payload.EventType(), Timestamp: time.Now(), Payload: payload}
 }
 
 // PipelineStartedEvent evento emesso all'avvio della pipeline
@@ -39,12 +57,13 @@ type PipelineStartedEvent struct {
 
 // PipelineCompletedEvent evento emesso al completamento della pipeline
 type PipelineCompletedEvent struct {
-	Duration time.Duration `json:"duration"`
+	Duration time.Duration `json:"duration"` // Nanoseconds
 }
 
 // PipelineErrorEvent evento emesso in caso di errore della pipeline
 type PipelineErrorEvent struct {
 	Error string `json:"error"`
+	Err   error  `json:"-"` // Original error (not serialized)
 }
 
 // StageStartedEvent evento emesso all'avvio di uno stage
@@ -52,6 +71,7 @@ type StageStartedEvent struct {
 	StageID string `json:"stage_id"`
 	StepID  string `json:"step_id"`
 	EventID string `json:"event_id"`
+	Attempt int    `json:"attempt"`
 }
 
 // StageCompletedEvent evento emesso al completamento di uno stage
@@ -59,15 +79,20 @@ type StageCompletedEvent struct {
 	StageID  string        `json:"stage_id"`
 	StepID   string        `json:"step_id"`
 	EventID  string        `json:"event_id"`
-	Duration time.Duration `json:"duration"`
+	Attempt  int           `json:"attempt"`
+	Duration time.Duration `json:"duration"` // Nanoseconds
 }
 
 // StageErrorEvent evento emesso in caso di errore di uno stage
 type StageErrorEvent struct {
-	StageID string `json:"stage_id"`
-	StepID  string `json:"step_id"`
-	EventID string `json:"event_id"`
-	Error   string `json:"error"`
+	StageID    string        `json:"stage_id"`
+	StepID     string        `json:"step_id"`
+	EventID    string        `json:"event_id"`
+	Attempt    int           `json:"attempt"`
+	Duration   time.Duration `json:"duration"` // Time spent on the event before failing (nanoseconds)
+	Error      string        `json:"error"`
+	StatusCode int           `json:"status_code,omitempty"` // HTTP status code, for errors exposing HTTPStatusCode()
+	Err        error         `json:"-"`                     // Original error (not serialized)
 }
 
 // StageOutputEvent event emitted when a stage produces output
@@ -75,11 +100,167 @@ type StageOutputEvent struct {
 	StageID   string                 `json:"stage_id"`
 	StepID    string                 `json:"step_id"`
 	EventID   string                 `json:"event_id"`
+	Attempt   int                    `json:"attempt"` // 0 if the output does not answer an input (e.g. a trigger)
 	Output    map[string]*Data       `json:"output"`
-	Timestamp time.Time              `json:"timestamp"`
+	Timestamp time.Time              `json:"timestamp"` // Time the step produced the output
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// StageLogEvent event emitted when a step writes a log line (e.g. console.log in a js step)
+type StageLogEvent struct {
+	StageID string   `json:"stage_id"`
+	StepID  string   `json:"step_id"`
+	EventID string   `json:"event_id"`
+	Level   LogLevel `json:"level"`
+	Message string   `json:"message"`
+}
+
+func (PipelineStartedEvent) EventType() EventType   { return EventPipelineStarted }
+func (PipelineCompletedEvent) EventType() EventType { return EventPipelineCompleted }
+func (PipelineErrorEvent) EventType() EventType     { return EventPipelineError }
+func (StageStartedEvent) EventType() EventType      { return EventStageStarted }
+func (StageCompletedEvent) EventType() EventType    { return EventStageCompleted }
+func (StageErrorEvent) EventType() EventType        { return EventStageError }
+func (StageOutputEvent) EventType() EventType       { return EventStageOutput }
+func (StageLogEvent) EventType() EventType          { return EventStageLog }
+
+// AsPipelineStarted returns the payload of a pipeline.started event
+func (e Event) AsPipelineStarted() (PipelineStartedEvent, bool) {
+	payload, ok := e.Payload.(PipelineStartedEvent)
+	return payload, ok
+}
+
+// AsPipelineCompleted returns the payload of a pipeline.completed event
+func (e Event) AsPipelineCompleted() (PipelineCompletedEvent, bool) {
+	payload, ok := e.Payload.(PipelineCompletedEvent)
+	return payload, ok
+}
+
+// AsPipelineError returns the payload of a pipeline.error event
+func (e Event) AsPipelineError() (PipelineErrorEvent, bool) {
+	payload, ok := e.Payload.(PipelineErrorEvent)
+	return payload, ok
+}
+
+// AsStageStarted returns the payload of a stage.started event
+func (e Event) AsStageStarted() (StageStartedEvent, bool) {
+	payload, ok := e.Payload.(StageStartedEvent)
+	return payload, ok
+}
+
+// AsStageCompleted returns the payload of a stage.completed event
+func (e Event) AsStageCompleted() (StageCompletedEvent, bool) {
+	payload, ok := e.Payload.(StageCompletedEvent)
+	return payload, ok
+}
+
+// AsStageError returns the payload of a stage.error event
+func (e Event) AsStageError() (StageErrorEvent, bool) {
+	payload, ok := e.Payload.(StageErrorEvent)
+	return payload, ok
+}
+
+// AsStageOutput returns the payload of a stage.output event
+func (e Event) AsStageOutput() (StageOutputEvent, bool) {
+	payload, ok := e.Payload.(StageOutputEvent)
+	return payload, ok
+}
+
+// AsStageLog returns the payload of a stage.log event
+func (e Event) AsStageLog() (StageLogEvent, bool) {
+	payload, ok := e.Payload.(StageLogEvent)
+	return payload, ok
+}
+
+// StageID returns the stage of a stage event ("" for pipeline events)
+func (e Event) StageID() string {
+	switch payload := e.Payload.(type) {
+	case StageStartedEvent:
+		return payload.StageID
+	case StageCompletedEvent:
+		return payload.StageID
+	case StageErrorEvent:
+		return payload.StageID
+	case StageOutputEvent:
+		return payload.StageID
+	case StageLogEvent:
+		return payload.StageID
+	}
+	return ""
+}
+
+// eventJSON is the serialized form of an event
+type eventJSON struct {
+	SchemaVersion int             `json:"schema_version"`
+	Type          EventType       `json:"type"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// MarshalJSON encodes the event as {"schema_version", "type", "timestamp", "data"}
+func (e Event) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", e.Type, err)
+	}
+	return json.Marshal(eventJSON{
+		SchemaVersion: EventSchemaVersion,
+		Type:          e.Type,
+		Timestamp:     e.Timestamp,
+		Data:          data,
+	})
+}
+
+// UnmarshalJSON decodes an event encoded by MarshalJSON into its typed payload
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw eventJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if raw.SchemaVersion > EventSchemaVersion {
+		return fmt.Errorf("unsupported event schema version %d", raw.SchemaVersion)
+	}
+
+	var payload EventPayload
+	var err error
+	switch raw.Type {
+	case EventPipelineStarted:
+		payload, err = decodePayload[PipelineStartedEvent](raw.Data)
+	case EventPipelineCompleted:
+		payload, err = decodePayload[PipelineCompletedEvent](raw.Data)
+	case EventPipelineError:
+		payload, err = decodePayload[PipelineErrorEvent](raw.Data)
+	case EventStageStarted:
+		payload, err = decodePayload[StageStartedEvent](raw.Data)
+	case EventStageCompleted:
+		payload, err = decodePayload[StageCompletedEvent](raw.Data)
+	case EventStageError:
+		payload, err = decodePayload[StageErrorEvent](raw.Data)
+	case EventStageOutput:
+		payload, err = decodePayload[StageOutputEvent](raw.Data)
+	case EventStageLog:
+		payload, err = decodePayload[StageLogEvent](raw.Data)
+	default:
+		return fmt.Errorf("unknown event type: %s", raw.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %s event: %w", raw.Type, err)
+	}
+
+	*e = Event{Type: raw.Type, Timestamp: raw.Timestamp, Payload: payload}
+	return nil
+}
+
+func decodePayload[T EventPayload](data json.RawMessage) (EventPayload, error) {
+	var payload T
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
 // EventListener è l'interfaccia che deve essere implementata per ricevere eventi dalla pipeline
 type EventListener interface {
 	OnEvent(event Event)
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_JSONRoundTrip(t *testing.T) {
+	timestamp := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Type: EventPipelineStarted, Timestamp: timestamp, Payload: PipelineStartedEvent{Mode: "batch"}},
+		{Type: EventStageCompleted, Timestamp: timestamp, Payload: StageCompletedEvent{
+			StageID: "fetch", StepID: "http_client", EventID: "evt_1", Attempt: 1, Duration: time.Second,
+		}},
+		{Type: EventStageError, Timestamp: timestamp, Payload: StageErrorEvent{
+			StageID: "fetch", EventID: "evt_1", Attempt: 2, Error: "boom", StatusCode: 503, Err: errors.New("boom"),
+		}},
+		{Type: EventStageOutput, Timestamp: timestamp, Payload: StageOutputEvent{
+			StageID: "fetch", EventID: "evt_1", Output: CreateDefaultResultData("ok"),
+		}},
+		{Type: EventStageLog, Timestamp: timestamp, Payload: StageLogEvent{
+			StageID: "transform", StepID: "js", EventID: "evt_1", Level: LogLevelWarn, Message: "slow",
+		}},
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("Marshal %s failed: %v", event.Type, err)
+		}
+		if !strings.Contains(string(data), `"schema_version":1`) {
+			t.Errorf("Missing schema version in %s", data)
+		}
+
+		var decoded Event
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal %s failed: %v", event.Type, err)
+		}
+		if decoded.Type != event.Type || !decoded.Timestamp.Equal(event.Timestamp) {
+			t.Errorf("Decoded %s header mismatch: %+v", event.Type, decoded)
+		}
+		if decoded.Payload.EventType() != event.Type {
+			t.Errorf("Decoded payload %T for %s", decoded.Payload, event.Type)
+		}
+	}
+}
+
+func TestEvent_StableJSON(t *testing.T) {
+	event := Event{
+		Type:      EventStageOutput,
+		Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Payload: StageOutputEvent{
+			StageID: "fetch",
+			StepID:  "http_client",
+			EventID: "evt_1",
+			Attempt: 1,
+			Output:  CreateDefaultResultData("ok"),
+		},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	expected := `{"schema_version":1,"type":"stage.output","timestamp":"2024-05-01T10:00:00Z","data":{"stage_id":"fetch","step_id":"http_client","event_id":"evt_1","attempt":1,"output":{"default":{"value":"ok"}},"timestamp":"0001-01-01T00:00:00Z"}}`
+	if string(data) != expected {
+		t.Errorf("Unexpected JSON:\n%s\nexpected:\n%s", data, expected)
+	}
+}
+
+func TestEvent_Accessors(t *testing.T) {
+	event := NewEvent(StageErrorEvent{StageID: "fetch", Error: "boom"})
+
+	if event.Type != EventStageError {
+		t.Errorf("Expected type %s, got %s", EventStageError, event.Type)
+	}
+	if payload, ok := event.AsStageError(); !ok || payload.Error != "boom" {
+		t.Errorf("AsStageError returned %+v, %v", payload, ok)
+	}
+	if _, ok := event.AsStageOutput(); ok {
+		t.Error("AsStageOutput should fail on a stage.error event")
+	}
+	if event.StageID() != "fetch" {
+		t.Errorf("Expected stage 'fetch', got '%s'", event.StageID())
+	}
+
+	var decoded Event
+	if err := json.Unmarshal([]byte(`{"schema_version":99,"type":"stage.error","data":{}}`), &decoded); err == nil {
+		t.Error("Expected error for a newer schema version")
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// StateStore keeps values across the events of a pipeline (the $state of js steps)
+// Values are grouped by scope (the stage ID); implementations must be safe for concurrent use
+type StateStore interface {
+	// Get returns the value of a key, false when it is missing or expired
+	Get(scope, key string) (any, bool, error)
+	// Set stores a value; it expires after ttl (0 = never)
+	Set(scope, key string, value any, ttl time.Duration) error
+	Delete(scope, key string) error
+	// Flush persists the values (called when a pipeline run ends)
+	Flush() error
+}
+
+// StageState is the state of one stage in a StateStore
+type StageState struct {
+	Store StateStore
+	Scope string // Stage ID
+}
+
+// Get returns the value of a key of the stage
+func (s *StageState) Get(key string) (any, bool, error) {
+	return s.Store.Get(s.Scope, key)
+}
+
+// Set stores a value for the stage; it expires after ttl (0 = never)
+func (s *StageState) Set(key string, value any, ttl time.Duration) error {
+	return s.Store.Set(s.Scope, key, value, ttl)
+}
+
+// Delete removes a key of the stage
+func (s *StageState) Delete(key string) error {
+	return s.Store.Delete(s.Scope, key)
+}
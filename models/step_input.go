@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -10,8 +11,12 @@ type StepInput struct {
 	Data            map[string]map[string]*Data // Data from dependencies
 	EventID         string                      // Unique event ID (propagated through pipeline)
 	Timestamp       time.Time                   // Event timestamp
+	Attempt         int                         // Delivery attempt of the event to the stage (0 or 1 = first, >1 = replay)
 	GlobalVariables map[string]any              // Global pipeline variables
 	GlobalSecrets   map[string]any              // Global pipeline secrets
+	Log             LogFunc                     // Writes stage.log events for the stage (nil outside a pipeline)
+	State           *StageState                 // State of the stage kept across events (nil outside a pipeline)
+	Ctx             context.Context             // Context of the stage run, done when the pipeline stops (nil outside a pipeline)
 	mu              sync.RWMutex                // Mutex for concurrency
 }
 
@@ -22,3 +27,31 @@ func (si *StepInput) Lock() {
 func (si *StepInput) Unlock() {
 	si.mu.Unlock()
 }
+
+// Context returns the context of the stage run, or context.Background() outside a pipeline
+func (si *StepInput) Context() context.Context {
+	if si == nil || si.Ctx == nil {
+		return context.Background()
+	}
+	return si.Ctx
+}
+
+// LogLevel is the severity of a line written by a step
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+// LogFunc writes a log line of the step processing an input
+type LogFunc func(level LogLevel, message string)
+
+// WriteLog writes a log line through Log; it does nothing when the input has no Log
+func (si *StepInput) WriteLog(level LogLevel, message string) {
+	if si.Log != nil {
+		si.Log(level, message)
+	}
+}
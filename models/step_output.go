@@ -7,4 +7,5 @@ type StepOutput struct {
 	Data      map[string]*Data // Risultato dello step
 	EventID   string           // Stesso EventID dell'input (per tracciamento)
 	Timestamp time.Time        // Timestamp dell'output
+	Metadata  map[string]any   // Opzionale: informazioni sull'esecuzione (es. "http"), inoltrate negli eventi stage.output
 }
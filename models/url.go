@@ -0,0 +1,15 @@
+package models
+
+import "net/url"
+
+// RedactedURL is the URL reported in events and errors: the query string, the fragment and
+// the credentials are dropped, since they often carry API keys (events are not redacted)
+func RedactedURL(u *url.URL) string {
+	safe := *u
+	safe.User = nil
+	safe.RawQuery = ""
+	safe.ForceQuery = false
+	safe.Fragment = ""
+	safe.RawFragment = ""
+	return safe.String()
+}
@@ -3,11 +3,13 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/config"
 	"github.com/simon020286/go-pipeline/models"
 	_ "github.com/simon020286/go-pipeline/steps"
 )
@@ -22,6 +24,14 @@ const (
 	ExecutionModeStreaming
 )
 
+// String returns "batch" or "streaming"
+func (m ExecutionMode) String() string {
+	if m == ExecutionModeStreaming {
+		return "streaming"
+	}
+	return "batch"
+}
+
 // StageDependency represents a dependency with an optional branch filter
 type StageDependency struct {
 	Stage  *Stage // Reference to the dependency stage
@@ -33,6 +43,7 @@ type StageDependency struct {
 type Stage struct {
 	ID             string            // Unique identifier of the stage
 	Step           models.Step       // The step to execute
+	StepType       string            // Optional: registered step type name (used in events)
 	dependencyRefs []StageDependency // References to dependency stages with optional branch filters
 }
 
@@ -63,10 +74,28 @@ type Pipeline struct {
 
 	// Event handling (private)
 	eventBus *eventBus
+	outputs  *outputHub // Channel and iterator subscriptions to stage outputs
 
 	// Global configuration
 	globalVariables map[string]any // Global variables accessible to all stages
 	globalSecrets   map[string]any // Global secrets accessible to all stages
+
+	// Pipeline as a function (see Invoke)
+	inputDefs  map[string]config.ParameterDef  // Declared inputs
+	outputDefs map[string]config.DependencyRef // Output name -> stage and optional port
+	runInputs  map[string]any                  // Inputs of the current run, exposed to entry stages
+
+	// Dead-letter handling
+	deadLetterSink  DeadLetterSink // Optional: receives events whose stage failed
+	deadLetterStage string         // Optional: stage that receives events whose stage failed
+
+	// Values kept across events ($state), flushed when a run ends
+	stateStore models.StateStore
+
+	// Runtime state of the current execution
+	runMutex      sync.RWMutex
+	replayTargets map[string]*inputTracker          // Map ID -> replay entry point (nil when not running)
+	connections   map[string]chan models.StepOutput // Map "producerID->consumerID" -> channel (nil when not running)
 }
 
 // StageBuilder allows configuring a stage with fluent API
@@ -131,12 +160,16 @@ func NewPipeline() *Pipeline {
 		dependents: make(map[string][]string),
 		done:       make(chan struct{}),
 		eventBus:   newEventBus(),
+		outputs:    newOutputHub(),
+		stateStore: NewMemoryStateStore(),
 	}
 }
 
 // AddListener adds a listener to receive events from the pipeline
-func (p *Pipeline) AddListener(listener models.EventListener) {
-	p.eventBus.addListener(listener)
+// Events are delivered in order through a queue of DefaultListenerQueueSize events;
+// when the queue is full the pipeline waits for the listener
+func (p *Pipeline) AddListener(listener models.EventListener) ListenerID {
+	return p.eventBus.addListener(listener, ListenerOptions{})
 }
 
 // SetGlobalVariables sets the global variables accessible to all stages
@@ -149,12 +182,34 @@ func (p *Pipeline) SetGlobalSecrets(secrets map[string]any) {
 	p.globalSecrets = secrets
 }
 
+// GlobalSecrets returns the resolved global secrets (e.g. to redact them from logs)
+func (p *Pipeline) GlobalSecrets() map[string]any {
+	return p.globalSecrets
+}
+
 // Start avvia la pipeline in background (non bloccante)
+// Gli input dichiarati assumono il loro valore di default (vedi Invoke): fallisce se un input è $required
 func (p *Pipeline) Start(parentCtx context.Context) error {
+	var inputs map[string]any
+	if len(p.inputDefs) > 0 {
+		resolved, err := config.ResolveInputs(p.inputDefs, nil)
+		if err != nil {
+			return fmt.Errorf("cannot start the pipeline: %w (use Invoke to pass the inputs)", err)
+		}
+		inputs = resolved
+	}
+	return p.start(parentCtx, inputs, nil)
+}
+
+// start avvia la pipeline con gli input già validati
+// sub (opzionale) è registrata solo se l'avvio riesce, prima che gli stage producano output
+func (p *Pipeline) start(parentCtx context.Context, inputs map[string]any, sub *outputSubscription) error {
 	if !p.running.CompareAndSwap(false, true) {
 		return fmt.Errorf("pipeline already running")
 	}
 
+	p.runInputs = inputs
+
 	// Valida prima di avviare
 	if err := p.Validate(); err != nil {
 		p.running.Store(false)
@@ -170,17 +225,20 @@ func (p *Pipeline) Start(parentCtx context.Context) error {
 	// Ricrea done channel
 	p.done = make(chan struct{})
 
-	// Emetti evento di avvio
-	modeStr := "batch"
-	if p.mode == ExecutionModeStreaming {
-		modeStr = "streaming"
+	if sub != nil {
+		p.outputs.add(sub)
 	}
-	p.eventBus.EmitPipelineStarted(modeStr)
+
+	// Emetti evento di avvio
+	p.eventBus.EmitPipelineStarted(p.mode.String())
 
 	// Avvia esecuzione in background
+	// Start ritorna quando il run accetta i replay, così Replay subito dopo Start non fallisce
 	startTime := time.Now()
+	ready := make(chan struct{})
 	go func() {
 		defer func() {
+			p.flushState()
 			p.running.Store(false)
 			duration := time.Since(startTime)
 			p.eventBus.EmitPipelineCompleted(duration)
@@ -191,9 +249,10 @@ func (p *Pipeline) Start(parentCtx context.Context) error {
 			close(p.done)
 		}()
 
-		p.execute(p.ctx)
+		p.execute(p.ctx, ready)
 	}()
 
+	<-ready
 	return nil
 }
 
@@ -239,14 +298,20 @@ func (p *Pipeline) Execute(ctx context.Context) error {
 	return nil
 }
 
+// Mode returns the execution mode the pipeline runs in: streaming when an entry stage
+// is continuous (e.g. cron or a continuous webhook), batch otherwise
+func (p *Pipeline) Mode() ExecutionMode {
+	return p.detectExecutionMode()
+}
+
 // detectExecutionMode determina se la pipeline è batch o streaming
 // in base agli entry points (stage senza dipendenze)
 func (p *Pipeline) detectExecutionMode() ExecutionMode {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
 
-	for _, stage := range p.stages {
-		if len(stage.dependencyRefs) == 0 {
+	for id, stage := range p.stages {
+		if len(stage.dependencyRefs) == 0 && id != p.deadLetterStage {
 			if stage.Step.IsContinuous() {
 				return ExecutionModeStreaming
 			}
@@ -303,6 +368,17 @@ func (p *Pipeline) Validate() error {
 		}
 	}
 
+	// Verifica lo stage dead-letter
+	if p.deadLetterStage != "" {
+		stage, exists := p.stages[p.deadLetterStage]
+		if !exists {
+			return fmt.Errorf("dead-letter stage '%s' not found in pipeline", p.deadLetterStage)
+		}
+		if len(stage.dependencyRefs) > 0 {
+			return fmt.Errorf("dead-letter stage '%s' cannot have dependencies", p.deadLetterStage)
+		}
+	}
+
 	// Verifica cicli (DFS)
 	visited := make(map[string]bool)
 	recStack := make(map[string]bool)
@@ -339,10 +415,14 @@ func (p *Pipeline) Validate() error {
 }
 
 // execute è la logica interna di esecuzione
-func (p *Pipeline) execute(ctx context.Context) {
+// ready è chiuso quando i punti di ingresso del replay sono pronti
+func (p *Pipeline) execute(ctx context.Context, ready chan<- struct{}) {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
 
+	p.outputs.start()
+	defer p.outputs.finish()
+
 	// Per ogni stage, creo un channel dedicato per ogni consumer
 	// Key: "producerID->consumerID"
 	stageConnections := make(map[string]chan models.StepOutput)
@@ -355,14 +435,55 @@ func (p *Pipeline) execute(ctx context.Context) {
 		}
 	}
 
-	var wg sync.WaitGroup
+	// Lo stage dead-letter e i suoi consumer terminano dopo tutti gli altri stage
+	deadLetterStages := p.deadLetterSubgraph()
+	var deadLetterInputs chan *models.StepInput
+	if p.deadLetterStage != "" {
+		deadLetterInputs = make(chan *models.StepInput, 10)
+	}
+
+	// Crea i tracker degli input (punto di ingresso del replay) prima di avviare gli stage
+	trackers := make(map[string]*inputTracker, len(p.stages))
+	activities := make(map[string]*stageActivity, len(p.stages))
+	for id, stage := range p.stages {
+		var source <-chan *models.StepInput
+		if id == p.deadLetterStage {
+			source = deadLetterInputs
+		} else {
+			source = p.createInputChannelV2(ctx, id, stageConnections)
+		}
+
+		// L'input iniziale di uno stage continuo non è un evento da tracciare
+		skipFirst := len(stage.dependencyRefs) == 0 && stage.Step.IsContinuous() && id != p.deadLetterStage
+		activities[id] = newStageActivity(ctx, p, stage, skipFirst)
+
+		trackers[id] = newInputTracker(ctx, source, activities[id])
+	}
+
+	p.runMutex.Lock()
+	p.replayTargets = trackers
+	p.connections = stageConnections
+	p.runMutex.Unlock()
+	close(ready)
+	defer func() {
+		p.runMutex.Lock()
+		p.replayTargets = nil
+		p.connections = nil
+		p.runMutex.Unlock()
+	}()
+
+	var wg, deadLetterWg sync.WaitGroup
 
 	// Avvia tutti gli stage
 	for id, stage := range p.stages {
-		wg.Add(1)
+		stageWg := &wg
+		if deadLetterStages[id] {
+			stageWg = &deadLetterWg
+		}
+		stageWg.Add(1)
 
 		go func(stageID string, stg *Stage) {
-			defer wg.Done()
+			defer stageWg.Done()
 
 			// Chiudi i channel di output verso i consumer al termine
 			defer func() {
@@ -374,14 +495,15 @@ func (p *Pipeline) execute(ctx context.Context) {
 				}
 			}()
 
-			// Crea channel di input da dipendenze
-			inputChan := p.createInputChannelV2(ctx, stageID, stageConnections)
+			tracker := trackers[stageID]
+			activity := activities[stageID]
+			defer activity.finish()
 
 			// Ottieni il nome dello step type (se disponibile)
-			stepID := fmt.Sprintf("%T", stg.Step)
+			stepID := stageStepID(stg)
 
 			// Esegui step
-			outputChan, errorChan := stg.Step.Run(ctx, inputChan)
+			outputChan, errorChan := stg.Step.Run(ctx, tracker.out)
 
 			// Forward outputs a TUTTI i consumer
 			var forwardWg sync.WaitGroup
@@ -392,7 +514,12 @@ func (p *Pipeline) execute(ctx context.Context) {
 				defer forwardWg.Done()
 				for out := range outputChan {
 					// Emetti evento di output
-					p.eventBus.EmitStageOutput(stageID, stepID, out.EventID, out.Data, nil)
+					attempt := activity.beforeOutput(out.EventID)
+					p.eventBus.EmitStageOutput(stageID, stepID, attempt, out)
+					activity.output(out.EventID)
+
+					// Consegna ai subscriber (bloccante: backpressure)
+					p.outputs.publish(ctx, stageID, out)
 
 					// Trova tutti i consumer di questo stage
 					for consumerID := range p.stages {
@@ -412,19 +539,64 @@ func (p *Pipeline) execute(ctx context.Context) {
 			go func() {
 				defer forwardWg.Done()
 				for err := range errorChan {
+					// L'errore si riferisce all'ultimo input consegnato: per gli step che
+					// accumulano input (es. batch) può non essere l'evento che ha fallito
+					input := tracker.Current()
+					eventID := ""
+					if input != nil {
+						eventID = input.EventID
+					}
+
 					// Emetti evento di errore
-					p.eventBus.EmitStageError(stageID, stepID, "", err)
+					attempt, duration := activity.failed(eventID)
+					p.eventBus.EmitStageError(stageID, stepID, eventID, attempt, duration, err)
+
+					if input != nil && !deadLetterStages[stageID] {
+						p.deadLetter(ctx, stageID, stepID, input, err, deadLetterInputs)
+					}
 				}
 			}()
 
 			// Aspetta che entrambi i forward finiscano
 			forwardWg.Wait()
+			p.outputs.stageFinished(stageID)
 
+			// Lo step è terminato: scarta gli input residui per non bloccare i producer
+			// e rifiuta i replay, che lo step non riceverebbe più
+			tracker.stop()
 		}(id, stage)
 	}
 
 	// Aspetta completamento
 	wg.Wait()
+
+	// Nessun altro evento può fallire: chiudi l'input dello stage dead-letter
+	if deadLetterInputs != nil {
+		close(deadLetterInputs)
+	}
+	deadLetterWg.Wait()
+}
+
+// stageStepID returns the step identifier used in events: the step type if known, the Go type otherwise
+func stageStepID(stage *Stage) string {
+	if stage.StepType != "" {
+		return stage.StepType
+	}
+	return fmt.Sprintf("%T", stage.Step)
+}
+
+// QueueDepths returns the number of outputs waiting to be consumed by each stage
+// The map is empty when the pipeline is not running
+func (p *Pipeline) QueueDepths() map[string]int {
+	p.runMutex.RLock()
+	defer p.runMutex.RUnlock()
+
+	depths := make(map[string]int)
+	for key, ch := range p.connections {
+		consumerID := key[strings.LastIndex(key, "->")+2:]
+		depths[consumerID] += len(ch)
+	}
+	return depths
 }
 
 // createInputChannelV2 crea il channel di input usando le connessioni dedicate
@@ -435,11 +607,16 @@ func (p *Pipeline) createInputChannelV2(ctx context.Context, stageID string, con
 	go func() {
 		defer close(inputChan)
 
-		// Se non ha dipendenze, emetti un input iniziale
+		// Se non ha dipendenze, emetti un input iniziale (con gli input della pipeline)
 		if len(stage.dependencyRefs) == 0 {
+			data := make(map[string]map[string]*models.Data)
+			if p.runInputs != nil {
+				data[InputsKey] = models.CreateDefaultResultData(p.runInputs)
+			}
+
 			select {
 			case inputChan <- &models.StepInput{
-				Data:            make(map[string]map[string]*models.Data),
+				Data:            data,
 				EventID:         builder.GenerateEventID(),
 				Timestamp:       time.Now(),
 				GlobalVariables: p.globalVariables,
@@ -471,6 +648,9 @@ func (p *Pipeline) createInputChannelV2(ctx context.Context, stageID string, con
 					if !ok {
 						continue
 					}
+					// Il channel è aperto anche se l'output viene scartato dal filtro:
+					// un output di un altro branch non deve chiudere l'input dello stage
+					allClosed = false
 					// Se c'è un filtro branch, verifica che l'output corrisponda
 					if dep.Branch != "" {
 						// L'output deve contenere una chiave che corrisponde al branch richiesto
@@ -479,7 +659,6 @@ func (p *Pipeline) createInputChannelV2(ctx context.Context, stageID string, con
 							continue
 						}
 					}
-					allClosed = false
 					data[dep.Stage.ID] = out.Data
 					if eventID == "" {
 						eventID = out.EventID
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// burstStep behaves like a webhook receiving count requests
+type burstStep struct {
+	count int
+}
+
+func (s *burstStep) IsContinuous() bool {
+	return true
+}
+
+func (s *burstStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		<-inputs
+		for i := 0; i < s.count; i++ {
+			select {
+			case outputChan <- models.StepOutput{
+				Data: models.CreateDefaultResultData(map[string]any{
+					"body": map[string]any{"order_id": i, "amount": 12.5},
+				}),
+				EventID:   fmt.Sprintf("evt_%d", i),
+				Timestamp: time.Now(),
+			}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+// BenchmarkPipeline_WebhookJS measures the events per second of a webhook pipeline
+// transforming every request with a js step and a map step with $js: fields
+func BenchmarkPipeline_WebhookJS(b *testing.B) {
+	transform, err := builder.CreateStep("js", map[string]any{
+		"code": "return { id: ctx.webhook.body.order_id, total: ctx.webhook.body.amount * 2 };",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	fields, err := builder.CreateStep("map", map[string]any{
+		"fields": []any{
+			map[string]any{"name": "id", "value": "$js: ctx.transform.id"},
+			map[string]any{"name": "label", "value": "order ${{ ctx.transform.id }}: ${{ ctx.transform.total }}"},
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	p := NewPipeline()
+	webhook := NewStage("webhook", &burstStep{count: b.N})
+	transformStage := NewStage("transform", transform)
+	p.AddStage(webhook)
+	if err := p.AddStage(transformStage).After(webhook); err != nil {
+		b.Fatal(err)
+	}
+	if err := p.AddStage(NewStage("fields", fields)).After(transformStage); err != nil {
+		b.Fatal(err)
+	}
+
+	outputs := p.Subscribe("fields", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	if err := p.Start(ctx); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		<-outputs
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "events/s")
+	cancel()
+	p.Wait()
+}
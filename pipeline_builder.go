@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/simon020286/go-pipeline/builder"
 	"github.com/simon020286/go-pipeline/config"
@@ -9,7 +10,13 @@ import (
 )
 
 // BuildFromConfig builds a pipeline from a configuration
+// The configuration is checked first with config.ValidatePipeline: all its problems are
+// returned together as config.Diagnostics
 func BuildFromConfig(cfg *config.PipelineConfig) (*Pipeline, error) {
+	if err := config.ValidatePipeline(cfg).Err(); err != nil {
+		return nil, err
+	}
+
 	pipeline := NewPipeline()
 
 	// Process global variables
@@ -30,19 +37,43 @@ func BuildFromConfig(cfg *config.PipelineConfig) (*Pipeline, error) {
 		pipeline.SetGlobalSecrets(resolvedSecrets)
 	}
 
+	// HTTP client shared by the steps (proxy, TLS and timeout)
+	var httpClient *http.Client
+	if cfg.HTTP != nil {
+		client, err := builder.NewHTTPClient(cfg.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http configuration: %w", err)
+		}
+		httpClient = client
+	}
+
+	// Instantiate stage templates (already done by config.LoadPipeline)
+	stages, err := cfg.ExpandStages()
+	if err != nil {
+		return nil, err
+	}
+
 	// Temporary map to resolve dependencies
 	stageMap := make(map[string]*Stage)
 
 	// Phase 1: Create all stages without dependencies
-	for _, stageConfig := range cfg.Stages {
+	for _, stageConfig := range stages {
 		// Create the step using the factory
-		step, err := builder.CreateStep(stageConfig.StepType, stageConfig.StepConfig)
+		stepConfig := stageConfig.StepConfig
+		if stageConfig.BaseDir != "" {
+			stepConfig = builder.WithBaseDir(stepConfig, stageConfig.BaseDir)
+		}
+		if httpClient != nil {
+			stepConfig = builder.WithHTTPClient(stepConfig, httpClient)
+		}
+		step, err := builder.CreateStep(stageConfig.StepType, stepConfig)
 		if err != nil {
 			return nil, err
 		}
 
 		// Create the stage (without dependencies)
 		stage := NewStage(stageConfig.ID, step)
+		stage.StepType = stageConfig.StepType
 		stageMap[stageConfig.ID] = stage
 
 		// Add the stage to the pipeline
@@ -50,7 +81,7 @@ func BuildFromConfig(cfg *config.PipelineConfig) (*Pipeline, error) {
 	}
 
 	// Phase 2: Resolve dependencies from IDs to *Stage references
-	for _, stageConfig := range cfg.Stages {
+	for _, stageConfig := range stages {
 		// Support both Dependencies and Inputs (legacy)
 		dependencies := stageConfig.Dependencies
 		if len(dependencies) == 0 {
@@ -88,9 +119,80 @@ func BuildFromConfig(cfg *config.PipelineConfig) (*Pipeline, error) {
 		}
 	}
 
+	// Declared inputs and outputs (see Pipeline.Invoke)
+	if cfg.Inputs != nil {
+		pipeline.SetInputs(cfg.Inputs)
+	}
+	if cfg.Outputs != nil {
+		if err := pipeline.SetOutputs(cfg.Outputs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Configure the dead-letter sink
+	if cfg.DeadLetter != nil {
+		if err := configureDeadLetter(pipeline, cfg.DeadLetter); err != nil {
+			return nil, err
+		}
+	}
+
+	// Configure the state store
+	if cfg.State != nil {
+		if err := configureState(pipeline, cfg.State); err != nil {
+			return nil, err
+		}
+	}
+
 	return pipeline, nil
 }
 
+// configureState applies the state configuration to the pipeline
+func configureState(pipeline *Pipeline, cfg *config.StateConfig) error {
+	switch cfg.Type {
+	case "", "memory":
+		pipeline.SetStateStore(NewMemoryStateStore())
+	case "file":
+		if cfg.Path == "" {
+			return fmt.Errorf("state of type 'file' requires 'path'")
+		}
+		store, err := NewFileStateStore(cfg.Path)
+		if err != nil {
+			return err
+		}
+		pipeline.SetStateStore(store)
+	default:
+		return fmt.Errorf("unsupported state type '%s' (expected memory or file)", cfg.Type)
+	}
+	return nil
+}
+
+// configureDeadLetter applies the dead-letter configuration to the pipeline
+func configureDeadLetter(pipeline *Pipeline, cfg *config.DeadLetterConfig) error {
+	switch cfg.Type {
+	case "file":
+		if cfg.Path == "" {
+			return fmt.Errorf("dead_letter of type 'file' requires 'path'")
+		}
+		pipeline.SetDeadLetterSink(NewJSONLDeadLetterSink(cfg.Path))
+	case "directory":
+		if cfg.Path == "" {
+			return fmt.Errorf("dead_letter of type 'directory' requires 'path'")
+		}
+		pipeline.SetDeadLetterSink(NewDirectoryDeadLetterSink(cfg.Path))
+	case "stage":
+		if cfg.Stage == "" {
+			return fmt.Errorf("dead_letter of type 'stage' requires 'stage'")
+		}
+		if _, exists := pipeline.GetStage(cfg.Stage); !exists {
+			return fmt.Errorf("dead-letter stage '%s' not found in pipeline", cfg.Stage)
+		}
+		pipeline.SetDeadLetterStage(cfg.Stage)
+	default:
+		return fmt.Errorf("unsupported dead_letter type '%s' (expected file, directory or stage)", cfg.Type)
+	}
+	return nil
+}
+
 // resolveGlobalConfig resolves global configuration values (variables or secrets)
 // Supports $env: references to environment variables
 func resolveGlobalConfig(configMap map[string]interface{}) (map[string]any, error) {
@@ -0,0 +1,77 @@
+package pipeline
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/simon020286/go-pipeline/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildFromConfig_Diagnostics(t *testing.T) {
+	var cfg config.PipelineConfig
+	err := yaml.Unmarshal([]byte(`
+stages:
+  - id: check
+    step_type: if
+    step_config:
+      condition: true
+  - id: log
+    step_type: js
+    step_config:
+      cod: return 1
+    dependencies: [check:maybe]
+  - id: wait
+    step_type: delay
+    step_config:
+      ms: $var:pause
+`), &cfg)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	_, err = BuildFromConfig(&cfg)
+	var diagnostics config.Diagnostics
+	if !errors.As(err, &diagnostics) {
+		t.Fatalf("Expected config.Diagnostics, got %v", err)
+	}
+
+	expected := []string{
+		"line 9, column 5: stage 'log': missing required key 'code' in step_config",
+		"line 10, column 7: stage 'log': unknown key 'cod' for step type 'js' (did you mean 'code'?)",
+		"line 11, column 20: stage 'log': depends on branch 'maybe' of stage 'check', but step type 'if' only emits true, false",
+		"line 15, column 11: stage 'wait': undefined variable 'pause'",
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for i, want := range expected {
+		if got := diagnostics[i].String(); got != want {
+			t.Errorf("Expected %q, got %q", want, got)
+		}
+	}
+}
+
+func TestValidatePipeline_Examples(t *testing.T) {
+	// Esempi nel vecchio formato (step: {type, config})
+	legacy := map[string]bool{"cron_pipeline.yaml": true}
+
+	files, err := filepath.Glob("examples/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("No examples found: %v", err)
+	}
+	for _, file := range files {
+		if legacy[filepath.Base(file)] {
+			continue
+		}
+		cfg, err := config.LoadPipeline(file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if err := config.ValidatePipeline(cfg).Err(); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
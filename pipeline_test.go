@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@ type mockStep struct {
 	delay      time.Duration
 	output     any
 	shouldFail bool
+	log        string // Written with input.WriteLog for every input
 }
 
 func (m *mockStep) IsContinuous() bool {
@@ -33,6 +35,9 @@ func (m *mockStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-
 			if m.delay > 0 {
 				time.Sleep(m.delay)
 			}
+			if m.log != "" {
+				input.WriteLog(models.LogLevelWarn, m.log)
+			}
 
 			if m.shouldFail {
 				errorChan <- fmt.Errorf("mock step failed")
@@ -262,8 +267,8 @@ func TestPipeline_AddListener(t *testing.T) {
 	p.AddListener(listener)
 
 	// Emit a test event
-	p.eventBus.Emit(models.EventStageStarted, map[string]any{
-		"stage_id": "test",
+	p.eventBus.Emit(models.StageStartedEvent{
+		StageID: "test",
 	})
 
 	// Wait for event or timeout
@@ -274,3 +279,134 @@ func TestPipeline_AddListener(t *testing.T) {
 		t.Error("Event listener did not receive event")
 	}
 }
+
+func TestPipeline_StageLifecycleEvents(t *testing.T) {
+	p := NewPipeline()
+	source := NewStage("source", &mockStep{output: "data"})
+	p.AddStage(source)
+	if err := p.AddStage(NewStage("sink", &mockStep{output: "done"})).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+
+	var mutex sync.Mutex
+	counts := make(map[string]map[models.EventType]int)
+	p.AddListener(models.EventListenerFunc(func(event models.Event) {
+		stageID := event.StageID()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if counts[stageID] == nil {
+			counts[stageID] = make(map[models.EventType]int)
+		}
+		counts[stageID][event.Type]++
+	}))
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	p.eventBus.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, stageID := range []string{"source", "sink"} {
+		if got := counts[stageID][models.EventStageStarted]; got != 1 {
+			t.Errorf("Stage '%s': expected 1 started event, got %d", stageID, got)
+		}
+		if got := counts[stageID][models.EventStageCompleted]; got != 1 {
+			t.Errorf("Stage '%s': expected 1 completed event, got %d", stageID, got)
+		}
+	}
+}
+
+func TestPipeline_StageLogEvents(t *testing.T) {
+	p := NewPipeline()
+	p.AddStage(NewStage("source", &mockStep{output: "data", log: "careful"}))
+
+	var mutex sync.Mutex
+	var logs []models.StageLogEvent
+	var startedEventID string
+	p.AddListener(models.EventListenerFunc(func(event models.Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if payload, ok := event.AsStageLog(); ok {
+			logs = append(logs, payload)
+		}
+		if payload, ok := event.AsStageStarted(); ok {
+			startedEventID = payload.EventID
+		}
+	}))
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	p.eventBus.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 stage.log event, got %d", len(logs))
+	}
+	log := logs[0]
+	if log.StageID != "source" || log.Level != models.LogLevelWarn || log.Message != "careful" {
+		t.Errorf("Unexpected stage.log payload: %+v", log)
+	}
+	if log.EventID == "" || log.EventID != startedEventID {
+		t.Errorf("Expected event ID %q, got %q", startedEventID, log.EventID)
+	}
+}
+
+// collectStep records the value of a dependency port for every input
+type collectStep struct {
+	stageID string
+	port    string
+	mutex   sync.Mutex
+	values  []any
+}
+
+func (s *collectStep) IsContinuous() bool {
+	return true
+}
+
+func (s *collectStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput)
+	errorChan := make(chan error, 1)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		for input := range inputs {
+			s.mutex.Lock()
+			s.values = append(s.values, input.Data[s.stageID][s.port].Value)
+			s.mutex.Unlock()
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+func TestPipeline_BranchFilterKeepsInputOpen(t *testing.T) {
+	// Un output scartato dal filtro branch non deve chiudere l'input dello stage:
+	// gli output successivi del branch vanno ancora consegnati
+	p := NewPipeline()
+	source := NewStage("source", &portsStep{count: 5})
+	p.AddStage(source)
+	sink := &collectStep{stageID: "source", port: "odd"}
+	if err := p.AddStage(NewStage("sink", sink)).AfterWithBranch(source, "odd"); err != nil {
+		t.Fatalf("AfterWithBranch failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Execute(ctx); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("Execute did not return before the timeout")
+	}
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if len(sink.values) != 2 || sink.values[0] != 1 || sink.values[1] != 3 {
+		t.Errorf("Expected the odd outputs [1 3], got %v", sink.values)
+	}
+}
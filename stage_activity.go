@@ -0,0 +1,177 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// stageActivity tracks the events a stage is processing and emits stage.started
+// and stage.completed events
+// An event is completed when the stage produces an output with its event ID, when the
+// stage takes the next input (steps process inputs sequentially) or when the stage ends
+type stageActivity struct {
+	ctx       context.Context // Context of the run, bound to the inputs
+	pipeline  *Pipeline
+	stageID   string
+	stepID    string
+	state     *models.StageState // $state of the stage
+	skipFirst bool               // The first input is the trigger of a continuous entry stage, not an event
+
+	mutex          sync.Mutex
+	queued         map[string]activityEntry // Inputs offered to the step
+	pending        map[string]activityEntry // Inputs taken by the step and not completed yet
+	early          map[string]bool          // Events started by an output before their delivery was confirmed
+	current        string                   // Event ID of the last delivered input
+	currentAttempt int
+}
+
+// activityEntry is an event being handed to or processed by the step
+type activityEntry struct {
+	since   time.Time
+	attempt int
+}
+
+func newStageActivity(ctx context.Context, p *Pipeline, stage *Stage, skipFirst bool) *stageActivity {
+	var state *models.StageState
+	if p.stateStore != nil {
+		state = &models.StageState{Store: p.stateStore, Scope: stage.ID}
+	}
+	return &stageActivity{
+		ctx:       ctx,
+		pipeline:  p,
+		stageID:   stage.ID,
+		stepID:    stageStepID(stage),
+		state:     state,
+		skipFirst: skipFirst,
+		queued:    make(map[string]activityEntry),
+		pending:   make(map[string]activityEntry),
+		early:     make(map[string]bool),
+	}
+}
+
+// inputAttempt returns the delivery attempt of an input (1 for the first delivery)
+func inputAttempt(input *models.StepInput) int {
+	if input.Attempt < 1 {
+		return 1
+	}
+	return input.Attempt
+}
+
+// offered records that an input is about to be handed to the step
+// It also binds the log (stage.log events), the state of the stage and the context of the run
+// to the input
+func (a *stageActivity) offered(input *models.StepInput) {
+	eventID := input.EventID
+	input.Log = func(level models.LogLevel, message string) {
+		a.pipeline.eventBus.EmitStageLog(a.stageID, a.stepID, eventID, level, message)
+	}
+	input.State = a.state
+	input.Ctx = a.ctx
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.skipFirst {
+		return
+	}
+	a.queued[input.EventID] = activityEntry{since: time.Now(), attempt: inputAttempt(input)}
+}
+
+// delivered records that the step took an input
+func (a *stageActivity) delivered(input *models.StepInput) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.skipFirst {
+		a.skipFirst = false
+		return
+	}
+
+	eventID := input.EventID
+	attempt := inputAttempt(input)
+	delete(a.queued, eventID)
+	if a.current != eventID {
+		a.completeLocked(a.current)
+	}
+	a.current = eventID
+	a.currentAttempt = attempt
+
+	if a.early[eventID] {
+		// The step answered before the delivery was confirmed
+		delete(a.early, eventID)
+		return
+	}
+
+	a.pending[eventID] = activityEntry{since: time.Now(), attempt: attempt}
+	a.pipeline.eventBus.EmitStageStarted(a.stageID, a.stepID, eventID, attempt)
+}
+
+// beforeOutput is called before an output of the stage is emitted and returns its attempt
+// 0 means the output does not answer an input (e.g. a trigger or a new event)
+// If the step answered before the delivery of the input was confirmed, the event starts here
+func (a *stageActivity) beforeOutput(eventID string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if entry, ok := a.queued[eventID]; ok {
+		delete(a.queued, eventID)
+		if a.current != eventID {
+			a.completeLocked(a.current)
+		}
+		a.current = eventID
+		a.currentAttempt = entry.attempt
+		a.early[eventID] = true
+		a.pending[eventID] = entry
+		a.pipeline.eventBus.EmitStageStarted(a.stageID, a.stepID, eventID, entry.attempt)
+		return entry.attempt
+	}
+	if entry, ok := a.pending[eventID]; ok {
+		return entry.attempt
+	}
+	if eventID != "" && eventID == a.current {
+		return a.currentAttempt
+	}
+	return 0
+}
+
+// output records that the stage produced an output for an event
+func (a *stageActivity) output(eventID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.completeLocked(eventID)
+}
+
+// failed records that the stage failed while processing an event
+// It returns the attempt and the time spent on the event
+func (a *stageActivity) failed(eventID string) (int, time.Duration) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if entry, ok := a.pending[eventID]; ok {
+		delete(a.pending, eventID)
+		return entry.attempt, time.Since(entry.since)
+	}
+	if eventID != "" && eventID == a.current {
+		return a.currentAttempt, 0
+	}
+	return 0, 0
+}
+
+// finish completes all the events still pending when the stage ends
+func (a *stageActivity) finish() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for eventID := range a.pending {
+		a.completeLocked(eventID)
+	}
+}
+
+func (a *stageActivity) completeLocked(eventID string) {
+	entry, ok := a.pending[eventID]
+	if !ok {
+		return
+	}
+	delete(a.pending, eventID)
+	a.pipeline.eventBus.EmitStageCompleted(a.stageID, a.stepID, eventID, entry.attempt, time.Since(entry.since))
+}
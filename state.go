@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// stateEntry is a stored value, kept as JSON so readers always get their own copy
+type stateEntry struct {
+	Value     json.RawMessage `json:"value"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+func (e stateEntry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// MemoryStateStore keeps stage state in memory: it survives across events and runs of the
+// same pipeline, not across processes
+type MemoryStateStore struct {
+	mutex  sync.Mutex
+	scopes map[string]map[string]stateEntry
+}
+
+// NewMemoryStateStore creates an empty in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{scopes: make(map[string]map[string]stateEntry)}
+}
+
+// Get returns a copy of the value of a key, false when it is missing or expired
+// The value is decoded from JSON: numbers are float64, objects map[string]any
+func (s *MemoryStateStore) Get(scope, key string) (any, bool, error) {
+	s.mutex.Lock()
+	entry, ok := s.scopes[scope][key]
+	if ok && entry.expired(time.Now()) {
+		delete(s.scopes[scope], key)
+		ok = false
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+
+	var value any
+	if err := json.Unmarshal(entry.Value, &value); err != nil {
+		return nil, false, fmt.Errorf("failed to decode state '%s': %w", key, err)
+	}
+	return value, true, nil
+}
+
+// Set stores a value; it must be serializable as JSON
+func (s *MemoryStateStore) Set(scope, key string, value any, ttl time.Duration) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("state '%s' is not serializable: %w", key, err)
+	}
+	entry := stateEntry{Value: encoded}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		entry.ExpiresAt = &expiresAt
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.scopes[scope] == nil {
+		s.scopes[scope] = make(map[string]stateEntry)
+	}
+	s.scopes[scope][key] = entry
+	return nil
+}
+
+// Delete removes a key
+func (s *MemoryStateStore) Delete(scope, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.scopes[scope], key)
+	return nil
+}
+
+// Flush drops expired values (nothing to persist)
+func (s *MemoryStateStore) Flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeExpired(time.Now())
+	return nil
+}
+
+func (s *MemoryStateStore) removeExpired(now time.Time) {
+	for scope, entries := range s.scopes {
+		for key, entry := range entries {
+			if entry.expired(now) {
+				delete(entries, key)
+			}
+		}
+		if len(entries) == 0 {
+			delete(s.scopes, scope)
+		}
+	}
+}
+
+// FileStateStore keeps stage state in memory and writes it to a JSON file on Flush,
+// so it survives restarts of the process
+type FileStateStore struct {
+	*MemoryStateStore
+	path       string
+	flushMutex sync.Mutex
+}
+
+// NewFileStateStore creates a store persisted to path, loading the values already there
+// A missing file is an empty state
+func NewFileStateStore(path string) (*FileStateStore, error) {
+	store := &FileStateStore{MemoryStateStore: NewMemoryStateStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.scopes); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %s: %w", path, err)
+	}
+	if store.scopes == nil {
+		store.scopes = make(map[string]map[string]stateEntry)
+	}
+	store.removeExpired(time.Now())
+	return store, nil
+}
+
+// Flush writes the values that have not expired to the file
+// The file is replaced atomically, so a crash during the write keeps the previous state
+func (s *FileStateStore) Flush() error {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.mutex.Lock()
+	s.removeExpired(time.Now())
+	data, err := json.MarshalIndent(s.scopes, "", "  ")
+	s.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
+
+// SetStateStore sets where the state of the stages ($state in js steps) is kept
+// The default is a MemoryStateStore; the store is flushed when a run ends (including Stop),
+// a nil store disables $state
+func (p *Pipeline) SetStateStore(store models.StateStore) {
+	p.stateStore = store
+}
+
+// StateStore returns the store of the stage state
+func (p *Pipeline) StateStore() models.StateStore {
+	return p.stateStore
+}
+
+// flushState persists the stage state at the end of a run
+func (p *Pipeline) flushState() {
+	if p.stateStore == nil {
+		return
+	}
+	if err := p.stateStore.Flush(); err != nil {
+		p.eventBus.EmitPipelineError(fmt.Errorf("failed to flush stage state: %w", err))
+	}
+}
@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/config"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func TestMemoryStateStore(t *testing.T) {
+	store := NewMemoryStateStore()
+
+	if _, ok, err := store.Get("a", "missing"); ok || err != nil {
+		t.Errorf("Expected a missing key, got ok=%v err=%v", ok, err)
+	}
+
+	if err := store.Set("a", "last", map[string]any{"id": 1}, 0); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	value, ok, err := store.Get("a", "last")
+	if !ok || err != nil {
+		t.Fatalf("Get failed: ok=%v err=%v", ok, err)
+	}
+	// Ogni lettura restituisce una copia
+	value.(map[string]any)["id"] = 2
+	value, _, _ = store.Get("a", "last")
+	if value.(map[string]any)["id"] != float64(1) {
+		t.Errorf("Stored value was modified through a read: %v", value)
+	}
+
+	// Gli stage non condividono le chiavi
+	if _, ok, _ := store.Get("b", "last"); ok {
+		t.Error("Expected keys to be scoped by stage")
+	}
+
+	if err := store.Delete("a", "last"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok, _ := store.Get("a", "last"); ok {
+		t.Error("Expected the key to be deleted")
+	}
+
+	if err := store.Set("a", "short", 1, 10*time.Millisecond); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok, _ := store.Get("a", "short"); ok {
+		t.Error("Expected the key to expire")
+	}
+
+	if err := store.Set("a", "invalid", make(chan int), 0); err == nil {
+		t.Error("Expected an error for a value that is not serializable")
+	}
+}
+
+func TestFileStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "pipeline.json")
+
+	store, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+	store.Set("poll", "etag", "abc", 0)
+	store.Set("poll", "expiring", true, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	reloaded, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+	if value, ok, _ := reloaded.Get("poll", "etag"); !ok || value != "abc" {
+		t.Errorf("Expected etag 'abc' after reload, got %v (ok=%v)", value, ok)
+	}
+	if _, ok, _ := reloaded.Get("poll", "expiring"); ok {
+		t.Error("Expected expired values not to be persisted")
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStateStore(path); err == nil {
+		t.Error("Expected an error for a corrupted state file")
+	}
+}
+
+func TestPipeline_StateAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	cfg := &config.PipelineConfig{
+		State: &config.StateConfig{Type: "file", Path: path},
+		Stages: []config.StageConfig{
+			{ID: "count", StepType: "js", StepConfig: map[string]any{"code": `
+const runs = $state.get("runs", 0) + 1;
+$state.set("runs", runs);
+return runs;`}},
+			{ID: "other", StepType: "js", StepConfig: map[string]any{"code": `return $state.get("runs", "unset");`}},
+		},
+		Outputs: map[string]string{"count": "count", "other": "other"},
+	}
+
+	for i, want := range []int64{1, 2} {
+		p, err := BuildFromConfig(cfg)
+		if err != nil {
+			t.Fatalf("BuildFromConfig failed: %v", err)
+		}
+		// Il secondo run riparte dal file scritto alla fine del primo
+		outputs, err := p.Invoke(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("Invoke failed: %v", err)
+		}
+		if !reflect.DeepEqual(outputs["count"], []any{want}) {
+			t.Errorf("Run %d: expected count [%d], got %v", i+1, want, outputs["count"])
+		}
+		if !reflect.DeepEqual(outputs["other"], []any{"unset"}) {
+			t.Errorf("Run %d: expected the state of 'count' not to be visible to 'other', got %v", i+1, outputs["other"])
+		}
+	}
+
+	if _, err := BuildFromConfig(&config.PipelineConfig{State: &config.StateConfig{Type: "redis"}}); err == nil {
+		t.Error("Expected an error for an unsupported state type")
+	}
+}
+
+func TestPipeline_StateFlushedOnStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+
+	detect, err := builder.CreateStep("js", map[string]any{"code": `
+const changed = $state.get("last") !== ctx.trigger;
+$state.set("last", ctx.trigger);
+return changed;`})
+	if err != nil {
+		t.Fatalf("Failed to create js step: %v", err)
+	}
+
+	p := NewPipeline()
+	p.SetStateStore(store)
+	trigger := NewStage("trigger", &mockStep{continuous: true, output: "v1"})
+	p.AddStage(trigger)
+	if err := p.AddStage(NewStage("detect", detect)).After(trigger); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+
+	results := p.Subscribe("detect", "")
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Il primo valore è un cambiamento, lo stesso valore ripetuto no
+	var changes []any
+	for i, value := range []string{"", "v1", "v2"} {
+		if value != "" {
+			err := p.Replay(context.Background(), DeadLetter{
+				StageID: "detect",
+				EventID: value,
+				Data:    map[string]map[string]*models.Data{"trigger": models.CreateDefaultResultData(value)},
+			})
+			if err != nil {
+				t.Fatalf("Replay failed: %v", err)
+			}
+		}
+		select {
+		case out := <-results:
+			changes = append(changes, out.Data["default"].Value)
+		case <-time.After(time.Second):
+			t.Fatalf("No output for event %d", i)
+		}
+	}
+	if len(changes) != 3 || changes[0] != true || changes[1] != false || changes[2] != true {
+		t.Errorf("Expected changes [true false true], got %v", changes)
+	}
+
+	if err := p.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	reloaded, err := NewFileStateStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStateStore failed: %v", err)
+	}
+	if value, ok, _ := reloaded.Get("detect", "last"); !ok || value != "v2" {
+		t.Errorf("Expected the state to be flushed on Stop, got %v (ok=%v)", value, ok)
+	}
+}
@@ -0,0 +1,307 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/simon020286/go-pipeline/config"
+
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// @step name=batch category=flow ports=default description=Buffers incoming events and emits them as a single array by size, time window or end of input
+type BatchConfig struct {
+	Size    int              `step:"desc=Emit a batch as soon as this many items are buffered (0 = no size limit)"`
+	Window  time.Duration    `step:"desc=Time window duration (e.g. 5s or 1m); buffered items are emitted when the window expires"`
+	Mode    string           `step:"default=tumbling,desc=Window mode: tumbling (each item in one batch) or sliding (overlapping windows)"`
+	Slide   time.Duration    `step:"desc=Emit interval for sliding windows (defaults to the window duration)"`
+	GroupBy config.ValueSpec `step:"name=group_by,desc=Expression whose result partitions items into separate batches"`
+	Item    config.ValueSpec `step:"desc=Value collected for each event (defaults to the upstream output)"`
+}
+
+const (
+	batchModeTumbling = "tumbling"
+	batchModeSliding  = "sliding"
+)
+
+// BatchStep buffers inputs and emits them as arrays
+// A batch is emitted when Size items are buffered, when the window expires
+// or when the input channel is closed
+type BatchStep struct {
+	size    int
+	window  time.Duration
+	slide   time.Duration
+	mode    string
+	groupBy config.ValueSpec // optional: nil = single group
+	item    config.ValueSpec // optional: nil = upstream output
+}
+
+// batchEntry is a single buffered item
+type batchEntry struct {
+	value    any
+	eventID  string
+	received time.Time
+}
+
+// batchGroup holds the buffered items of a group_by key
+type batchGroup struct {
+	key     string
+	entries []batchEntry
+	dirty   bool // true if items were added since the last emission (sliding mode)
+}
+
+func (s *BatchStep) IsContinuous() bool {
+	return false // Step batch, termina quando l'input viene chiuso
+}
+
+func (s *BatchStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput, 10)
+	errorChan := make(chan error, 1)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		groups := make(map[string]*batchGroup)
+		order := make([]string, 0) // Group keys in arrival order, for deterministic flushes
+
+		// Ticker for time windows (nil channel blocks forever when there is no window)
+		var tick <-chan time.Time
+		if interval := s.tickInterval(); interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		// Un gruppo svuotato viene rimosso: le chiavi che non arrivano più non occupano memoria
+		removeGroup := func(key string) {
+			delete(groups, key)
+			order = slices.DeleteFunc(order, func(k string) bool { return k == key })
+		}
+
+		emit := func(group *batchGroup) bool {
+			select {
+			case outputChan <- s.buildOutput(group):
+				return true
+			case <-ctx.Done():
+				errorChan <- errors.New("step cancelled")
+				return false
+			}
+		}
+
+		for {
+			select {
+			case input, ok := <-inputs:
+				if !ok {
+					// Input closed: flush whatever is still buffered
+					for _, key := range order {
+						group := groups[key]
+						if len(group.entries) == 0 || (s.mode == batchModeSliding && !group.dirty) {
+							continue
+						}
+						if !emit(group) {
+							return
+						}
+					}
+					return
+				}
+
+				key, value, err := s.resolveInput(input)
+				if err != nil {
+					errorChan <- err
+					return
+				}
+
+				group, exists := groups[key]
+				if !exists {
+					group = &batchGroup{key: key}
+					groups[key] = group
+					order = append(order, key)
+				}
+				group.entries = append(group.entries, batchEntry{
+					value:    value,
+					eventID:  input.EventID,
+					received: time.Now(),
+				})
+				group.dirty = true
+
+				if s.size <= 0 || len(group.entries) < s.size {
+					continue
+				}
+
+				if s.mode == batchModeSliding {
+					// In sliding mode size caps the window to the most recent items
+					group.entries = group.entries[len(group.entries)-s.size:]
+					continue
+				}
+
+				if !emit(group) {
+					return
+				}
+				removeGroup(key)
+
+			case now := <-tick:
+				for _, key := range slices.Clone(order) {
+					group := groups[key]
+					if s.mode == batchModeSliding {
+						group.entries = evictExpired(group.entries, now.Add(-s.window))
+					}
+					if len(group.entries) == 0 {
+						removeGroup(key)
+						continue
+					}
+					if !emit(group) {
+						return
+					}
+					if s.mode == batchModeTumbling {
+						removeGroup(key)
+					}
+					group.dirty = false
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+// tickInterval returns how often the window ticker fires (0 = no ticker)
+func (s *BatchStep) tickInterval() time.Duration {
+	if s.mode == batchModeSliding {
+		return s.slide
+	}
+	return s.window
+}
+
+// resolveInput resolves the group key and the collected value for an input
+func (s *BatchStep) resolveInput(input *models.StepInput) (string, any, error) {
+	key := ""
+	if s.groupBy != nil {
+		keyResolved, err := s.groupBy.Resolve(input)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to resolve group_by: %w", err)
+		}
+		key = fmt.Sprintf("%v", keyResolved)
+	}
+
+	if s.item != nil {
+		value, err := s.item.Resolve(input)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to resolve item: %w", err)
+		}
+		return key, value, nil
+	}
+
+	return key, upstreamValue(input), nil
+}
+
+// buildOutput creates the output for a group, copying the buffered items
+func (s *BatchStep) buildOutput(group *batchGroup) models.StepOutput {
+	items := make([]any, len(group.entries))
+	eventIDs := make([]any, len(group.entries))
+	for i, entry := range group.entries {
+		items[i] = entry.value
+		eventIDs[i] = entry.eventID
+	}
+
+	return models.StepOutput{
+		Data: models.CreateDefaultResultData(map[string]any{
+			"items":     items,
+			"count":     len(items),
+			"key":       group.key,
+			"event_ids": eventIDs,
+		}),
+		EventID:   builder.GenerateEventID(), // Un batch è un nuovo evento
+		Timestamp: time.Now(),
+	}
+}
+
+// evictExpired drops the entries received before the cutoff
+func evictExpired(entries []batchEntry, cutoff time.Time) []batchEntry {
+	for i, entry := range entries {
+		if !entry.received.Before(cutoff) {
+			return entries[i:]
+		}
+	}
+	return nil
+}
+
+// upstreamValue returns the upstream data of an input using the same shape as the JS ctx:
+// with a single dependency its value is returned directly, otherwise a map keyed by stage ID
+func upstreamValue(input *models.StepInput) any {
+	input.Lock()
+	defer input.Unlock()
+
+	values := make(map[string]any, len(input.Data))
+	for stageID, outputs := range input.Data {
+		if data, ok := outputs["default"]; ok && len(outputs) == 1 {
+			values[stageID] = data.Value
+			continue
+		}
+		stageValues := make(map[string]any, len(outputs))
+		for port, data := range outputs {
+			stageValues[port] = data.Value
+		}
+		values[stageID] = stageValues
+	}
+
+	if len(values) == 1 {
+		for _, value := range values {
+			return value
+		}
+	}
+	return values
+}
+
+func init() {
+	builder.RegisterStepType("batch", func(cfg map[string]any) (models.Step, error) {
+		var c BatchConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid batch step: %w", err)
+		}
+		if c.Size < 0 {
+			return nil, fmt.Errorf("'size' must not be negative, got %d", c.Size)
+		}
+		if c.Window < 0 || c.Slide < 0 {
+			return nil, errors.New("'window' and 'slide' must be positive")
+		}
+
+		mode := c.Mode
+		if mode == "" {
+			mode = batchModeTumbling // Default to tumbling windows
+		}
+
+		slide := c.Slide
+		switch mode {
+		case batchModeTumbling:
+			if slide > 0 {
+				return nil, errors.New("'slide' is only supported in sliding mode")
+			}
+		case batchModeSliding:
+			if c.Window == 0 {
+				return nil, errors.New("sliding mode requires 'window'")
+			}
+			if slide == 0 {
+				slide = c.Window
+			}
+		default:
+			return nil, fmt.Errorf("invalid 'mode' %q (expected tumbling or sliding)", mode)
+		}
+
+		return &BatchStep{
+			size:    c.Size,
+			window:  c.Window,
+			slide:   slide,
+			mode:    mode,
+			groupBy: c.GroupBy,
+			item:    c.Item,
+		}, nil
+	})
+}
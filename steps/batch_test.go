@@ -0,0 +1,209 @@
+package steps
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func newBatchInput(eventID string, value any) *models.StepInput {
+	return &models.StepInput{
+		Data: map[string]map[string]*models.Data{
+			"source": models.CreateDefaultResultData(value),
+		},
+		EventID: eventID,
+	}
+}
+
+func createBatchStep(t *testing.T, cfg map[string]any) *BatchStep {
+	t.Helper()
+	step, err := builder.CreateStep("batch", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create batch step: %v", err)
+	}
+	return step.(*BatchStep)
+}
+
+func collectBatches(outputChan <-chan models.StepOutput, errorChan <-chan error) ([]map[string]any, error) {
+	var batches []map[string]any
+	for out := range outputChan {
+		batches = append(batches, out.Data["default"].Value.(map[string]any))
+	}
+	if err, ok := <-errorChan; ok {
+		return batches, err
+	}
+	return batches, nil
+}
+
+func TestBatchStep_Size(t *testing.T) {
+	step := createBatchStep(t, map[string]any{"size": 2})
+
+	inputChan := make(chan *models.StepInput, 5)
+	for i, v := range []int{1, 2, 3, 4, 5} {
+		inputChan <- newBatchInput(string(rune('a'+i)), v)
+	}
+	close(inputChan)
+
+	batches, err := collectBatches(step.Run(context.Background(), inputChan))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(batches) != 3 {
+		t.Fatalf("Expected 3 batches, got %d", len(batches))
+	}
+	if batches[0]["count"] != 2 || batches[2]["count"] != 1 {
+		t.Errorf("Unexpected batch sizes: %v", batches)
+	}
+	items := batches[1]["items"].([]any)
+	if items[0] != 3 || items[1] != 4 {
+		t.Errorf("Expected items [3 4], got %v", items)
+	}
+	eventIDs := batches[0]["event_ids"].([]any)
+	if eventIDs[0] != "a" || eventIDs[1] != "b" {
+		t.Errorf("Expected event IDs [a b], got %v", eventIDs)
+	}
+}
+
+func TestBatchStep_FlushOnClose(t *testing.T) {
+	step := createBatchStep(t, map[string]any{})
+
+	inputChan := make(chan *models.StepInput, 3)
+	inputChan <- newBatchInput("e1", "x")
+	inputChan <- newBatchInput("e2", "y")
+	inputChan <- newBatchInput("e3", "z")
+	close(inputChan)
+
+	batches, err := collectBatches(step.Run(context.Background(), inputChan))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(batches))
+	}
+	if batches[0]["count"] != 3 {
+		t.Errorf("Expected 3 items, got %v", batches[0]["count"])
+	}
+}
+
+func TestBatchStep_GroupBy(t *testing.T) {
+	step := createBatchStep(t, map[string]any{
+		"size":     2,
+		"group_by": "$js: ctx.source.type",
+	})
+
+	inputChan := make(chan *models.StepInput, 4)
+	inputChan <- newBatchInput("e1", map[string]any{"type": "a", "n": 1})
+	inputChan <- newBatchInput("e2", map[string]any{"type": "b", "n": 2})
+	inputChan <- newBatchInput("e3", map[string]any{"type": "a", "n": 3})
+	inputChan <- newBatchInput("e4", map[string]any{"type": "b", "n": 4})
+	close(inputChan)
+
+	batches, err := collectBatches(step.Run(context.Background(), inputChan))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(batches) != 2 {
+		t.Fatalf("Expected 2 batches, got %d", len(batches))
+	}
+	if batches[0]["key"] != "a" || batches[1]["key"] != "b" {
+		t.Errorf("Expected keys a and b, got %v and %v", batches[0]["key"], batches[1]["key"])
+	}
+	for _, batch := range batches {
+		if batch["count"] != 2 {
+			t.Errorf("Expected 2 items in group %v, got %v", batch["key"], batch["count"])
+		}
+	}
+}
+
+func TestBatchStep_TumblingWindow(t *testing.T) {
+	step := createBatchStep(t, map[string]any{"window": "50ms"})
+
+	inputChan := make(chan *models.StepInput)
+	outputChan, errorChan := step.Run(context.Background(), inputChan)
+
+	inputChan <- newBatchInput("e1", 1)
+	inputChan <- newBatchInput("e2", 2)
+
+	select {
+	case out := <-outputChan:
+		batch := out.Data["default"].Value.(map[string]any)
+		if batch["count"] != 2 {
+			t.Errorf("Expected 2 items in window, got %v", batch["count"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Window did not expire")
+	}
+
+	close(inputChan)
+	if batches, _ := collectBatches(outputChan, errorChan); len(batches) != 0 {
+		t.Errorf("Expected no more batches, got %d", len(batches))
+	}
+}
+
+func TestBatchStep_SlidingWindow(t *testing.T) {
+	step := createBatchStep(t, map[string]any{"window": "500ms", "mode": "sliding", "slide": "200ms"})
+
+	inputChan := make(chan *models.StepInput)
+	outputChan, errorChan := step.Run(context.Background(), inputChan)
+
+	next := func() []any {
+		t.Helper()
+		select {
+		case out := <-outputChan:
+			return out.Data["default"].Value.(map[string]any)["items"].([]any)
+		case <-time.After(time.Second):
+			t.Fatal("No batch emitted")
+			return nil
+		}
+	}
+
+	// t=0: 1; t=200ms: [1], poi 2; t=400ms: [1 2]; t=600ms: 1 è fuori dalla finestra
+	inputChan <- newBatchInput("e1", 1)
+	if items := next(); !reflect.DeepEqual(items, []any{1}) {
+		t.Errorf("Expected the first window [1], got %v", items)
+	}
+	inputChan <- newBatchInput("e2", 2)
+	if items := next(); !reflect.DeepEqual(items, []any{1, 2}) {
+		t.Errorf("Expected the overlapping window [1 2], got %v", items)
+	}
+	if items := next(); !reflect.DeepEqual(items, []any{2}) {
+		t.Errorf("Expected the window [2] once 1 expired, got %v", items)
+	}
+
+	// Nothing new since the last window: closing the input emits nothing
+	close(inputChan)
+	if batches, _ := collectBatches(outputChan, errorChan); len(batches) != 0 {
+		t.Errorf("Expected no more batches, got %v", batches)
+	}
+}
+
+func TestBatchStep_InvalidConfig(t *testing.T) {
+	cases := []map[string]any{
+		{"size": -1},
+		{"window": "soon"},
+		{"mode": "hopping"},
+		{"mode": "sliding"},
+		{"window": "1s", "slide": "500ms"},
+	}
+
+	for _, cfg := range cases {
+		if _, err := builder.CreateStep("batch", cfg); err == nil {
+			t.Errorf("Expected error for config %v", cfg)
+		}
+	}
+}
+
+func TestBatchStep_ConfigErrors(t *testing.T) {
+	_, err := builder.CreateStep("batch", map[string]any{"size": "10", "window": 5, "mode": "sliding"})
+	want := "invalid batch step: size: expected an integer, got string; window: expected a duration like 500ms or 5s, got int"
+	if err == nil || err.Error() != want {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+}
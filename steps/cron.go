@@ -1,4 +1,4 @@
-//go:generate go run ../codegen/cmd/stepgen .
+//go:generate go run ../codegen/cmd/stepgen -schema ../schema/pipeline.schema.json -docs ../docs/reference -services ../builder/services .
 
 package steps
 
@@ -11,7 +11,7 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=cron category=trigger description=Triggers pipeline execution on a schedule
+// @step name=cron category=trigger ports=default continuous=true description=Triggers pipeline execution on a schedule
 type CronConfig struct {
 	Schedule string `step:"required,desc=Cron expression or duration (e.g. @every 5m or 1h30m)"`
 }
@@ -84,19 +84,19 @@ func parseCronExpression(expr string) (time.Duration, error) {
 
 func init() {
 	builder.RegisterStepType("cron", func(cfg map[string]any) (models.Step, error) {
-		schedule, ok := cfg["schedule"].(string)
-		if !ok {
-			return nil, fmt.Errorf("cron trigger requires 'schedule' configuration")
+		var c CronConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid cron step: %w", err)
 		}
 
 		// Validate the schedule expression
-		_, err := parseCronExpression(schedule)
+		_, err := parseCronExpression(c.Schedule)
 		if err != nil {
 			return nil, fmt.Errorf("invalid schedule expression: %w", err)
 		}
 
 		return &CronStep{
-			schedule: schedule,
+			schedule: c.Schedule,
 		}, nil
 	})
 }
@@ -12,9 +12,9 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=delay category=flow description=Pauses pipeline execution for a specified duration
+// @step name=delay category=flow ports=default description=Pauses pipeline execution for a specified duration
 type DelayConfig struct {
-	Ms int `step:"name=ms,required,desc=Delay duration in milliseconds"`
+	Ms config.ValueSpec `step:"name=ms,required,type=int,desc=Delay duration in milliseconds"`
 }
 
 type DelayStep struct {
@@ -77,27 +77,11 @@ func (s *DelayStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<
 
 func init() {
 	builder.RegisterStepType("delay", func(cfg map[string]any) (models.Step, error) {
-		ms, ok := cfg["ms"]
-		if !ok {
-			return nil, errors.New("missing 'ms' in delay step")
-		}
-		rawInputs, _ := cfg["inputs"].([]any)
-		inputs := make([]string, len(rawInputs))
-		for i, v := range rawInputs {
-			inputs[i], ok = v.(string)
-			if !ok {
-				return nil, errors.New("invalid input name")
-			}
-		}
-		// Converti in ValueSpec
-		var delaySpec config.ValueSpec
-		if vs, ok := ms.(config.ValueSpec); ok {
-			delaySpec = vs
-		} else {
-			// Wrappa come StaticValue
-			delaySpec = config.NewStaticValue(ms)
+		var c DelayConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid delay step: %w", err)
 		}
 
-		return &DelayStep{delay: delaySpec}, nil
+		return &DelayStep{delay: c.Ms}, nil
 	})
 }
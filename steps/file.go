@@ -13,9 +13,9 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=file category=data description=Reads the content of a file from the filesystem
+// @step name=file category=data ports=default description=Reads the content of a file from the filesystem
 type FileConfig struct {
-	Path string `step:"required,desc=The path to the file to read"`
+	Path config.ValueSpec `step:"required,type=string,desc=The path to the file to read"`
 }
 
 type FileStep struct {
@@ -69,18 +69,11 @@ func (s *FileStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-
 
 func init() {
 	builder.RegisterStepType("file", func(cfg map[string]any) (models.Step, error) {
-		path, ok := cfg["path"]
-		if !ok {
-			return nil, errors.New("missing 'path' in file step")
-		}
-		// Converti in ValueSpec
-		var pathSpec config.ValueSpec
-		if vs, ok := path.(config.ValueSpec); ok {
-			pathSpec = vs
-		} else {
-			pathSpec = config.NewStaticValue(path)
+		var c FileConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid file step: %w", err)
 		}
 
-		return &FileStep{path: pathSpec}, nil
+		return &FileStep{path: c.Path}, nil
 	})
 }
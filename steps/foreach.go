@@ -14,7 +14,7 @@ import (
 
 // @step name=foreach category=flow description=Iterates over a list and emits each item with its index
 type ForeachConfig struct {
-	List any `step:"required,desc=The list to iterate over"`
+	List config.ValueSpec `step:"required,type=array,desc=The list to iterate over"`
 }
 
 type ForeachStep struct {
@@ -98,21 +98,13 @@ func (s *ForeachStep) Run(ctx context.Context, inputs <-chan *models.StepInput)
 
 func init() {
 	builder.RegisterStepType("foreach", func(cfg map[string]any) (models.Step, error) {
-		list, ok := cfg["list"]
-		if !ok {
-			return nil, errors.New("missing 'list' in foreach step")
-		}
-
-		// Converti in ValueSpec
-		var listSpec config.ValueSpec
-		if vs, ok := list.(config.ValueSpec); ok {
-			listSpec = vs
-		} else {
-			listSpec = config.NewStaticValue(list)
+		var c ForeachConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid foreach step: %w", err)
 		}
 
 		return &ForeachStep{
-			list: listSpec,
+			list: c.List,
 		}, nil
 	})
 }
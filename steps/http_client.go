@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"time"
 
 	"github.com/simon020286/go-pipeline/config"
@@ -16,14 +17,14 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=http_client category=network description=HTTP client for making API requests
+// @step name=http_client category=network ports=default description=HTTP client for making API requests
 type HTTPClientConfig struct {
-	URL         string            `step:"required,desc=The URL to call"`
-	Method      string            `step:"default=GET,desc=HTTP method (GET POST PUT DELETE etc)"`
-	Headers     map[string]string `step:"desc=HTTP headers to send with the request"`
-	Body        any               `step:"desc=Request body for POST PUT etc"`
-	ContentType string            `step:"name=content_type,default=application/json,desc=Content-Type header for the request body"`
-	Response    string            `step:"default=json,desc=Expected response type (json or text)"`
+	URL         config.ValueSpec            `step:"required,type=string,desc=The URL to call"`
+	Method      config.ValueSpec            `step:"default=GET,type=string,desc=HTTP method (GET POST PUT DELETE etc)"`
+	Headers     map[string]config.ValueSpec `step:"type=map[string]string,desc=HTTP headers to send with the request"`
+	Body        config.ValueSpec            `step:"desc=Request body for POST PUT etc"`
+	ContentType string                      `step:"name=content_type,default=application/json,desc=Content-Type header for the request body"`
+	Response    string                      `step:"default=json,desc=Expected response type (json or text)"`
 }
 
 type HTTPClientStep struct {
@@ -33,6 +34,7 @@ type HTTPClientStep struct {
 	bodySpec     config.ValueSpec
 	contentType  string
 	responseType string
+	client       *http.Client // Client of the pipeline (proxy, TLS, timeout)
 }
 
 type HTTPClientResponse struct {
@@ -41,6 +43,21 @@ type HTTPClientResponse struct {
 	Body       any               `json:"body"`
 }
 
+// HTTPStatusError is returned when the server answers with a non-2xx status code
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// HTTPStatusCode returns the response status code (used by event listeners)
+func (e *HTTPStatusError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
 func (s *HTTPClientStep) IsContinuous() bool {
 	return false // Step batch, esegue e termina
 }
@@ -112,12 +129,18 @@ func (s *HTTPClientStep) Run(ctx context.Context, inputs <-chan *models.StepInpu
 			}
 
 			// Esegui la richiesta
-			client := &http.Client{
-				Timeout: 30 * time.Second,
+			client := s.client
+			if client == nil {
+				client = builder.HTTPClient(nil)
 			}
 
+			startTime := time.Now()
 			resp, err := client.Do(req)
 			if err != nil {
+				var urlErr *neturl.Error
+				if errors.As(err, &urlErr) {
+					urlErr.URL = models.RedactedURL(req.URL)
+				}
 				errorChan <- fmt.Errorf("HTTP request failed: %w", err)
 				return
 			}
@@ -126,7 +149,7 @@ func (s *HTTPClientStep) Run(ctx context.Context, inputs <-chan *models.StepInpu
 			// Verifica status code
 			if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 				bodyBytes, _ := io.ReadAll(resp.Body)
-				errorChan <- fmt.Errorf("HTTP request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+				errorChan <- &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 				return
 			}
 
@@ -175,6 +198,15 @@ func (s *HTTPClientStep) Run(ctx context.Context, inputs <-chan *models.StepInpu
 				Data:      models.CreateDefaultResultData(responseData),
 				EventID:   input.EventID,
 				Timestamp: time.Now(),
+				Metadata: map[string]any{
+					"http": map[string]any{
+						"method":      method,
+						"url":         models.RedactedURL(req.URL),
+						"status_code": resp.StatusCode,
+						"started_at":  startTime,
+						"duration":    time.Since(startTime),
+					},
+				},
 			}:
 			case <-ctx.Done():
 				errorChan <- errors.New("step cancelled")
@@ -207,65 +239,19 @@ func serializeBody(body any, contentType string) ([]byte, error) {
 
 func init() {
 	builder.RegisterStepType("http_client", func(cfg map[string]any) (models.Step, error) {
-		urlRaw, ok := cfg["url"]
-		if !ok {
-			return nil, errors.New("missing 'url' in http_client step")
-		}
-
-		methodRaw, ok := cfg["method"]
-		if !ok {
-			methodRaw = "GET" // Default to GET if not specified
-		}
-
-		headers, _ := cfg["headers"].(map[string]config.ValueSpec)
-		headersMap := make(map[string]config.ValueSpec)
-		for k, v := range headers {
-			headersMap[k] = v
-		}
-
-		responseType, ok := cfg["response"].(string)
-		if !ok {
-			responseType = "json" // Default to JSON
-		}
-
-		contentType, ok := cfg["content_type"].(string)
-		if !ok {
-			contentType = "application/json" // Default to JSON
-		}
-
-		bodyRaw := cfg["body"] // Body can be optional (nil)
-
-		// Converti i valori in ValueSpec
-		var urlSpec config.ValueSpec
-		if vs, ok := urlRaw.(config.ValueSpec); ok {
-			urlSpec = vs
-		} else {
-			urlSpec = builder.ParseConfigValue(urlRaw)
-		}
-
-		var methodSpec config.ValueSpec
-		if vs, ok := methodRaw.(config.ValueSpec); ok {
-			methodSpec = vs
-		} else {
-			methodSpec = config.NewStaticValue(methodRaw)
-		}
-
-		var bodySpec config.ValueSpec
-		if bodyRaw != nil {
-			if vs, ok := bodyRaw.(config.ValueSpec); ok {
-				bodySpec = vs
-			} else {
-				bodySpec = config.NewStaticValue(bodyRaw)
-			}
+		var c HTTPClientConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid http_client step: %w", err)
 		}
 
 		return &HTTPClientStep{
-			urlSpec:      urlSpec,
-			methodSpec:   methodSpec,
-			headers:      headersMap,
-			bodySpec:     bodySpec,
-			contentType:  contentType,
-			responseType: responseType,
+			urlSpec:      c.URL,
+			methodSpec:   c.Method,
+			headers:      c.Headers,
+			bodySpec:     c.Body,
+			contentType:  c.ContentType,
+			responseType: c.Response,
+			client:       builder.HTTPClient(cfg),
 		}, nil
 	})
 }
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/simon020286/go-pipeline/builder"
 	"github.com/simon020286/go-pipeline/config"
 	"github.com/simon020286/go-pipeline/models"
 )
@@ -251,6 +253,49 @@ func TestHTTPClientStep_WithHeaders(t *testing.T) {
 	}
 }
 
+func TestHTTPClientStep_InterpolatedConfig(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/42/items" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token123" {
+			t.Errorf("Expected Authorization header 'Bearer token123', got %s", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "ok"})
+	}))
+	defer server.Close()
+
+	// Configuration as decoded from YAML
+	step, err := builder.CreateStep("http_client", map[string]any{
+		"url":     server.URL + "/users/${{ $vars.user_id }}/items",
+		"headers": map[string]any{"Authorization": "Bearer ${{ $secret:token }}"},
+	})
+	if err != nil {
+		t.Fatalf("CreateStep failed: %v", err)
+	}
+
+	inputChan := make(chan *models.StepInput, 1)
+	inputChan <- &models.StepInput{
+		Data:            make(map[string]map[string]*models.Data),
+		EventID:         "test-event",
+		GlobalVariables: map[string]any{"user_id": 42},
+		GlobalSecrets:   map[string]any{"token": "token123"},
+	}
+	close(inputChan)
+
+	outputChan, errorChan := step.Run(context.Background(), inputChan)
+
+	select {
+	case <-outputChan:
+		// Success
+	case err := <-errorChan:
+		t.Fatalf("Unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for output")
+	}
+}
+
 func TestHTTPClientStep_TextResponse(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
@@ -293,3 +338,53 @@ func TestHTTPClientStep_TextResponse(t *testing.T) {
 		t.Fatal("Timeout waiting for output")
 	}
 }
+
+func TestHTTPClientStep_MetadataURLWithoutQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api_key") != "s3cr3t" {
+			t.Errorf("Expected the query string to be sent, got %q", r.URL.RawQuery)
+		}
+		w.Write([]byte("ok"))
+	}))
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	defer server.Close()
+
+	run := func(url string) (models.StepOutput, error) {
+		step := &HTTPClientStep{
+			urlSpec:      config.NewStaticValue(url),
+			methodSpec:   config.NewStaticValue("GET"),
+			headers:      make(map[string]config.ValueSpec),
+			responseType: "text",
+		}
+		inputChan := make(chan *models.StepInput, 1)
+		inputChan <- &models.StepInput{Data: make(map[string]map[string]*models.Data), EventID: "test-event"}
+		close(inputChan)
+
+		outputChan, errorChan := step.Run(context.Background(), inputChan)
+		select {
+		case output := <-outputChan:
+			return output, nil
+		case err := <-errorChan:
+			return models.StepOutput{}, err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout waiting for output")
+		}
+		return models.StepOutput{}, nil
+	}
+
+	// La query string (spesso con chiavi API) non finisce negli eventi
+	output, err := run(server.URL + "/items?api_key=s3cr3t#top")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if url := output.Metadata["http"].(map[string]any)["url"]; url != server.URL+"/items" {
+		t.Errorf("Expected the URL without query string, got %v", url)
+	}
+
+	// Né negli errori di connessione
+	_, err = run(closed.URL + "/items?api_key=s3cr3t")
+	if err == nil || strings.Contains(err.Error(), "s3cr3t") || !strings.Contains(err.Error(), closed.URL+"/items") {
+		t.Errorf("Expected a connection error without the query string, got %v", err)
+	}
+}
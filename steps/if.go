@@ -12,9 +12,9 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=if category=flow description=Conditional branching step that evaluates a boolean condition
+// @step name=if category=flow ports=true,false description=Conditional branching step that evaluates a boolean condition
 type IfConfig struct {
-	Condition bool `step:"required,desc=Boolean condition to evaluate (use $js: for dynamic expressions)"`
+	Condition config.ValueSpec `step:"required,type=bool,desc=Boolean condition to evaluate (use $js: for dynamic expressions)"`
 }
 
 type IfStep struct {
@@ -74,19 +74,11 @@ func (s *IfStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-ch
 
 func init() {
 	builder.RegisterStepType("if", func(cfg map[string]any) (models.Step, error) {
-		condition, ok := cfg["condition"]
-		if !ok {
-			return nil, errors.New("missing 'condition' in if step")
+		var c IfConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid if step: %w", err)
 		}
 
-		// Converti in ValueSpec
-		var condSpec config.ValueSpec
-		if vs, ok := condition.(config.ValueSpec); ok {
-			condSpec = vs
-		} else {
-			condSpec = config.NewStaticValue(condition)
-		}
-
-		return &IfStep{condition: condSpec}, nil
+		return &IfStep{condition: c.Condition}, nil
 	})
 }
@@ -6,18 +6,29 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/dop251/goja"
 	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/jsruntime"
 	"github.com/simon020286/go-pipeline/models"
 )
 
 // @step name=js category=scripting description=Executes JavaScript code with access to pipeline context
 type JsConfig struct {
-	Code string `step:"required,desc=JavaScript code to execute (use ctx for step outputs and $vars/$secrets for globals)"`
+	Code             string        `step:"required,raw,desc=JavaScript code to execute (use ctx for step outputs and $vars/$secrets for globals)"`
+	MaxExecutionTime time.Duration `step:"name=max_execution_time,desc=Maximum execution time per event (e.g. 500ms); the script is also interrupted when the pipeline stops"`
+	MaxCallStackSize int           `step:"name=max_call_stack_size,desc=Maximum function call depth (default 1024)"`
+	MaxMemory        uint64        `step:"name=max_memory,desc=Interrupts the script when the heap allocations made while it runs exceed this many bytes (approximate: allocations are sampled for the whole process)"`
+	EmitEach         bool          `step:"name=emit_each,default=false,desc=If true the code returns an array and each element becomes a separate output"`
+	ModuleDirs       []string      `step:"name=module_dirs,desc=Other directories require() can load modules from (relative to the pipeline file); by default only the directory of the pipeline file"`
 }
 
+// OutputsKey is the key of a js step result that sets several ports: { $outputs: { port: value } }
+const OutputsKey = "$outputs"
+
 type JsStep struct {
-	code string
+	code     string
+	program  *jsruntime.Program // Compiled when the step is built
+	limits   jsruntime.Limits
+	emitEach bool
 }
 
 func (s *JsStep) IsContinuous() bool {
@@ -32,100 +43,146 @@ func (s *JsStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-ch
 		defer close(outputChan)
 		defer close(errorChan)
 
-		// Process ALL incoming inputs
-		for input := range inputs {
-			// Prepare JavaScript context
-			runtime := goja.New()
-			jsCtx := make(map[string]any)
-
-			input.Lock()
-			for stepName, outputs := range input.Data {
-				// If there's only one "default" output, use the value directly
-				if len(outputs) == 1 {
-					if data, ok := outputs["default"]; ok {
-						jsCtx[stepName] = data.Value
-						continue
-					}
-				}
-
-				// Otherwise create a map of all outputs
-				stepCtx := make(map[string]any)
-				for outName, data := range outputs {
-					stepCtx[outName] = data.Value
-				}
-				jsCtx[stepName] = stepCtx
+		program := s.program
+		if program == nil {
+			var err error
+			if program, err = jsruntime.Compile(s.code); err != nil {
+				errorChan <- fmt.Errorf("JavaScript compilation error: %w", err)
+				return
 			}
+			program = program.EnableRequire("").EnableFetch(builder.HTTPClient(nil))
+		}
 
-			// Add execution metadata
-			if input.EventID != "" {
-				jsCtx["_execution"] = map[string]any{
-					"id": input.EventID,
-				}
+		// Process ALL incoming inputs
+		for input := range inputs {
+			// The code runs on a pooled runtime, with ctx, $vars and $secrets bound to the input
+			// The user can write: return { key: "value" }; or call emit("port", value)
+			var emitted []map[string]*models.Data
+			emit := func(port string, value any) error {
+				emitted = append(emitted, models.CreateResultData(port, value))
+				return nil
 			}
-			input.Unlock()
-
-			// Set the context in the JavaScript runtime
-			if err := runtime.Set("ctx", jsCtx); err != nil {
-				errorChan <- fmt.Errorf("failed to set context in JavaScript runtime: %w", err)
+			result, err := program.RunWithEmit(ctx, input, s.limits, emit)
+			if err != nil {
+				errorChan <- fmt.Errorf("JavaScript execution error: %w", err)
 				return
 			}
 
-			// Set global variables and secrets in the JavaScript runtime
-			if input.GlobalVariables != nil {
-				if err := runtime.Set("$vars", input.GlobalVariables); err != nil {
-					errorChan <- fmt.Errorf("failed to set global variables in JavaScript runtime: %w", err)
-					return
-				}
+			outputs, err := s.outputs(result, emitted)
+			if err != nil {
+				errorChan <- fmt.Errorf("invalid JavaScript result: %w", err)
+				return
 			}
 
-			if input.GlobalSecrets != nil {
-				if err := runtime.Set("$secrets", input.GlobalSecrets); err != nil {
-					errorChan <- fmt.Errorf("failed to set global secrets in JavaScript runtime: %w", err)
+			// Send the results, in order
+			for _, data := range outputs {
+				select {
+				case outputChan <- models.StepOutput{
+					Data:      data,
+					EventID:   input.EventID,
+					Timestamp: time.Now(),
+				}:
+				case <-ctx.Done():
+					errorChan <- errors.New("step cancelled")
 					return
 				}
 			}
+		}
+	}()
 
-			// Wrap the code in an anonymous function to allow return usage
-			// The user can write: return { key: "value" };
-			// It gets transformed to: (function() { return { key: "value" }; })()
-			wrappedCode := "(function() {\n" + s.code + "\n})()"
+	return outputChan, errorChan
+}
 
-			// Execute the wrapped JavaScript code
-			result, err := runtime.RunString(wrappedCode)
-			if err != nil {
-				errorChan <- fmt.Errorf("JavaScript execution error: %w", err)
-				return
-			}
+// outputs converts the result of a run into the outputs of the step: the emitted outputs first,
+// then the returned value (nothing if the code called emit and returned no value)
+func (s *JsStep) outputs(result any, emitted []map[string]*models.Data) ([]map[string]*models.Data, error) {
+	outputs := emitted
+	if result == nil && len(emitted) > 0 {
+		return outputs, nil
+	}
+
+	items := []any{result}
+	if s.emitEach {
+		array, ok := result.([]any)
+		if !ok {
+			return nil, fmt.Errorf("emit_each requires an array result, got %T", result)
+		}
+		items = array
+	}
 
-			// Export the result
-			output := result.Export()
-
-			// Send the result
-			select {
-			case outputChan <- models.StepOutput{
-				Data:      models.CreateDefaultResultData(output),
-				EventID:   input.EventID,
-				Timestamp: time.Now(),
-			}:
-			case <-ctx.Done():
-				errorChan <- errors.New("step cancelled")
-				return
-			}
+	for _, item := range items {
+		data, err := resultData(item)
+		if err != nil {
+			return nil, err
 		}
-	}()
+		if data != nil {
+			outputs = append(outputs, data)
+		}
+	}
+	return outputs, nil
+}
 
-	return outputChan, errorChan
+// resultData converts a returned value: { $outputs: {...} } sets a port per key (an empty
+// object produces no output), any other value is the default port
+func resultData(value any) (map[string]*models.Data, error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return models.CreateDefaultResultData(value), nil
+	}
+	ports, ok := object[OutputsKey]
+	if !ok {
+		return models.CreateDefaultResultData(value), nil
+	}
+	if len(object) != 1 {
+		return nil, fmt.Errorf("%s cannot be combined with other keys", OutputsKey)
+	}
+	portValues, ok := ports.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object of port values, got %T", OutputsKey, ports)
+	}
+	if len(portValues) == 0 {
+		return nil, nil
+	}
+
+	data := make(map[string]*models.Data, len(portValues))
+	for port, portValue := range portValues {
+		data[port] = &models.Data{Value: portValue}
+	}
+	return data, nil
 }
 
 func init() {
 	builder.RegisterStepType("js", func(cfg map[string]any) (models.Step, error) {
-		code, ok := cfg["code"].(string)
-		if !ok {
-			return nil, errors.New("missing 'code' in js step")
+		var c JsConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid js step: %w", err)
+		}
+		if c.MaxExecutionTime < 0 {
+			return nil, fmt.Errorf("'max_execution_time' must be positive, got %s", c.MaxExecutionTime)
+		}
+		if c.MaxCallStackSize < 0 {
+			return nil, fmt.Errorf("'max_call_stack_size' must be positive, got %d", c.MaxCallStackSize)
+		}
+
+		program, err := jsruntime.Compile(c.Code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JavaScript code in js step: %w", err)
 		}
+		// require() risolve i percorsi dalla cartella del file della pipeline e non ne esce,
+		// salvo le cartelle di module_dirs
+		baseDir, _ := cfg[builder.BaseDirKey].(string)
+		// fetch() usa il client HTTP della pipeline (proxy, TLS, timeout)
+		program = program.EnableRequire(baseDir, c.ModuleDirs...).EnableFetch(builder.HTTPClient(cfg))
 
 		return &JsStep{
-			code: code,
+			code:    c.Code,
+			program: program,
+			limits: jsruntime.Limits{
+				MaxExecutionTime: c.MaxExecutionTime,
+				MaxCallStackSize: c.MaxCallStackSize,
+				MaxAllocBytes:    c.MaxMemory,
+			},
+			emitEach: c.EmitEach,
 		}, nil
 	})
 }
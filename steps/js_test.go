@@ -0,0 +1,301 @@
+package steps
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/builder"
+	"github.com/simon020286/go-pipeline/config"
+	"github.com/simon020286/go-pipeline/jsruntime"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+func TestJsStep_MaxExecutionTime(t *testing.T) {
+	step, err := builder.CreateStep("js", map[string]any{
+		"code":               "while (true) {}",
+		"max_execution_time": "50ms",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create js step: %v", err)
+	}
+
+	inputChan := make(chan *models.StepInput, 1)
+	inputChan <- &models.StepInput{Data: map[string]map[string]*models.Data{}, EventID: "a"}
+	close(inputChan)
+
+	start := time.Now()
+	outputChan, errorChan := step.Run(context.Background(), inputChan)
+	for range outputChan {
+		t.Error("Unexpected output")
+	}
+	err = <-errorChan
+
+	var timeoutErr *jsruntime.JSTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected *jsruntime.JSTimeoutError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Script was not interrupted in time (%s)", elapsed)
+	}
+}
+
+func TestJsStep_MaxCallStackSize(t *testing.T) {
+	run := func(cfg map[string]any) error {
+		step, err := builder.CreateStep("js", cfg)
+		if err != nil {
+			t.Fatalf("Failed to create js step: %v", err)
+		}
+		inputChan := make(chan *models.StepInput, 1)
+		inputChan <- &models.StepInput{Data: map[string]map[string]*models.Data{}, EventID: "a"}
+		close(inputChan)
+
+		outputChan, errorChan := step.Run(context.Background(), inputChan)
+		for range outputChan {
+		}
+		return <-errorChan
+	}
+	code := "const depth = (n) => n === 0 ? 0 : 1 + depth(n - 1); return depth(100);"
+
+	if err := run(map[string]any{"code": code}); err != nil {
+		t.Fatalf("Unexpected error with the default call depth: %v", err)
+	}
+	err := run(map[string]any{"code": code, "max_call_stack_size": 50})
+	if err == nil || !strings.Contains(err.Error(), "maximum call stack size exceeded (50)") {
+		t.Errorf("Expected a stack overflow at depth 50, got %v", err)
+	}
+}
+
+func TestJsStep_InvalidConfig(t *testing.T) {
+	_, err := builder.CreateStep("js", map[string]any{"code": "return ;;("})
+	var syntaxErr *jsruntime.JSSyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected *jsruntime.JSSyntaxError, got %v", err)
+	}
+
+	if _, err := builder.CreateStep("js", map[string]any{"code": "return 1;", "max_execution_time": "soon"}); err == nil {
+		t.Error("Expected an error for an invalid max_execution_time")
+	}
+	if _, err := builder.CreateStep("js", map[string]any{"code": "return 1;", "max_memory": -1}); err == nil {
+		t.Error("Expected an error for a negative max_memory")
+	}
+}
+
+func TestJsStep_Require(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "helpers.js"), []byte("exports.double = (n) => n * 2;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	step, err := builder.CreateStep("js", builder.WithBaseDir(map[string]any{
+		"code": "return require('./helpers').double(ctx.source);",
+	}, dir))
+	if err != nil {
+		t.Fatalf("Failed to create js step: %v", err)
+	}
+
+	inputChan := make(chan *models.StepInput, 1)
+	inputChan <- &models.StepInput{
+		Data:    map[string]map[string]*models.Data{"source": models.CreateDefaultResultData(21)},
+		EventID: "a",
+	}
+	close(inputChan)
+
+	outputChan, errorChan := step.Run(context.Background(), inputChan)
+	var results []any
+	for out := range outputChan {
+		results = append(results, out.Data["default"].Value)
+	}
+	if err := <-errorChan; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0] != int64(42) {
+		t.Errorf("Expected [42], got %v", results)
+	}
+}
+
+// runJs runs a js step on one input and returns its outputs
+func runJs(t *testing.T, cfg map[string]any, data map[string]map[string]*models.Data) ([]models.StepOutput, error) {
+	t.Helper()
+	step, err := builder.CreateStep("js", cfg)
+	if err != nil {
+		t.Fatalf("Failed to create js step: %v", err)
+	}
+
+	inputChan := make(chan *models.StepInput, 1)
+	inputChan <- &models.StepInput{Data: data, EventID: "evt_1"}
+	close(inputChan)
+
+	outputChan, errorChan := step.Run(context.Background(), inputChan)
+	var outputs []models.StepOutput
+	for out := range outputChan {
+		outputs = append(outputs, out)
+	}
+	return outputs, <-errorChan
+}
+
+// portValues returns the port values of each output
+func portValues(outputs []models.StepOutput) []map[string]any {
+	values := make([]map[string]any, len(outputs))
+	for i, out := range outputs {
+		values[i] = make(map[string]any, len(out.Data))
+		for port, data := range out.Data {
+			values[i][port] = data.Value
+		}
+	}
+	return values
+}
+
+func TestJsStep_Outputs(t *testing.T) {
+	orders := map[string]map[string]*models.Data{
+		"orders": models.CreateDefaultResultData([]any{
+			map[string]any{"id": "a", "total": 10},
+			map[string]any{"id": "b", "total": -1},
+		}),
+	}
+
+	tests := []struct {
+		name string
+		cfg  map[string]any
+		want []map[string]any
+	}{
+		{
+			name: "return value",
+			cfg:  map[string]any{"code": "return ctx.orders.length;"},
+			want: []map[string]any{{"default": int64(2)}},
+		},
+		{
+			name: "$outputs",
+			cfg:  map[string]any{"code": "return { $outputs: { count: ctx.orders.length, first: ctx.orders[0].id } };"},
+			want: []map[string]any{{"count": int64(2), "first": "a"}},
+		},
+		{
+			name: "empty $outputs",
+			cfg:  map[string]any{"code": "return { $outputs: {} };"},
+			want: []map[string]any{},
+		},
+		{
+			name: "emit",
+			cfg: map[string]any{"code": `
+for (const order of ctx.orders) {
+  emit(order.total > 0 ? "valid" : "invalid", order.id);
+}`},
+			want: []map[string]any{{"valid": "a"}, {"invalid": "b"}},
+		},
+		{
+			name: "emit and return",
+			cfg:  map[string]any{"code": `emit("audit", "seen"); return "done";`},
+			want: []map[string]any{{"audit": "seen"}, {"default": "done"}},
+		},
+		{
+			name: "emit_each",
+			cfg:  map[string]any{"code": "return ctx.orders.map(o => o.id);", "emit_each": true},
+			want: []map[string]any{{"default": "a"}, {"default": "b"}},
+		},
+		{
+			name: "emit_each with ports",
+			cfg: map[string]any{
+				"code":      "return ctx.orders.map(o => ({ $outputs: { [o.total > 0 ? 'valid' : 'invalid']: o.id } }));",
+				"emit_each": true,
+			},
+			want: []map[string]any{{"valid": "a"}, {"invalid": "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputs, err := runJs(t, tt.cfg, orders)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := portValues(outputs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+			for _, out := range outputs {
+				if out.EventID != "evt_1" {
+					t.Errorf("Expected event ID evt_1, got %s", out.EventID)
+				}
+			}
+		})
+	}
+}
+
+func TestJsStep_CodeIsNotInterpolated(t *testing.T) {
+	// Il codice è passato così com'è: ${ in un template literal non è un'interpolazione ${{ }}
+	outputs, err := runJs(t, map[string]any{
+		"code": "const label = `${{a:1}.a}-${{b:2}.b}`; return label + ' $var:none';",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(outputs) != 1 || outputs[0].Data["default"].Value != "1-2 $var:none" {
+		t.Errorf("Unexpected outputs: %v", portValues(outputs))
+	}
+}
+
+func TestJsStep_OutputErrors(t *testing.T) {
+	tests := []struct {
+		cfg     map[string]any
+		message string
+	}{
+		{map[string]any{"code": "return { $outputs: { a: 1 }, extra: true };"}, "cannot be combined"},
+		{map[string]any{"code": "return { $outputs: [1, 2] };"}, "must be an object"},
+		{map[string]any{"code": "return 1;", "emit_each": true}, "requires an array"},
+		{map[string]any{"code": "emit('', 1);"}, "port name"},
+	}
+
+	for _, tt := range tests {
+		_, err := runJs(t, tt.cfg, map[string]map[string]*models.Data{})
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.cfg["code"], tt.message, err)
+		}
+	}
+
+	if _, err := builder.CreateStep("js", map[string]any{"code": "return 1;", "emit_each": "yes"}); err == nil {
+		t.Error("Expected an error for a non-boolean emit_each")
+	}
+}
+
+func TestJsStep_FetchUsesPipelineClient(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.String() {
+		case "http://api.invalid/user":
+			w.Write([]byte(`{"id":7}`))
+		case "http://api.invalid/orders?user=7":
+			w.Write([]byte(`[{"total":10},{"total":32}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer proxy.Close()
+
+	// Il proxy della pipeline risponde al posto di api.invalid
+	client, err := builder.NewHTTPClient(&config.HTTPConfig{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+
+	outputs, err := runJs(t, builder.WithHTTPClient(map[string]any{
+		"code": `
+			const user = await (await fetch("http://api.invalid/user")).json();
+			const orders = await (await fetch("http://api.invalid/orders?user=" + user.id)).json();
+			return orders.reduce((sum, o) => sum + o.total, 0);
+		`,
+	}, client), map[string]map[string]*models.Data{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := portValues(outputs)
+	if want := []map[string]any{{"default": int64(42)}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
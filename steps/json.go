@@ -13,9 +13,9 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=json category=data description=Parses a JSON string into a structured object
+// @step name=json category=data ports=default description=Parses a JSON string into a structured object
 type JsonConfig struct {
-	Data string `step:"required,desc=JSON string to parse (supports variable interpolation)"`
+	Data config.ValueSpec `step:"required,type=string,desc=JSON string to parse (supports variable interpolation)"`
 }
 
 type JsonStep struct {
@@ -70,21 +70,13 @@ func (s *JsonStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-
 
 func init() {
 	builder.RegisterStepType("json", func(cfg map[string]any) (models.Step, error) {
-		data, ok := cfg["data"]
-		if !ok {
-			return nil, errors.New("missing 'data' in json step")
-		}
-
-		// Converti in ValueSpec
-		var dataSpec config.ValueSpec
-		if vs, ok := data.(config.ValueSpec); ok {
-			dataSpec = vs
-		} else {
-			dataSpec = config.NewStaticValue(data)
+		var c JsonConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid json step: %w", err)
 		}
 
 		return &JsonStep{
-			data: dataSpec,
+			data: c.Data,
 		}, nil
 	})
 }
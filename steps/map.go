@@ -12,15 +12,15 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=map category=data description=Creates an object by mapping named fields to values
+// @step name=map category=data ports=default description=Creates an object by mapping named fields to values
 type MapConfig struct {
 	Fields []MapField `step:"required,desc=List of name/value pairs defining the output fields"`
 }
 
 // MapField represents a single field in the map step
 type MapField struct {
-	Name  string `json:"name"`
-	Value any    `json:"value"`
+	Name  string           `json:"name" step:"required"`
+	Value config.ValueSpec `json:"value" step:"required"`
 }
 
 type MapStep struct {
@@ -71,43 +71,14 @@ func (s *MapStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-c
 
 func init() {
 	builder.RegisterStepType("map", func(cfg map[string]any) (models.Step, error) {
-		fields, ok := cfg["fields"]
-		if !ok {
-			return nil, errors.New("missing 'fields' in map step")
+		var c MapConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid map step: %w", err)
 		}
 
-		fieldList, ok := fields.([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("fields must be a list of maps, got %T", fields)
-		}
-
-		mapStepFields := make(map[string]config.ValueSpec)
-
-		for _, field := range fieldList {
-			fieldMap, ok := field.(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("each field must be a map, got %T", field)
-			}
-
-			name, ok := fieldMap["name"].(string)
-			if !ok {
-				return nil, fmt.Errorf("field map must contain a 'name' key with a string value, got %T", fieldMap["name"])
-			}
-
-			value, ok := fieldMap["value"]
-			if !ok {
-				return nil, fmt.Errorf("field map must contain a 'value' key, got %v", fieldMap)
-			}
-
-			// Converti in ValueSpec
-			var fieldSpec config.ValueSpec
-			if vs, ok := value.(config.ValueSpec); ok {
-				fieldSpec = vs
-			} else {
-				fieldSpec = config.NewStaticValue(value)
-			}
-
-			mapStepFields[name] = fieldSpec
+		mapStepFields := make(map[string]config.ValueSpec, len(c.Fields))
+		for _, field := range c.Fields {
+			mapStepFields[field.Name] = field.Value
 		}
 
 		return &MapStep{fields: mapStepFields}, nil
@@ -0,0 +1,366 @@
+// Code generated by stepgen. DO NOT EDIT.
+package steps
+
+import (
+	"encoding/json"
+
+	"github.com/simon020286/go-pipeline/config"
+)
+
+// StepMetadata represents the complete metadata for a step type
+type StepMetadata = config.StepMetadata
+
+// InputMeta represents an input parameter metadata
+type InputMeta = config.InputMeta
+
+// stepsMetadataJSON contains the embedded JSON metadata
+var stepsMetadataJSON = `{
+  "steps": [
+    {
+      "name": "batch",
+      "category": "flow",
+      "description": "Buffers incoming events and emits them as a single array by size, time window or end of input",
+      "inputs": [
+        {
+          "name": "size",
+          "type": "int",
+          "required": false,
+          "description": "Emit a batch as soon as this many items are buffered (0 = no size limit)"
+        },
+        {
+          "name": "window",
+          "type": "duration",
+          "required": false,
+          "description": "Time window duration (e.g. 5s or 1m); buffered items are emitted when the window expires"
+        },
+        {
+          "name": "mode",
+          "type": "string",
+          "required": false,
+          "default": "tumbling",
+          "description": "Window mode: tumbling (each item in one batch) or sliding (overlapping windows)"
+        },
+        {
+          "name": "slide",
+          "type": "duration",
+          "required": false,
+          "description": "Emit interval for sliding windows (defaults to the window duration)"
+        },
+        {
+          "name": "group_by",
+          "type": "any",
+          "required": false,
+          "description": "Expression whose result partitions items into separate batches"
+        },
+        {
+          "name": "item",
+          "type": "any",
+          "required": false,
+          "description": "Value collected for each event (defaults to the upstream output)"
+        }
+      ],
+      "ports": [
+        "default"
+      ]
+    },
+    {
+      "name": "cron",
+      "category": "trigger",
+      "description": "Triggers pipeline execution on a schedule",
+      "inputs": [
+        {
+          "name": "schedule",
+          "type": "string",
+          "required": true,
+          "description": "Cron expression or duration (e.g. @every 5m or 1h30m)"
+        }
+      ],
+      "ports": [
+        "default"
+      ],
+      "continuous": true
+    },
+    {
+      "name": "delay",
+      "category": "flow",
+      "description": "Pauses pipeline execution for a specified duration",
+      "inputs": [
+        {
+          "name": "ms",
+          "type": "int",
+          "required": true,
+          "description": "Delay duration in milliseconds"
+        }
+      ],
+      "ports": [
+        "default"
+      ]
+    },
+    {
+      "name": "file",
+      "category": "data",
+      "description": "Reads the content of a file from the filesystem",
+      "inputs": [
+        {
+          "name": "path",
+          "type": "string",
+          "required": true,
+          "description": "The path to the file to read"
+        }
+      ],
+      "ports": [
+        "default"
+      ]
+    },
+    {
+      "name": "foreach",
+      "category": "flow",
+      "description": "Iterates over a list and emits each item with its index",
+      "inputs": [
+        {
+          "name": "list",
+          "type": "array",
+          "required": true,
+          "description": "The list to iterate over"
+        }
+      ]
+    },
+    {
+      "name": "http_client",
+      "category": "network",
+      "description": "HTTP client for making API requests",
+      "inputs": [
+        {
+          "name": "url",
+          "type": "string",
+          "required": true,
+          "description": "The URL to call"
+        },
+        {
+          "name": "method",
+          "type": "string",
+          "required": false,
+          "default": "GET",
+          "description": "HTTP method (GET POST PUT DELETE etc)"
+        },
+        {
+          "name": "headers",
+          "type": "map[string]string",
+          "required": false,
+          "description": "HTTP headers to send with the request"
+        },
+        {
+          "name": "body",
+          "type": "any",
+          "required": false,
+          "description": "Request body for POST PUT etc"
+        },
+        {
+          "name": "content_type",
+          "type": "string",
+          "required": false,
+          "default": "application/json",
+          "description": "Content-Type header for the request body"
+        },
+        {
+          "name": "response",
+          "type": "string",
+          "required": false,
+          "default": "json",
+          "description": "Expected response type (json or text)"
+        }
+      ],
+      "ports": [
+        "default"
+      ]
+    },
+    {
+      "name": "if",
+      "category": "flow",
+      "description": "Conditional branching step that evaluates a boolean condition",
+      "inputs": [
+        {
+          "name": "condition",
+          "type": "bool",
+          "required": true,
+          "description": "Boolean condition to evaluate (use $js: for dynamic expressions)"
+        }
+      ],
+      "ports": [
+        "true",
+        "false"
+      ]
+    },
+    {
+      "name": "js",
+      "category": "scripting",
+      "description": "Executes JavaScript code with access to pipeline context",
+      "inputs": [
+        {
+          "name": "code",
+          "type": "string",
+          "required": true,
+          "description": "JavaScript code to execute (use ctx for step outputs and $vars/$secrets for globals)",
+          "raw": true
+        },
+        {
+          "name": "max_execution_time",
+          "type": "duration",
+          "required": false,
+          "description": "Maximum execution time per event (e.g. 500ms); the script is also interrupted when the pipeline stops"
+        },
+        {
+          "name": "max_call_stack_size",
+          "type": "int",
+          "required": false,
+          "description": "Maximum function call depth (default 1024)"
+        },
+        {
+          "name": "max_memory",
+          "type": "uint64",
+          "required": false,
+          "description": "Interrupts the script when the heap allocations made while it runs exceed this many bytes (approximate: allocations are sampled for the whole process)"
+        },
+        {
+          "name": "emit_each",
+          "type": "bool",
+          "required": false,
+          "default": "false",
+          "description": "If true the code returns an array and each element becomes a separate output"
+        },
+        {
+          "name": "module_dirs",
+          "type": "[]string",
+          "required": false,
+          "description": "Other directories require() can load modules from (relative to the pipeline file); by default only the directory of the pipeline file"
+        }
+      ]
+    },
+    {
+      "name": "json",
+      "category": "data",
+      "description": "Parses a JSON string into a structured object",
+      "inputs": [
+        {
+          "name": "data",
+          "type": "string",
+          "required": true,
+          "description": "JSON string to parse (supports variable interpolation)"
+        }
+      ],
+      "ports": [
+        "default"
+      ]
+    },
+    {
+      "name": "map",
+      "category": "data",
+      "description": "Creates an object by mapping named fields to values",
+      "inputs": [
+        {
+          "name": "fields",
+          "type": "[]MapField",
+          "required": true,
+          "description": "List of name/value pairs defining the output fields"
+        }
+      ],
+      "ports": [
+        "default"
+      ]
+    },
+    {
+      "name": "webhook",
+      "category": "trigger",
+      "description": "Receives HTTP events and propagates them in the pipeline",
+      "inputs": [
+        {
+          "name": "path",
+          "type": "string",
+          "required": false,
+          "default": "/webhook",
+          "description": "The URL path to listen on"
+        },
+        {
+          "name": "method",
+          "type": "string",
+          "required": false,
+          "default": "POST",
+          "description": "HTTP method to accept"
+        },
+        {
+          "name": "continuous",
+          "type": "bool",
+          "required": false,
+          "default": "false",
+          "description": "If true acts as entry point; if false waits for input before listening"
+        }
+      ],
+      "ports": [
+        "default"
+      ],
+      "continuous_configurable": true
+    }
+  ],
+  "version": "1.0.0"
+}`
+
+// stepsMetadata is loaded before the init functions of the package,
+// so that builder.RegisterStepType finds the metadata of each step type
+var stepsMetadata = loadStepsMetadata()
+
+func loadStepsMetadata() []StepMetadata {
+	var registry struct {
+		Steps []StepMetadata `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(stepsMetadataJSON), &registry); err != nil {
+		return nil
+	}
+	for _, step := range registry.Steps {
+		config.RegisterStepMetadata(step)
+	}
+	return registry.Steps
+}
+
+// GetStepsMetadata returns the metadata for all registered steps
+func GetStepsMetadata() []StepMetadata {
+	return stepsMetadata
+}
+
+// GetStepMetadata returns the metadata for a specific step by name
+func GetStepMetadata(name string) (StepMetadata, bool) {
+	for _, step := range stepsMetadata {
+		if step.Name == name {
+			return step, true
+		}
+	}
+	return StepMetadata{}, false
+}
+
+// GetStepsMetadataJSON returns the raw JSON metadata
+func GetStepsMetadataJSON() string {
+	return stepsMetadataJSON
+}
+
+// GetStepsByCategory returns all steps in a given category
+func GetStepsByCategory(category string) []StepMetadata {
+	var result []StepMetadata
+	for _, step := range stepsMetadata {
+		if step.Category == category {
+			result = append(result, step)
+		}
+	}
+	return result
+}
+
+// GetCategories returns all unique categories
+func GetCategories() []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, step := range stepsMetadata {
+		if !seen[step.Category] {
+			seen[step.Category] = true
+			categories = append(categories, step.Category)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,29 @@
+package steps
+
+import (
+	"testing"
+
+	"github.com/simon020286/go-pipeline/builder"
+)
+
+func TestRegisteredStepsCarryMetadata(t *testing.T) {
+	for _, step := range GetStepsMetadata() {
+		meta, err := builder.DescribeStepType(step.Name)
+		if err != nil {
+			t.Errorf("step %s has metadata but is not registered: %v", step.Name, err)
+			continue
+		}
+		if meta.Category != step.Category || len(meta.Inputs) != len(step.Inputs) {
+			t.Errorf("step %s: registry metadata %+v differs from generated %+v", step.Name, meta, step)
+		}
+	}
+
+	cron, _ := builder.DescribeStepType("cron")
+	if !cron.Continuous {
+		t.Error("cron should be described as continuous")
+	}
+	delay, _ := builder.DescribeStepType("delay")
+	if delay.Continuous {
+		t.Error("delay should not be described as continuous")
+	}
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/simon020286/go-pipeline/models"
 )
 
-// @step name=webhook category=trigger description=Receives HTTP events and propagates them in the pipeline
+// @step name=webhook category=trigger ports=default continuous=configurable description=Receives HTTP events and propagates them in the pipeline
 type WebhookConfig struct {
 	Path       string `step:"default=/webhook,desc=The URL path to listen on"`
 	Method     string `step:"default=POST,desc=HTTP method to accept"`
@@ -172,25 +172,15 @@ func (s *WebhookStep) Run(ctx context.Context, inputs <-chan *models.StepInput)
 
 func init() {
 	builder.RegisterStepType("webhook", func(cfg map[string]any) (models.Step, error) {
-		method, ok := cfg["method"].(string)
-		if !ok {
-			method = "POST" // Default to POST if not specified
-		}
-
-		path, ok := cfg["path"].(string)
-		if !ok {
-			path = "/webhook" // Default path
-		}
-
-		continuous, ok := cfg["continuous"].(bool)
-		if !ok {
-			continuous = false
+		var c WebhookConfig
+		if err := builder.DecodeConfig(cfg, &c); err != nil {
+			return nil, fmt.Errorf("invalid webhook step: %w", err)
 		}
 
 		return &WebhookStep{
-			method:     method,
-			path:       path,
-			continuous: continuous,
+			method:     c.Method,
+			path:       c.Path,
+			continuous: c.Continuous,
 		}, nil
 	})
 }
@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// outputSubscription receives the outputs of one stage (or of all stages)
+type outputSubscription struct {
+	stageID string // "" for all stages
+	port    string // "" for all ports
+	ch      chan models.StepOutput
+	all     chan stageOutput // Used instead of ch when subscribed to all stages
+
+	mutex  sync.Mutex // Held while sending, so that closing never races with a send
+	closed bool
+	done   chan struct{} // Closed by Unsubscribe to release a blocked send
+	once   sync.Once
+}
+
+// stageOutput is an output tagged with the stage that produced it
+type stageOutput struct {
+	stageID string
+	output  models.StepOutput
+}
+
+// outputHub tracks the output subscriptions of a pipeline
+type outputHub struct {
+	mutex         sync.Mutex
+	subscriptions []*outputSubscription
+	running       bool
+	finished      map[string]bool // Stages of the current run that already ended
+}
+
+func newOutputHub() *outputHub {
+	return &outputHub{finished: make(map[string]bool)}
+}
+
+// Subscribe returns a channel receiving the outputs of a stage
+// With a port, only outputs containing that port are delivered, reduced to that port
+// Sends block until the output is read, so a slow reader slows the stage down (backpressure)
+// The channel is closed when the stage ends; subscribing before Start receives the whole run
+// A subscription made while no run is active (before Start or after a run ended) waits for
+// the next Start: if none follows, the channel stays open until Unsubscribe is called
+// An unknown stage yields a closed channel
+func (p *Pipeline) Subscribe(stageID, port string) <-chan models.StepOutput {
+	sub := &outputSubscription{
+		stageID: stageID,
+		port:    port,
+		ch:      make(chan models.StepOutput),
+		done:    make(chan struct{}),
+	}
+
+	p.mutex.RLock()
+	_, exists := p.stages[stageID]
+	p.mutex.RUnlock()
+
+	if !exists || !p.outputs.add(sub) {
+		sub.close()
+	}
+	return sub.ch
+}
+
+// Unsubscribe stops the delivery to a channel returned by Subscribe and closes it
+func (p *Pipeline) Unsubscribe(ch <-chan models.StepOutput) {
+	p.outputs.mutex.Lock()
+	var sub *outputSubscription
+	for _, candidate := range p.outputs.subscriptions {
+		if candidate.ch != nil && (<-chan models.StepOutput)(candidate.ch) == ch {
+			sub = candidate
+			break
+		}
+	}
+	p.outputs.mutex.Unlock()
+
+	if sub != nil {
+		p.outputs.remove(sub)
+	}
+}
+
+// Outputs returns an iterator over the outputs of all stages, as (stage ID, output) pairs
+// The subscription starts when Outputs is called (call it before Start to receive the
+// whole run) and the iteration ends with the run; breaking out of the loop unsubscribes
+// Called while no run is active, the iteration waits for the next Start: do not range over
+// it after the last run, it would never end
+// Always range over the result: until then, the stages wait for it to read their outputs
+func (p *Pipeline) Outputs() iter.Seq2[string, models.StepOutput] {
+	sub := &outputSubscription{
+		all:  make(chan stageOutput),
+		done: make(chan struct{}),
+	}
+	p.outputs.add(sub)
+
+	return func(yield func(string, models.StepOutput) bool) {
+		for item := range sub.all {
+			if !yield(item.stageID, item.output) {
+				p.outputs.remove(sub)
+				return
+			}
+		}
+	}
+}
+
+// StageOutputs returns an iterator over the outputs of a stage (see Subscribe and Outputs)
+func (p *Pipeline) StageOutputs(stageID, port string) iter.Seq[models.StepOutput] {
+	ch := p.Subscribe(stageID, port)
+
+	return func(yield func(models.StepOutput) bool) {
+		for output := range ch {
+			if !yield(output) {
+				p.Unsubscribe(ch)
+				return
+			}
+		}
+	}
+}
+
+// add registers a subscription; it returns false if its stage already ended in the current run
+// Outside a run, the subscription is kept for the next one (closed by finish or remove)
+func (h *outputHub) add(sub *outputSubscription) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if sub.stageID != "" && h.running && h.finished[sub.stageID] {
+		return false
+	}
+	h.subscriptions = append(h.subscriptions, sub)
+	return true
+}
+
+// remove unregisters a subscription and closes its channel
+func (h *outputHub) remove(sub *outputSubscription) {
+	sub.once.Do(func() { close(sub.done) })
+
+	h.mutex.Lock()
+	for i, candidate := range h.subscriptions {
+		if candidate == sub {
+			h.subscriptions = append(h.subscriptions[:i], h.subscriptions[i+1:]...)
+			break
+		}
+	}
+	h.mutex.Unlock()
+
+	sub.close()
+}
+
+// start marks the beginning of a run
+func (h *outputHub) start() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.running = true
+	h.finished = make(map[string]bool)
+}
+
+// publish delivers an output of a stage to its subscribers, waiting for them to read it
+func (h *outputHub) publish(ctx context.Context, stageID string, output models.StepOutput) {
+	h.mutex.Lock()
+	subscriptions := make([]*outputSubscription, 0, len(h.subscriptions))
+	for _, sub := range h.subscriptions {
+		if sub.stageID == "" || sub.stageID == stageID {
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+	h.mutex.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.send(ctx, stageID, output)
+	}
+}
+
+// stageFinished closes the subscriptions of a stage that ended
+func (h *outputHub) stageFinished(stageID string) {
+	h.mutex.Lock()
+	h.finished[stageID] = true
+	var ended []*outputSubscription
+	remaining := h.subscriptions[:0]
+	for _, sub := range h.subscriptions {
+		if sub.stageID == stageID {
+			ended = append(ended, sub)
+		} else {
+			remaining = append(remaining, sub)
+		}
+	}
+	h.subscriptions = remaining
+	h.mutex.Unlock()
+
+	for _, sub := range ended {
+		sub.close()
+	}
+}
+
+// finish closes all the subscriptions at the end of a run
+func (h *outputHub) finish() {
+	h.mutex.Lock()
+	ended := h.subscriptions
+	h.subscriptions = nil
+	h.running = false
+	h.mutex.Unlock()
+
+	for _, sub := range ended {
+		sub.close()
+	}
+}
+
+// send delivers an output unless the subscription is closed or the run is cancelled
+func (s *outputSubscription) send(ctx context.Context, stageID string, output models.StepOutput) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+
+	if s.all != nil {
+		select {
+		case s.all <- stageOutput{stageID: stageID, output: output}:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	if s.port != "" {
+		data, ok := output.Data[s.port]
+		if !ok {
+			return
+		}
+		output.Data = map[string]*models.Data{s.port: data}
+	}
+
+	select {
+	case s.ch <- output:
+	case <-s.done:
+	case <-ctx.Done():
+	}
+}
+
+// close closes the subscription channel (once)
+func (s *outputSubscription) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.all != nil {
+		close(s.all)
+	} else {
+		close(s.ch)
+	}
+}
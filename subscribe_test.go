@@ -0,0 +1,218 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// portsStep emits count outputs with the ports "even" and "odd" for each input
+type portsStep struct {
+	count int
+}
+
+func (s *portsStep) IsContinuous() bool {
+	return false
+}
+
+func (s *portsStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		for input := range inputs {
+			for i := 0; i < s.count; i++ {
+				port := "even"
+				if i%2 == 1 {
+					port = "odd"
+				}
+				select {
+				case outputChan <- models.StepOutput{
+					Data:      models.CreateResultData(port, i),
+					EventID:   input.EventID,
+					Timestamp: time.Now(),
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+func TestPipeline_Subscribe(t *testing.T) {
+	p := NewPipeline()
+	source := NewStage("source", &portsStep{count: 4})
+	p.AddStage(source)
+	if err := p.AddStage(NewStage("sink", &mockStep{output: "done"})).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+
+	odd := p.Subscribe("source", "odd")
+	sink := p.Subscribe("sink", "")
+	if _, ok := <-p.Subscribe("missing", ""); ok {
+		t.Error("Expected closed channel for an unknown stage")
+	}
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	var values []any
+	for output := range odd {
+		if len(output.Data) != 1 {
+			t.Errorf("Expected only the 'odd' port, got %v", output.Data)
+		}
+		values = append(values, output.Data["odd"].Value)
+	}
+	if len(values) != 2 || values[0] != 1 || values[1] != 3 {
+		t.Errorf("Unexpected odd values: %v", values)
+	}
+
+	var sinkOutputs int
+	for output := range sink {
+		sinkOutputs++
+		if output.Data["default"].Value != "done" {
+			t.Errorf("Unexpected sink output: %v", output.Data)
+		}
+	}
+	if sinkOutputs != 1 {
+		t.Errorf("Expected 1 sink output, got %d", sinkOutputs)
+	}
+
+	p.Wait()
+}
+
+func TestPipeline_SubscribeBackpressure(t *testing.T) {
+	p := NewPipeline()
+	p.AddStage(NewStage("source", &portsStep{count: 3}))
+	outputs := p.Subscribe("source", "")
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Nobody reads: the stage waits for the subscriber
+	time.Sleep(50 * time.Millisecond)
+	if !p.IsRunning() {
+		t.Fatal("Pipeline finished without the subscriber reading its outputs")
+	}
+
+	count := 0
+	for range outputs {
+		count++
+	}
+	p.Wait()
+	if count != 3 {
+		t.Errorf("Expected 3 outputs, got %d", count)
+	}
+}
+
+func TestPipeline_OutputsIterator(t *testing.T) {
+	p := NewPipeline()
+	source := NewStage("source", &portsStep{count: 3})
+	p.AddStage(source)
+	if err := p.AddStage(NewStage("sink", &mockStep{output: "done"})).After(source); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+
+	all := p.Outputs()
+	first := p.StageOutputs("source", "")
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	counts := make(map[string]int)
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for stageID := range all {
+			counts[stageID]++
+		}
+	}()
+
+	// Breaking out of the loop unsubscribes
+	for range first {
+		break
+	}
+
+	<-collected
+	if counts["source"] != 3 || counts["sink"] != 1 {
+		t.Errorf("Unexpected outputs per stage: %v", counts)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Pipeline did not finish after the iterators ended")
+	}
+}
+
+func TestPipeline_SubscribeAfterRun(t *testing.T) {
+	p := NewPipeline()
+	p.AddStage(NewStage("source", &portsStep{count: 2}))
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// No run is active: the subscriptions wait for the next Start
+	ch := p.Subscribe("source", "")
+	outputs := p.Outputs()
+	select {
+	case _, ok := <-ch:
+		t.Fatalf("Expected the subscription to wait for the next run (ok=%v)", ok)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Both subscriptions must be read: each one holds the stage back until it reads
+	iterated := make(chan int)
+	go func() {
+		count := 0
+		for stageID := range outputs {
+			if stageID == "source" {
+				count++
+			}
+		}
+		iterated <- count
+	}()
+
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	var received int
+	for range ch {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("Expected the 2 outputs of the next run, got %d", received)
+	}
+	select {
+	case count := <-iterated:
+		if count != 2 {
+			t.Errorf("Expected the iterator to yield the 2 outputs of the next run, got %d", count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The iteration did not end with the run")
+	}
+	p.Wait()
+
+	// Unsubscribe releases a subscription no run will close
+	ch = p.Subscribe("source", "")
+	p.Unsubscribe(ch)
+	if _, ok := <-ch; ok {
+		t.Error("Expected Unsubscribe to close the channel")
+	}
+}
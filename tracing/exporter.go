@@ -0,0 +1,260 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Exporter sends finished spans to a backend
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []Span) error
+	Shutdown(ctx context.Context) error
+}
+
+// JSONFileExporter appends spans to a JSON Lines file
+type JSONFileExporter struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewJSONFileExporter creates an exporter that appends one JSON span per line to path
+func NewJSONFileExporter(path string) *JSONFileExporter {
+	return &JSONFileExporter{path: path}
+}
+
+// ExportSpans appends the spans to the file, creating it if needed
+func (e *JSONFileExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, span := range spans {
+		if err := encoder.Encode(span); err != nil {
+			return fmt.Errorf("failed to encode span: %w", err)
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if dir := filepath.Dir(e.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create trace directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(e.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open trace file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write spans: %w", err)
+	}
+	return nil
+}
+
+// Shutdown implements Exporter (the file is opened on every export)
+func (e *JSONFileExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP/HTTP with JSON encoding
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+}
+
+// NewOTLPExporter creates an exporter posting to endpoint
+// (e.g. "http://localhost:4318/v1/traces"); serviceName is reported as service.name
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		headers:     make(map[string]string),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SetHeader sets a header sent with every export (e.g. authentication)
+func (e *OTLPExporter) SetHeader(key, value string) {
+	e.headers[key] = value
+}
+
+// ExportSpans posts the spans to the collector
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP collector returned status %d: %s", resp.StatusCode, string(message))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// Shutdown implements Exporter
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// OTLP/JSON request payload (ExportTraceServiceRequest)
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// instrumentationScope is the scope name reported to the collector
+const instrumentationScope = "github.com/simon020286/go-pipeline/tracing"
+
+func (e *OTLPExporter) request(spans []Span) otlpRequest {
+	otlpSpans := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		otlpSpans[i] = otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              otlpKind(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusCode(span.Status.Code), Message: span.Status.Message},
+		}
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpKeyValue{{Key: "service.name", Value: otlpValue(e.serviceName)}},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScope},
+				Spans: otlpSpans,
+			}},
+		}},
+	}
+}
+
+func otlpKind(kind SpanKind) int {
+	switch kind {
+	case SpanKindInternal:
+		return 1
+	case SpanKindClient:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func otlpStatusCode(code StatusCode) int {
+	switch code {
+	case StatusOK:
+		return 1
+	case StatusError:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func otlpAttributes(attributes map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, otlpKeyValue{Key: key, Value: otlpValue(attributes[key])})
+	}
+	return result
+}
+
+// otlpValue encodes an attribute value (int64 values are strings in OTLP/JSON)
+func otlpValue(value any) map[string]any {
+	switch v := value.(type) {
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,75 @@
+// Package tracing turns pipeline events into OpenTelemetry-style traces: every event ID
+// is a trace, every stage execution a span and every HTTP request a child span.
+// Spans are sent to an Exporter (JSON file or OTLP/HTTP).
+package tracing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// SpanKind describes the role of a span
+type SpanKind string
+
+const (
+	SpanKindInternal SpanKind = "internal" // Stage execution
+	SpanKindClient   SpanKind = "client"   // Outgoing request (e.g. HTTP)
+)
+
+// StatusCode is the outcome of a span
+type StatusCode string
+
+const (
+	StatusUnset StatusCode = "unset"
+	StatusOK    StatusCode = "ok"
+	StatusError StatusCode = "error"
+)
+
+// Status is the outcome of a span with an optional error message
+type Status struct {
+	Code    StatusCode `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+// Span is a finished unit of work of a trace
+type Span struct {
+	TraceID      string         `json:"trace_id"` // 32 hex chars
+	SpanID       string         `json:"span_id"`  // 16 hex chars
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Name         string         `json:"name"`
+	Kind         SpanKind       `json:"kind"`
+	StartTime    time.Time      `json:"start_time"`
+	EndTime      time.Time      `json:"end_time"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Status       Status         `json:"status"`
+}
+
+// Duration returns the duration of the span
+func (s Span) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// TraceID returns the trace ID of a pipeline event
+// The ID is derived from the event ID, so all the stages that process the event
+// (even in different processes) report to the same trace
+func TraceID(eventID string) string {
+	sum := sha256.Sum256([]byte(eventID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// stageSpanID returns the span ID of a stage execution
+// It is deterministic so that child spans can reference it without waiting for the stage to end
+func stageSpanID(eventID, stageID string, attempt int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", eventID, stageID, attempt)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// randomSpanID returns a new random span ID
+func randomSpanID() string {
+	var id [8]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,222 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// DefaultBatchSize is the number of spans buffered before they are exported
+const DefaultBatchSize = 100
+
+// Span attribute keys
+const (
+	AttrEventID        = "pipeline.event.id"
+	AttrStageID        = "pipeline.stage.id"
+	AttrStepType       = "pipeline.step.type"
+	AttrAttempt        = "pipeline.stage.attempt"
+	AttrStatus         = "pipeline.stage.status"
+	AttrHTTPMethod     = "http.request.method"
+	AttrHTTPURL        = "url.full"
+	AttrHTTPStatusCode = "http.response.status_code"
+)
+
+// Tracer is an EventListener that builds spans from stage events and exports them in batches
+//
+// A stage span is built when the stage completes or fails (both events carry the time
+// spent on the event), so the tracer does not depend on the order events are delivered in
+// Outputs that do not answer an input (e.g. a webhook receiving a request) become
+// zero-length spans marking the start of the trace
+type Tracer struct {
+	exporter  Exporter
+	batchSize int
+
+	mutex sync.Mutex
+	spans []Span
+	err   error // First export error not yet returned by Flush
+
+	exportMutex sync.Mutex // Serializes calls to the exporter
+}
+
+// NewTracer creates a tracer exporting spans to exporter
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter, batchSize: DefaultBatchSize}
+}
+
+// SetBatchSize sets the number of spans buffered before an export (1 exports every span)
+func (t *Tracer) SetBatchSize(size int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if size < 1 {
+		size = 1
+	}
+	t.batchSize = size
+}
+
+// OnEvent implements models.EventListener
+func (t *Tracer) OnEvent(event models.Event) {
+	spans := spansFromEvent(event)
+	if len(spans) == 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	t.spans = append(t.spans, spans...)
+	full := len(t.spans) >= t.batchSize
+	t.mutex.Unlock()
+
+	if full {
+		if err := t.export(context.Background()); err != nil {
+			t.mutex.Lock()
+			if t.err == nil {
+				t.err = err
+			}
+			t.mutex.Unlock()
+		}
+	}
+}
+
+// Flush exports the buffered spans
+// It also returns the first error of the batches exported automatically since the last Flush
+func (t *Tracer) Flush(ctx context.Context) error {
+	err := t.export(ctx)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.err != nil {
+		err, t.err = t.err, nil
+	}
+	return err
+}
+
+// Shutdown flushes the buffered spans and shuts the exporter down
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	flushErr := t.Flush(ctx)
+	if err := t.exporter.Shutdown(ctx); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+func (t *Tracer) export(ctx context.Context) error {
+	t.exportMutex.Lock()
+	defer t.exportMutex.Unlock()
+
+	t.mutex.Lock()
+	spans := t.spans
+	t.spans = nil
+	t.mutex.Unlock()
+
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := t.exporter.ExportSpans(ctx, spans); err != nil {
+		return fmt.Errorf("failed to export %d spans: %w", len(spans), err)
+	}
+	return nil
+}
+
+// spansFromEvent returns the spans completed by a pipeline event
+func spansFromEvent(event models.Event) []Span {
+	switch payload := event.Payload.(type) {
+	case models.StageCompletedEvent:
+		if payload.EventID == "" {
+			return nil
+		}
+		span := stageSpan(payload.EventID, payload.StageID, payload.StepID, payload.Attempt, event.Timestamp.Add(-payload.Duration), event.Timestamp)
+		span.Status = Status{Code: StatusOK}
+		span.Attributes[AttrStatus] = "ok"
+		return []Span{span}
+
+	case models.StageErrorEvent:
+		if payload.EventID == "" {
+			return nil
+		}
+		span := stageSpan(payload.EventID, payload.StageID, payload.StepID, payload.Attempt, event.Timestamp.Add(-payload.Duration), event.Timestamp)
+		span.Status = Status{Code: StatusError, Message: payload.Error}
+		span.Attributes[AttrStatus] = "error"
+		if payload.StatusCode != 0 {
+			span.Attributes[AttrHTTPStatusCode] = payload.StatusCode
+		}
+		return []Span{span}
+
+	case models.StageOutputEvent:
+		if payload.EventID == "" {
+			return nil
+		}
+		var spans []Span
+		if payload.Attempt == 0 {
+			// New event: the stage span starts and ends here
+			span := stageSpan(payload.EventID, payload.StageID, payload.StepID, 0, event.Timestamp, event.Timestamp)
+			span.Status = Status{Code: StatusOK}
+			span.Attributes[AttrStatus] = "ok"
+			spans = append(spans, span)
+		}
+		if httpInfo, ok := payload.Metadata["http"].(map[string]any); ok {
+			parentSpanID := stageSpanID(payload.EventID, payload.StageID, payload.Attempt)
+			spans = append(spans, httpSpan(payload.EventID, parentSpanID, httpInfo, event.Timestamp))
+		}
+		return spans
+	}
+	return nil
+}
+
+// stageSpan creates the span of a stage execution
+func stageSpan(eventID, stageID, stepID string, attempt int, start, end time.Time) Span {
+	return Span{
+		TraceID:   TraceID(eventID),
+		SpanID:    stageSpanID(eventID, stageID, attempt),
+		Name:      "stage " + stageID,
+		Kind:      SpanKindInternal,
+		StartTime: start,
+		EndTime:   end,
+		Attributes: map[string]any{
+			AttrEventID:  eventID,
+			AttrStageID:  stageID,
+			AttrStepType: stepID,
+			AttrAttempt:  attempt,
+		},
+	}
+}
+
+// httpSpan creates the child span of an HTTP request from the output metadata of a step
+func httpSpan(eventID, parentSpanID string, info map[string]any, end time.Time) Span {
+	method, _ := info["method"].(string)
+	start, ok := info["started_at"].(time.Time)
+	if duration, hasDuration := info["duration"].(time.Duration); hasDuration {
+		if ok {
+			end = start.Add(duration)
+		} else {
+			start = end.Add(-duration)
+		}
+	} else if !ok {
+		start = end
+	}
+
+	span := Span{
+		TraceID:      TraceID(eventID),
+		SpanID:       randomSpanID(),
+		ParentSpanID: parentSpanID,
+		Name:         "HTTP " + method,
+		Kind:         SpanKindClient,
+		StartTime:    start,
+		EndTime:      end,
+		Attributes: map[string]any{
+			AttrHTTPMethod: method,
+		},
+		Status: Status{Code: StatusUnset},
+	}
+	if url, ok := info["url"].(string); ok {
+		span.Attributes[AttrHTTPURL] = url
+	}
+	if code, ok := info["status_code"].(int); ok {
+		span.Attributes[AttrHTTPStatusCode] = code
+		if code >= 500 {
+			span.Status = Status{Code: StatusError, Message: fmt.Sprintf("HTTP %d", code)}
+		}
+	}
+	return span
+}
@@ -0,0 +1,275 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pipeline "github.com/simon020286/go-pipeline"
+	"github.com/simon020286/go-pipeline/models"
+)
+
+// memoryExporter keeps the exported spans in memory
+type memoryExporter struct {
+	mutex sync.Mutex
+	spans []Span
+}
+
+func (e *memoryExporter) ExportSpans(ctx context.Context, spans []Span) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// triggerStep behaves like a webhook: it emits one new event and stops
+type triggerStep struct{}
+
+func (s *triggerStep) IsContinuous() bool {
+	return true
+}
+
+func (s *triggerStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput, 1)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		<-inputs
+		outputChan <- models.StepOutput{
+			Data:      models.CreateDefaultResultData("request"),
+			EventID:   "evt_webhook",
+			Timestamp: time.Now(),
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+// fetchStep simulates an HTTP call and reports its metadata
+type fetchStep struct{}
+
+func (s *fetchStep) IsContinuous() bool {
+	return false
+}
+
+func (s *fetchStep) Run(ctx context.Context, inputs <-chan *models.StepInput) (<-chan models.StepOutput, <-chan error) {
+	outputChan := make(chan models.StepOutput, 1)
+	errorChan := make(chan error)
+
+	go func() {
+		defer close(outputChan)
+		defer close(errorChan)
+
+		for input := range inputs {
+			startTime := time.Now()
+			time.Sleep(10 * time.Millisecond)
+			outputChan <- models.StepOutput{
+				Data:      models.CreateDefaultResultData("response"),
+				EventID:   input.EventID,
+				Timestamp: time.Now(),
+				Metadata: map[string]any{
+					"http": map[string]any{
+						"method":      "GET",
+						"url":         "http://example.com",
+						"status_code": 200,
+						"started_at":  startTime,
+						"duration":    time.Since(startTime),
+					},
+				},
+			}
+		}
+	}()
+
+	return outputChan, errorChan
+}
+
+func TestTracer_WebhookEventTrace(t *testing.T) {
+	exporter := &memoryExporter{}
+	tracer := NewTracer(exporter)
+
+	p := pipeline.NewPipeline()
+	trigger := pipeline.NewStage("webhook", &triggerStep{})
+	p.AddStage(trigger)
+	if err := p.AddStage(pipeline.NewStage("fetch", &fetchStep{})).After(trigger); err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+	p.AddListener(tracer)
+
+	if err := p.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	byName := make(map[string]Span)
+	for _, span := range exporter.spans {
+		if span.TraceID != TraceID("evt_webhook") {
+			t.Errorf("Span %s has trace %s, expected the webhook event trace", span.Name, span.TraceID)
+		}
+		byName[span.Name] = span
+	}
+	if len(byName) != 3 {
+		t.Fatalf("Expected 3 spans, got %+v", exporter.spans)
+	}
+
+	origin := byName["stage webhook"]
+	if origin.Attributes[AttrAttempt] != 0 || origin.Duration() != 0 {
+		t.Errorf("Unexpected webhook span: %+v", origin)
+	}
+
+	fetch := byName["stage fetch"]
+	if fetch.Attributes[AttrAttempt] != 1 || fetch.Status.Code != StatusOK {
+		t.Errorf("Unexpected fetch span: %+v", fetch)
+	}
+	if fetch.Duration() < 10*time.Millisecond {
+		t.Errorf("Expected fetch span to last at least 10ms, got %s", fetch.Duration())
+	}
+	if fetch.StartTime.Before(origin.StartTime) {
+		t.Error("Fetch span starts before the webhook received the event")
+	}
+
+	request := byName["HTTP GET"]
+	if request.ParentSpanID != fetch.SpanID {
+		t.Errorf("HTTP span parent %s, expected %s", request.ParentSpanID, fetch.SpanID)
+	}
+	if request.Kind != SpanKindClient || request.Attributes[AttrHTTPStatusCode] != 200 {
+		t.Errorf("Unexpected HTTP span: %+v", request)
+	}
+}
+
+func TestTracer_StageError(t *testing.T) {
+	spans := spansFromEvent(models.NewEvent(models.StageErrorEvent{
+		StageID:    "fetch",
+		StepID:     "http_client",
+		EventID:    "evt_1",
+		Attempt:    2,
+		Duration:   time.Second,
+		Error:      "boom",
+		StatusCode: 503,
+	}))
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Status.Code != StatusError || span.Status.Message != "boom" {
+		t.Errorf("Unexpected status: %+v", span.Status)
+	}
+	if span.Duration() != time.Second {
+		t.Errorf("Expected 1s span, got %s", span.Duration())
+	}
+	if span.SpanID != stageSpanID("evt_1", "fetch", 2) {
+		t.Error("Span ID does not identify the attempt")
+	}
+	if span.Attributes[AttrHTTPStatusCode] != 503 {
+		t.Errorf("Expected status code attribute, got %v", span.Attributes)
+	}
+}
+
+func TestJSONFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces", "spans.jsonl")
+	exporter := NewJSONFileExporter(path)
+
+	spans := []Span{
+		{TraceID: TraceID("evt_1"), SpanID: "0000000000000001", Name: "stage a", Kind: SpanKindInternal},
+		{TraceID: TraceID("evt_1"), SpanID: "0000000000000002", Name: "stage b", Kind: SpanKindInternal},
+	}
+	if err := exporter.ExportSpans(context.Background(), spans); err != nil {
+		t.Fatalf("ExportSpans failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var span Span
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatalf("Invalid span line: %v", err)
+		}
+		names = append(names, span.Name)
+	}
+	if len(names) != 2 || names[0] != "stage a" || names[1] != "stage b" {
+		t.Errorf("Unexpected spans: %v", names)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var received map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte("{}"))
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", "orders")
+	exporter.SetHeader("Authorization", "Bearer token")
+
+	start := time.Unix(0, 1000)
+	span := Span{
+		TraceID:    TraceID("evt_1"),
+		SpanID:     stageSpanID("evt_1", "fetch", 1),
+		Name:       "stage fetch",
+		Kind:       SpanKindInternal,
+		StartTime:  start,
+		EndTime:    start.Add(time.Microsecond),
+		Attributes: map[string]any{AttrAttempt: 1, AttrStageID: "fetch"},
+		Status:     Status{Code: StatusError, Message: "boom"},
+	}
+	if err := exporter.ExportSpans(context.Background(), []Span{span}); err != nil {
+		t.Fatalf("ExportSpans failed: %v", err)
+	}
+
+	resourceSpans := received["resourceSpans"].([]any)[0].(map[string]any)
+	serviceName := resourceSpans["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	if serviceName["value"].(map[string]any)["stringValue"] != "orders" {
+		t.Errorf("Unexpected resource attributes: %v", serviceName)
+	}
+
+	got := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if len(got["traceId"].(string)) != 32 || len(got["spanId"].(string)) != 16 {
+		t.Errorf("Unexpected IDs: %v / %v", got["traceId"], got["spanId"])
+	}
+	if got["startTimeUnixNano"] != "1000" || got["endTimeUnixNano"] != "2000" {
+		t.Errorf("Unexpected times: %v - %v", got["startTimeUnixNano"], got["endTimeUnixNano"])
+	}
+	if got["kind"] != float64(1) || got["status"].(map[string]any)["code"] != float64(2) {
+		t.Errorf("Unexpected kind/status: %v %v", got["kind"], got["status"])
+	}
+	attempt := got["attributes"].([]any)[0].(map[string]any)
+	if attempt["key"] != AttrAttempt || attempt["value"].(map[string]any)["intValue"] != "1" {
+		t.Errorf("Unexpected attribute: %v", attempt)
+	}
+
+	failing := NewOTLPExporter(collector.URL+"/v1/traces", "orders")
+	if err := failing.ExportSpans(context.Background(), []Span{span}); err == nil {
+		t.Error("Expected error for rejected export")
+	}
+}